@@ -0,0 +1,252 @@
+// Command admin performs maintenance on the productivity timer database.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+)
+
+const usage = `Usage: admin <command> [arguments]
+
+Commands:
+  indexes [--verify]                   Create missing indexes, or only list them
+  reconcile-tagstats [--dry-run]       Rebuild tag stats from timer sessions
+  users                                List users
+  purge-user [--dry-run] <user-id>     Delete a user and all of their data
+  migrate [--dry-run]                  Apply pending schema migrations
+  vapid-keys                           Generate a key pair for push notifications
+
+The database is configured through the same environment as the server;
+no other settings are needed.
+`
+
+// app holds what every command needs
+type app struct {
+	db    database.Service
+	clock clock.Clock
+	out   io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	clk := clock.New()
+	a := &app{clock: clk, out: os.Stdout}
+	if needsDatabase(os.Args[1]) {
+		dbConfig, err := config.LoadDatabase()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "admin:", err)
+			os.Exit(1)
+		}
+		if a.db, err = database.New(*dbConfig, clk); err != nil {
+			fmt.Fprintln(os.Stderr, "admin:", err)
+			os.Exit(1)
+		}
+	}
+	if err := a.run(ctx, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "admin:", err)
+		os.Exit(1)
+	}
+}
+
+// needsDatabase reports whether command works on the database; the others
+// run without any configuration
+func needsDatabase(command string) bool {
+	return command != "vapid-keys" && command != "help"
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+
+	command, commandArgs := args[0], args[1:]
+	switch command {
+	case "indexes":
+		return a.indexes(ctx, commandArgs)
+	case "reconcile-tagstats":
+		return a.reconcileTagStats(ctx, commandArgs)
+	case "users":
+		return a.users(ctx)
+	case "purge-user":
+		return a.purgeUser(ctx, commandArgs)
+	case "migrate":
+		return a.migrate(ctx, commandArgs)
+	case "vapid-keys":
+		return a.vapidKeys()
+	case "help":
+		fmt.Fprint(a.out, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, run: admin help", command)
+	}
+}
+
+func (a *app) indexes(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("indexes", flag.ContinueOnError)
+	verify := flags.Bool("verify", false, "only list missing indexes, failing if there are any")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *verify {
+		missing, err := a.db.MissingIndexes(ctx)
+		if err != nil {
+			return err
+		}
+		for _, name := range missing {
+			fmt.Fprintln(a.out, "missing", name)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%d indexes missing, run: admin indexes", len(missing))
+		}
+		fmt.Fprintln(a.out, "All indexes present")
+		return nil
+	}
+
+	created, err := a.db.EnsureIndexes(ctx)
+	for _, name := range created {
+		fmt.Fprintln(a.out, "created", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%w (duplicate tag stats block their unique index, run: admin reconcile-tagstats; duplicate users block theirs, run: admin migrate)", err)
+	}
+	if len(created) == 0 {
+		fmt.Fprintln(a.out, "All indexes present")
+	}
+	return nil
+}
+
+func (a *app) reconcileTagStats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reconcile-tagstats", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report changes without making them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := reconcileTagStats(ctx, a.db, a.clock.Now(), *dryRun, a.out)
+	if err != nil {
+		return err
+	}
+	verb := "Made"
+	if *dryRun {
+		verb = "Would make"
+	}
+	fmt.Fprintf(a.out, "%s %d changes: %d created, %d updated, %d deleted\n",
+		verb, report.total(), report.created, report.updated, report.deleted)
+	return nil
+}
+
+func (a *app) users(ctx context.Context) error {
+	users, err := a.db.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEMAIL\tPROVIDER\tCREATED\tLAST LOGIN")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", user.ID, user.Email, user.Provider,
+			user.CreatedAt.Format(time.DateOnly), user.LastLoginAt.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func (a *app) purgeUser(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("purge-user", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be deleted without deleting it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: admin purge-user [--dry-run] <user-id>")
+	}
+	userID := flags.Arg(0)
+
+	if *dryRun {
+		counts, err := a.db.CountUserData(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if counts.Users == 0 {
+			return fmt.Errorf("no user with ID %s", userID)
+		}
+		fmt.Fprintf(a.out, "Would delete %s\n", describeUserData(counts))
+		return nil
+	}
+
+	counts, err := a.db.PurgeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if *counts == (database.UserDataCounts{}) {
+		return fmt.Errorf("no user with ID %s", userID)
+	}
+	fmt.Fprintf(a.out, "Deleted %s\n", describeUserData(counts))
+	return nil
+}
+
+func describeUserData(counts *database.UserDataCounts) string {
+	return fmt.Sprintf("%d user, %d timer sessions, %d tag stats, %d login sessions, %d push subscriptions, %d webhooks, %d webhook deliveries",
+		counts.Users, counts.TimerSessions, counts.TagStats, counts.Sessions, counts.PushSubscriptions, counts.Webhooks, counts.WebhookDeliveries)
+}
+
+// vapidKeys prints a new VAPID key pair in the .env format. Replacing the
+// keys of a running server cuts off every browser subscribed with the old
+// ones.
+func (a *app) vapidKeys() error {
+	publicKey, privateKey, err := push.GenerateKeys()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "VAPID_PUBLIC_KEY=%s\nVAPID_PRIVATE_KEY=%s\n", publicKey, privateKey)
+	return nil
+}
+
+func (a *app) migrate(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "list pending migrations without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		pending, err := a.db.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			fmt.Fprintf(a.out, "pending %d: %s\n", migration.Version, migration.Description)
+		}
+		fmt.Fprintf(a.out, "Would apply %d migrations\n", len(pending))
+		return nil
+	}
+
+	applied, err := a.db.Migrate(ctx)
+	for _, migration := range applied {
+		fmt.Fprintf(a.out, "applied %d: %s\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Applied %d migrations\n", len(applied))
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+)
+
+func newTestApp(t *testing.T) (*app, *bytes.Buffer) {
+	t.Helper()
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	var out bytes.Buffer
+	return &app{db: database.NewMemory(clk), clock: clk, out: &out}, &out
+}
+
+func seedSession(t *testing.T, db database.Service, userID, tag string, duration int64, now time.Time) {
+	t.Helper()
+	timerSession := models.NewTimerSession(userID, tag, now)
+	timerSession.Duration = duration
+	timerSession.Status = models.StatusCompleted
+	if err := db.CreateTimerSession(context.Background(), timerSession); err != nil {
+		t.Fatalf("CreateTimerSession: %v", err)
+	}
+}
+
+func seedTagStats(t *testing.T, db database.Service, userID, tag string, count int, duration int64, now time.Time) {
+	t.Helper()
+	userTagStats := models.NewUserTagStats(userID, tag, now)
+	userTagStats.SessionCount = count
+	userTagStats.TotalDuration = duration
+	if err := db.CreateUserTagStats(context.Background(), userTagStats); err != nil {
+		t.Fatalf("CreateUserTagStats: %v", err)
+	}
+}
+
+func TestReconcileTagStats(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+	now := a.clock.Now()
+
+	seedSession(t, a.db, "user-1", "coding", 600, now)
+	seedSession(t, a.db, "user-1", "coding", 300, now)
+	seedSession(t, a.db, "user-1", "reading", 120, now)
+	seedSession(t, a.db, "user-2", "coding", 60, now)
+
+	seedTagStats(t, a.db, "user-1", "coding", 1, 600, now)  // stale
+	seedTagStats(t, a.db, "user-1", "coding", 2, 900, now)  // duplicate
+	seedTagStats(t, a.db, "user-1", "reading", 1, 120, now) // correct
+	seedTagStats(t, a.db, "user-1", "gone", 3, 999, now)    // orphan
+	// user-2 "coding" is missing
+
+	if err := a.run(ctx, []string{"reconcile-tagstats", "--dry-run"}); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !strings.Contains(out.String(), "Would make 4 changes: 1 created, 1 updated, 2 deleted") {
+		t.Errorf("dry run output = %q", out.String())
+	}
+	if all, _ := a.db.ListAllUserTagStats(ctx); len(all) != 4 {
+		t.Fatalf("dry run changed tag stats: %d documents, want 4", len(all))
+	}
+
+	out.Reset()
+	if err := a.run(ctx, []string{"reconcile-tagstats"}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if !strings.Contains(out.String(), "Made 4 changes") {
+		t.Errorf("reconcile output = %q", out.String())
+	}
+
+	want := map[tagKey]int64{
+		{"user-1", "coding"}:  900,
+		{"user-1", "reading"}: 120,
+		{"user-2", "coding"}:  60,
+	}
+	all, err := a.db.ListAllUserTagStats(ctx)
+	if err != nil {
+		t.Fatalf("ListAllUserTagStats: %v", err)
+	}
+	if len(all) != len(want) {
+		t.Fatalf("tag stats = %d documents, want %d", len(all), len(want))
+	}
+	for _, userTagStats := range all {
+		if duration, ok := want[tagKey{userTagStats.UserID, userTagStats.Tag}]; !ok || duration != userTagStats.TotalDuration {
+			t.Errorf("%s %q total = %d, want %d", userTagStats.UserID, userTagStats.Tag, userTagStats.TotalDuration, duration)
+		}
+	}
+
+	out.Reset()
+	if err = a.run(ctx, []string{"reconcile-tagstats"}); err != nil {
+		t.Fatalf("second reconcile: %v", err)
+	}
+	if !strings.Contains(out.String(), "Made 0 changes") {
+		t.Errorf("second reconcile output = %q, want no changes", out.String())
+	}
+}
+
+func TestReconcileKeepsConfiguredTags(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+	now := a.clock.Now()
+
+	seedTagStats(t, a.db, "user-1", "client-a", 2, 900, now)
+	if _, err := a.db.UpdateTagSettings(ctx, "user-1", "client-a", models.TagSettings{Color: "#ff9800", Billable: true}); err != nil {
+		t.Fatalf("UpdateTagSettings: %v", err)
+	}
+
+	if err := a.run(ctx, []string{"reconcile-tagstats"}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if !strings.Contains(out.String(), `reset user-1 "client-a", kept for its settings`) {
+		t.Errorf("reconcile output = %q", out.String())
+	}
+	tag, err := a.db.FindUserTagStats(ctx, "user-1", "client-a")
+	if err != nil {
+		t.Fatalf("FindUserTagStats: %v", err)
+	}
+	if tag.SessionCount != 0 || tag.TotalDuration != 0 || tag.Color != "#ff9800" || !tag.Billable {
+		t.Errorf("tag after reconcile = %+v, want zero totals and its settings", tag)
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+	now := a.clock.Now()
+
+	user, err := a.db.FindOrCreateUser(ctx, &models.User{Email: "ada@example.com", Provider: "google", ProviderID: "g-1"})
+	if err != nil {
+		t.Fatalf("FindOrCreateUser: %v", err)
+	}
+	seedSession(t, a.db, user.ID, "coding", 600, now)
+	seedTagStats(t, a.db, user.ID, "coding", 1, 600, now)
+	seedSession(t, a.db, "someone-else", "coding", 60, now)
+
+	if err = a.run(ctx, []string{"purge-user", "--dry-run", user.ID}); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if got := out.String(); got != "Would delete 1 user, 1 timer sessions, 1 tag stats, 0 login sessions, 0 push subscriptions, 0 webhooks, 0 webhook deliveries\n" {
+		t.Errorf("dry run output = %q", got)
+	}
+	if found, _ := a.db.GetUserByID(ctx, user.ID); found == nil {
+		t.Fatal("dry run deleted the user")
+	}
+
+	out.Reset()
+	if err = a.run(ctx, []string{"purge-user", user.ID}); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if got := out.String(); got != "Deleted 1 user, 1 timer sessions, 1 tag stats, 0 login sessions, 0 push subscriptions, 0 webhooks, 0 webhook deliveries\n" {
+		t.Errorf("purge output = %q", got)
+	}
+	if found, _ := a.db.GetUserByID(ctx, user.ID); found != nil {
+		t.Error("user still exists after purge")
+	}
+	if expected, _ := a.db.RecomputeUserTagStats(ctx); len(expected) != 1 || expected[0].UserID != "someone-else" {
+		t.Errorf("other users' sessions were touched: %+v", expected)
+	}
+
+	if err = a.run(ctx, []string{"purge-user", user.ID}); err == nil || !strings.Contains(err.Error(), "no user with ID") {
+		t.Errorf("purging twice: err = %v, want no user", err)
+	}
+	if err = a.run(ctx, []string{"purge-user"}); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("purge without ID: err = %v, want usage", err)
+	}
+}
+
+func TestVAPIDKeys(t *testing.T) {
+	a, out := newTestApp(t)
+	if err := a.run(context.Background(), []string{"vapid-keys"}); err != nil {
+		t.Fatalf("vapid-keys: %v", err)
+	}
+
+	env := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		name, value, _ := strings.Cut(line, "=")
+		env[name] = value
+	}
+	if _, err := push.ParseKeys(env["VAPID_PUBLIC_KEY"], env["VAPID_PRIVATE_KEY"]); err != nil {
+		t.Errorf("generated keys do not parse: %v; output:\n%s", err, out)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+type reconcileReport struct {
+	created int
+	updated int
+	deleted int
+}
+
+func (r *reconcileReport) total() int {
+	return r.created + r.updated + r.deleted
+}
+
+type tagKey struct {
+	userID string
+	tag    string
+}
+
+// reconcileTagStats makes the tagstats collection agree with the timer
+// sessions it summarises: one document per user and tag holding the session
+// count and summed duration. Duplicates after the oldest document and stats
+// for tags without sessions are deleted, except that tags the user has
+// configured are kept with zero totals. Each change is written to out.
+func reconcileTagStats(ctx context.Context, db database.Service, now time.Time, dryRun bool, out io.Writer) (*reconcileReport, error) {
+	expected, err := db.RecomputeUserTagStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := db.ListAllUserTagStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[tagKey]*models.UserTagStats, len(expected))
+	for _, userTagStats := range expected {
+		want[tagKey{userTagStats.UserID, userTagStats.Tag}] = userTagStats
+	}
+
+	report := &reconcileReport{}
+	seen := make(map[tagKey]bool, len(actual))
+	for _, userTagStats := range actual {
+		key := tagKey{userTagStats.UserID, userTagStats.Tag}
+		expectedStats, ok := want[key]
+		switch {
+		case seen[key]:
+			fmt.Fprintf(out, "delete duplicate %s %q\n", userTagStats.UserID, userTagStats.Tag)
+			report.deleted++
+			if !dryRun {
+				err = db.DeleteUserTagStatsByID(ctx, userTagStats.ID)
+			}
+		case !ok && !userTagStats.TagSettings.IsZero():
+			// Keep tags the user has configured; they only lose their totals
+			if userTagStats.SessionCount == 0 && userTagStats.TotalDuration == 0 {
+				break
+			}
+			fmt.Fprintf(out, "reset %s %q, kept for its settings: %s -> no sessions\n", userTagStats.UserID, userTagStats.Tag,
+				describeTagStats(userTagStats))
+			report.updated++
+			if !dryRun {
+				userTagStats.SessionCount = 0
+				userTagStats.TotalDuration = 0
+				userTagStats.LastUpdated = now
+				err = db.UpdateUserTagStats(ctx, userTagStats)
+			}
+		case !ok:
+			fmt.Fprintf(out, "delete orphan %s %q\n", userTagStats.UserID, userTagStats.Tag)
+			report.deleted++
+			if !dryRun {
+				err = db.DeleteUserTagStatsByID(ctx, userTagStats.ID)
+			}
+		case userTagStats.SessionCount != expectedStats.SessionCount || userTagStats.TotalDuration != expectedStats.TotalDuration:
+			fmt.Fprintf(out, "update %s %q: %s -> %s\n", userTagStats.UserID, userTagStats.Tag,
+				describeTagStats(userTagStats), describeTagStats(expectedStats))
+			report.updated++
+			if !dryRun {
+				userTagStats.SessionCount = expectedStats.SessionCount
+				userTagStats.TotalDuration = expectedStats.TotalDuration
+				userTagStats.LastUpdated = now
+				err = db.UpdateUserTagStats(ctx, userTagStats)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reconciling %s %q: %w", userTagStats.UserID, userTagStats.Tag, err)
+		}
+		seen[key] = true
+	}
+
+	for _, expectedStats := range expected {
+		key := tagKey{expectedStats.UserID, expectedStats.Tag}
+		if seen[key] {
+			continue
+		}
+		fmt.Fprintf(out, "create %s %q: %s\n", expectedStats.UserID, expectedStats.Tag, describeTagStats(expectedStats))
+		report.created++
+		if dryRun {
+			continue
+		}
+		userTagStats := models.NewUserTagStats(expectedStats.UserID, expectedStats.Tag, now)
+		userTagStats.SessionCount = expectedStats.SessionCount
+		userTagStats.TotalDuration = expectedStats.TotalDuration
+		if err = db.CreateUserTagStats(ctx, userTagStats); err != nil {
+			return nil, fmt.Errorf("reconciling %s %q: %w", expectedStats.UserID, expectedStats.Tag, err)
+		}
+	}
+
+	return report, nil
+}
+
+func describeTagStats(userTagStats *models.UserTagStats) string {
+	return fmt.Sprintf("%d sessions, %s", userTagStats.SessionCount, models.FormatDuration(userTagStats.TotalDuration))
+}
@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/neilsmahajan/productivity-timer/internal/config"
+	"github.com/neilsmahajan/productivity-timer/internal/logging"
 	"github.com/neilsmahajan/productivity-timer/internal/server"
 )
 
@@ -39,7 +43,7 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	// Listen for the interrupt signal.
 	<-ctx.Done()
 
-	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	slog.Info("Shutting down gracefully, press Ctrl+C again to force")
 	stop() // Allow Ctrl+C to force shutdown
 
 	// The context is used to inform the server it has 5 seconds to finish
@@ -47,17 +51,38 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := apiServer.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown with error: %v", err)
+		slog.Error("Server forced to shutdown", "err", err)
 	}
 
-	log.Println("Server exiting")
+	slog.Info("Server exiting")
 
 	// Notify the main goroutine that the shutdown is complete
 	done <- true
 }
 
 func main() {
-	newServer := server.NewServer()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Also routes the standard log package through the structured handler
+	slog.SetDefault(logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level))
+
+	newServer, background, err := server.NewServer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The server listens while it waits for the database; a schema it cannot
+	// bring up to date stops it. Shutting down while it waits is not an error.
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for err := range background {
+			if !errors.Is(err, context.Canceled) {
+				log.Fatal(err)
+			}
+		}
+	}()
 
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
@@ -65,12 +90,14 @@ func main() {
 	// Run graceful shutdown in a separate goroutine
 	go gracefulShutdown(newServer, done)
 
-	err := newServer.ListenAndServe()
+	err = newServer.ListenAndServe()
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(fmt.Sprintf("http newServer error: %s", err))
 	}
 
-	// Wait for the graceful shutdown to complete
+	// Wait for the graceful shutdown to complete, including the server's
+	// background work
 	<-done
-	log.Println("Graceful shutdown complete.")
+	<-stopped
+	slog.Info("Graceful shutdown complete")
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+var errNotLoggedIn = errors.New("not logged in, run: ptimer login")
+
+// apiError is the server's ErrorResponse body
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("server returned %d %s", e.Status, e.Code)
+}
+
+// isAPIError reports whether err is an API error with the given code
+func isAPIError(err error, code string) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// timerResponse mirrors the server's TimerResponse
+type timerResponse struct {
+	Session  *models.TimerSession `json:"session"`
+	Duration int64                `json:"duration"`
+	Status   string               `json:"status"`
+}
+
+type tagListResponse struct {
+	Tags []string `json:"tags"`
+}
+
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type deviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// client calls the productivity timer JSON API
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL, token string) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends form (if any) to path and decodes the JSON response into out
+func (c *client) do(ctx context.Context, method, path string, form url.Values, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "ptimer")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		if resp.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("%w (%s)", errNotLoggedIn, apiErr.Error())
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// current returns the active timer, or nil if there is none
+func (c *client) current(ctx context.Context) (*timerResponse, error) {
+	var timer timerResponse
+	err := c.do(ctx, http.MethodGet, "/api/v1/timer", nil, &timer)
+	if isAPIError(err, "not_found") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &timer, nil
+}
+
+// timerAction posts tag to one of the start/stop/reset endpoints
+// timerAction posts action for tag; an empty note leaves the session's note
+// alone, except for the note action where it removes it
+func (c *client) timerAction(ctx context.Context, action, tag, note string) (*timerResponse, error) {
+	form := url.Values{"tag": {tag}}
+	if note != "" || action == "note" {
+		form.Set("note", note)
+	}
+
+	var timer timerResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/timer/"+action, form, &timer); err != nil {
+		return nil, err
+	}
+	return &timer, nil
+}
+
+// startTimer starts tag's timer, counting down from countdown unless it is
+// zero
+func (c *client) startTimer(ctx context.Context, tag, note string, countdown time.Duration) (*timerResponse, error) {
+	form := url.Values{"tag": {tag}}
+	if note != "" {
+		form.Set("note", note)
+	}
+	if countdown != 0 {
+		form.Set("countdown", countdown.String())
+	}
+
+	var timer timerResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/timer/start", form, &timer); err != nil {
+		return nil, err
+	}
+	return &timer, nil
+}
+
+func (c *client) tags(ctx context.Context) ([]string, error) {
+	var resp tagListResponse
+	if err := c.do(ctx, http.MethodGet, "/api/v1/tags", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+func (c *client) stats(ctx context.Context, start, end time.Time) (*models.StatsSummary, error) {
+	query := url.Values{
+		"start": {start.Format(time.RFC3339)},
+		"end":   {end.Format(time.RFC3339)},
+	}
+
+	var summary models.StatsSummary
+	if err := c.do(ctx, http.MethodGet, "/api/v1/stats/summary?"+query.Encode(), nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (c *client) startDeviceLogin(ctx context.Context) (*deviceCodeResponse, error) {
+	var resp deviceCodeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/code", url.Values{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// deviceToken polls once for the token of an approved device login
+func (c *client) deviceToken(ctx context.Context, deviceCode string) (string, error) {
+	var resp deviceTokenResponse
+	if err := c.do(ctx, http.MethodPost, "/api/v1/device/token", url.Values{"device_code": {deviceCode}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.AccessToken, nil
+}
+
+// revokeSession logs the token out on the server
+func (c *client) revokeSession(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/sessions/current", nil, nil)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultServer = "http://localhost:8080"
+
+// config is what ptimer remembers between runs
+type config struct {
+	Server string `json:"server,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// configPath returns ~/.config/ptimer/config.json or the platform equivalent
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ptimer", "config.json"), nil
+}
+
+// loadConfig reads the saved config, returning an empty one if there is none
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// save writes the config readable only by the current user, since it holds
+// the API token
+func (cfg *config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,370 @@
+// Command ptimer drives the productivity timer from the terminal.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+const usage = `Usage: ptimer [--server URL] <command> [arguments]
+
+Commands:
+  login [--token TOKEN]            Log in through the browser, or save an existing API token
+  logout                           Revoke the API token and forget it
+  start <tag> [note]               Start a timer for tag, noting what it is for
+  start --countdown 25m <tag>      Start a timer that stops by itself after 25 minutes
+  stop [note]                      Stop the running timer, replacing its note
+  note <note>                      Replace the active timer's note
+  continue                         Resume the stopped timer
+  reset                            Save the stopped timer's session and start over
+  status                           Show the active timer
+  tags                             List your tags
+  stats [--period day|week|month]  Show totals for the period so far (default day)
+
+The server defaults to PTIMER_SERVER, then the server used at login, then
+http://localhost:8080. PTIMER_TOKEN overrides the saved API token.
+`
+
+// app holds what every command needs
+type app struct {
+	cfg    *config
+	client *client
+	out    io.Writer
+	now    func() time.Time
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ptimer:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("ptimer", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	server := flags.String("server", "", "server URL")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no command given")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	serverURL := firstNonEmpty(*server, os.Getenv("PTIMER_SERVER"), cfg.Server, defaultServer)
+	token := firstNonEmpty(os.Getenv("PTIMER_TOKEN"), cfg.Token)
+
+	a := &app{
+		cfg:    cfg,
+		client: newClient(serverURL, token),
+		out:    out,
+		now:    time.Now,
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "login":
+		return a.login(ctx, commandArgs)
+	case "logout":
+		return a.logout(ctx)
+	case "start":
+		return a.start(ctx, commandArgs)
+	case "stop":
+		return a.stop(ctx, commandArgs)
+	case "note":
+		return a.note(ctx, commandArgs)
+	case "continue":
+		return a.resume(ctx)
+	case "reset":
+		return a.reset(ctx)
+	case "status":
+		return a.status(ctx)
+	case "tags":
+		return a.tags(ctx)
+	case "stats":
+		return a.stats(ctx, commandArgs)
+	case "help":
+		fmt.Fprint(out, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, run: ptimer help", command)
+	}
+}
+
+func (a *app) login(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	token := flags.String("token", "", "existing API token to save")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *token == "" {
+		var err error
+		if *token, err = a.deviceLogin(ctx); err != nil {
+			return err
+		}
+	}
+
+	a.cfg.Server = a.client.baseURL
+	a.cfg.Token = *token
+	if err := a.cfg.save(); err != nil {
+		return fmt.Errorf("saving token: %w", err)
+	}
+	fmt.Fprintf(a.out, "Logged in to %s\n", a.client.baseURL)
+	return nil
+}
+
+// deviceLogin has the user approve this login in the browser and waits for
+// the resulting API token
+func (a *app) deviceLogin(ctx context.Context) (string, error) {
+	grant, err := a.client.startDeviceLogin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(a.out, "Open %s and enter the code %s\n", grant.VerificationURI, grant.UserCode)
+	fmt.Fprintf(a.out, "or go straight to %s\n", grant.VerificationURIComplete)
+	fmt.Fprintln(a.out, "Waiting for approval...")
+
+	interval := time.Duration(grant.Interval) * time.Second
+	deadline := a.now().Add(time.Duration(grant.ExpiresIn) * time.Second)
+	for a.now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+
+		token, err := a.client.deviceToken(ctx, grant.DeviceCode)
+		if isAPIError(err, "authorization_pending") {
+			continue
+		}
+		return token, err
+	}
+	return "", errors.New("login was not approved in time")
+}
+
+func (a *app) logout(ctx context.Context) error {
+	// The token is forgotten even if the server cannot be reached; it can
+	// still be revoked under Devices in the web app
+	var revokeErr error
+	if a.client.token != "" {
+		if err := a.client.revokeSession(ctx); err != nil && !errors.Is(err, errNotLoggedIn) {
+			revokeErr = err
+		}
+	}
+
+	a.cfg.Token = ""
+	if err := a.cfg.save(); err != nil {
+		return err
+	}
+	if revokeErr != nil {
+		return fmt.Errorf("forgot the saved token, but could not revoke it (revoke it under Devices in the web app): %w", revokeErr)
+	}
+	fmt.Fprintln(a.out, "Logged out.")
+	return nil
+}
+
+func (a *app) start(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("start", flag.ContinueOnError)
+	countdown := flags.Duration("countdown", 0, "stop the timer by itself after this long, e.g. 25m")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) < 1 {
+		return errors.New("usage: ptimer start [--countdown 25m] <tag> [note]")
+	}
+
+	timer, err := a.client.startTimer(ctx, args[0], strings.Join(args[1:], " "), *countdown)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Started %s at %s\n", timer.Session.Tag, models.FormatDuration(timer.Duration))
+	if timer.Session.Countdown > 0 {
+		fmt.Fprintf(a.out, "Stops by itself at %s\n", timer.Session.DueAt.Local().Format("15:04:05"))
+	}
+	return nil
+}
+
+func (a *app) stop(ctx context.Context, args []string) error {
+	current, err := a.requireTimer(ctx, models.StatusRunning, "no timer is running")
+	if err != nil {
+		return err
+	}
+
+	timer, err := a.client.timerAction(ctx, "stop", current.Session.Tag, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Stopped %s at %s\n", timer.Session.Tag, models.FormatDuration(timer.Duration))
+	return nil
+}
+
+func (a *app) note(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: ptimer note <note>")
+	}
+	current, err := a.client.current(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return errors.New("no active timer to add a note to")
+	}
+
+	timer, err := a.client.timerAction(ctx, "note", current.Session.Tag, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Noted on %s: %s\n", timer.Session.Tag, timer.Session.Note)
+	return nil
+}
+
+func (a *app) resume(ctx context.Context) error {
+	current, err := a.requireTimer(ctx, models.StatusStopped, "no stopped timer to continue")
+	if err != nil {
+		return err
+	}
+
+	timer, err := a.client.timerAction(ctx, "start", current.Session.Tag, "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Continued %s from %s\n", timer.Session.Tag, models.FormatDuration(timer.Duration))
+	return nil
+}
+
+func (a *app) reset(ctx context.Context) error {
+	current, err := a.requireTimer(ctx, models.StatusStopped, "no stopped timer to reset, stop it first")
+	if err != nil {
+		return err
+	}
+
+	timer, err := a.client.timerAction(ctx, "reset", current.Session.Tag, "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.out, "Saved %s session of %s\n", timer.Session.Tag, models.FormatDuration(timer.Duration))
+	return nil
+}
+
+// requireTimer returns the active timer, failing with message unless it has
+// the given status
+func (a *app) requireTimer(ctx context.Context, status models.TimerStatus, message string) (*timerResponse, error) {
+	current, err := a.client.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.Session.Status != status {
+		return nil, errors.New(message)
+	}
+	return current, nil
+}
+
+func (a *app) status(ctx context.Context) error {
+	current, err := a.client.current(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		fmt.Fprintln(a.out, "No active timer")
+		return nil
+	}
+	fmt.Fprintf(a.out, "%s  %s  %s", models.FormatDuration(current.Duration), current.Session.Tag, current.Status)
+	if current.Session.Countdown > 0 && current.Status == string(models.StatusRunning) {
+		fmt.Fprintf(a.out, ", %s left", models.FormatDuration(max(current.Session.Countdown-current.Duration, 0)))
+	}
+	fmt.Fprintln(a.out)
+	return nil
+}
+
+func (a *app) tags(ctx context.Context) error {
+	tags, err := a.client.tags(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Fprintln(a.out, tag)
+	}
+	return nil
+}
+
+func (a *app) stats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	period := flags.String("period", "day", "day, week or month")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	now := a.now()
+	start, err := periodStart(*period, now)
+	if err != nil {
+		return err
+	}
+
+	summary, err := a.client.stats(ctx, start, now)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "Since %s\n", start.Format("Mon Jan 2"))
+	if len(summary.TagBreakdown) == 0 {
+		fmt.Fprintln(a.out, "No completed sessions")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tDURATION\tSESSIONS\tAVERAGE\tSHARE")
+	for _, tag := range summary.TagBreakdown {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%.1f%%\n", tag.Tag, models.FormatDuration(tag.TotalDuration),
+			tag.SessionCount, models.FormatDuration(tag.AverageSession), tag.PercentageOfTotal)
+	}
+	fmt.Fprintf(w, "TOTAL\t%s\t%d\t%s\t\n", models.FormatDuration(summary.TotalDuration),
+		summary.TotalSessions, models.FormatDuration(summary.AverageSession))
+	return w.Flush()
+}
+
+// periodStart returns local midnight at the start of now's day, ISO week
+// (starting Monday) or month
+func periodStart(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(period) {
+	case "day", string(models.PeriodDaily):
+		return today, nil
+	case "week", string(models.PeriodWeekly):
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -daysSinceMonday), nil
+	case "month", string(models.PeriodMonthly):
+		return today.AddDate(0, 0, 1-today.Day()), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown period %q, use day, week or month", period)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
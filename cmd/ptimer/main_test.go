@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestPeriodStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("loading location: %v", err)
+	}
+	// Wednesday afternoon
+	now := time.Date(2026, 3, 11, 15, 30, 0, 0, newYork)
+
+	tests := []struct {
+		period  string
+		now     time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{period: "day", now: now, want: time.Date(2026, 3, 11, 0, 0, 0, 0, newYork)},
+		{period: "week", now: now, want: time.Date(2026, 3, 9, 0, 0, 0, 0, newYork)},
+		{period: "weekly", now: now, want: time.Date(2026, 3, 9, 0, 0, 0, 0, newYork)},
+		{period: "week", now: time.Date(2026, 3, 15, 23, 0, 0, 0, newYork), want: time.Date(2026, 3, 9, 0, 0, 0, 0, newYork)},
+		{period: "week", now: time.Date(2026, 3, 9, 0, 0, 0, 0, newYork), want: time.Date(2026, 3, 9, 0, 0, 0, 0, newYork)},
+		{period: "month", now: now, want: time.Date(2026, 3, 1, 0, 0, 0, 0, newYork)},
+		{period: "year", now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period+" "+tt.now.Format(time.DateTime), func(t *testing.T) {
+			got, err := periodStart(tt.period, tt.now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("periodStart(%q) = %v, want error", tt.period, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("periodStart(%q) = %v, want %v", tt.period, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PTIMER_TOKEN", "secret")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"unauthorized","message":"User not authenticated"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/timer":
+			_, _ = w.Write([]byte(`{"session":{"tag":"coding","status":"running"},"duration":3725,"status":"running"}`))
+		case "/api/v1/timer/note":
+			_, _ = fmt.Fprintf(w, `{"session":{"tag":%q,"note":%q,"status":"running"},"duration":3725,"status":"running"}`,
+				r.PostFormValue("tag"), r.PostFormValue("note"))
+		case "/api/v1/tags":
+			_, _ = w.Write([]byte(`{"tags":["coding","reading"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not_found","message":"no stopped timer for this tag"}`))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{args: []string{"status"}, want: "01:02:05  coding  running\n"},
+		{args: []string{"tags"}, want: "coding\nreading\n"},
+		{args: []string{"note", "billing", "migration"}, want: "Noted on coding: billing migration\n"},
+		{args: []string{"continue"}, wantErr: "no stopped timer to continue"},
+		{args: []string{"start"}, wantErr: "usage: ptimer start [--countdown 25m] <tag> [note]"},
+		{args: []string{"frobnicate"}, wantErr: `unknown command "frobnicate"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			err := run(context.Background(), append([]string{"--server", srv.URL}, tt.args...), &out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PTIMER_TOKEN", "")
+
+	var revoked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/sessions/current" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		revoked = append(revoked, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	cfg.Token = "secret"
+	if err = cfg.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err = run(context.Background(), []string{"--server", srv.URL, "logout"}, &out); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != "Bearer secret" {
+		t.Errorf("revoked = %q, want the saved token revoked once", revoked)
+	}
+	if cfg, err = loadConfig(); err != nil || cfg.Token != "" {
+		t.Errorf("saved token = %q, %v; want it forgotten", cfg.Token, err)
+	}
+}
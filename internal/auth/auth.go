@@ -1,108 +1,292 @@
 package auth
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
+	"time"
 
 	"github.com/gorilla/sessions"
-	"github.com/joho/godotenv"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 	"github.com/markbates/goth/providers/google"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
 )
 
 const (
 	maxAge = 86400 * 30
+
+	sessionName     = "user-session"
+	sessionTokenKey = "token"
+
+	// touchInterval limits how often a session's last-seen time is written back
+	touchInterval = time.Minute
+
+	// CurrentSessionID stands for the requesting session in RevokeSession
+	CurrentSessionID = "current"
+)
+
+var (
+	ErrNoSession      = errors.New("no user in session")
+	ErrSessionExpired = errors.New("session expired")
+	// ErrSessionNotFound is returned when revoking a session the user does not own
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrInvalidCSRFToken = errors.New("invalid CSRF token")
 )
 
 type Service interface {
-	GetUserFromSession(r *http.Request) (*goth.User, error)
-	StoreUserInSession(w http.ResponseWriter, r *http.Request, user *goth.User) error
+	CurrentSession(r *http.Request) (*models.Session, error)
+	StoreUserInSession(w http.ResponseWriter, r *http.Request, user *models.User) error
 	ClearUserSession(w http.ResponseWriter, r *http.Request) error
+	ListUserSessions(r *http.Request) (sessions []*models.Session, currentID string, err error)
+	RevokeSession(r *http.Request, id string) error
+	RevokeOtherSessions(r *http.Request) error
+	CSRFToken(session *models.Session) string
+	VerifyCSRFToken(r *http.Request, session *models.Session, token string) error
+	StartDeviceAuthorization(ctx context.Context) (*DeviceGrant, error)
+	ApproveDevice(r *http.Request, userCode string) error
+	ExchangeDeviceCode(r *http.Request, deviceCode string) (string, error)
 }
 
-type service struct{}
-
-func NewAuth() Service {
-	// Load .env file if it exists (for local development)
-	// In production (Railway, etc.), env vars are set directly in the platform
-	_ = godotenv.Load()
-
-	googleClientId := os.Getenv("GOOGLE_KEY")
-	googleClientSecret := os.Getenv("GOOGLE_SECRET")
-	sessionSecret := os.Getenv("SESSION_SECRET")
-	baseURL := os.Getenv("BASE_URL") // e.g., "https://your-app.railway.app" or "http://localhost:8080"
-	appEnv := os.Getenv("APP_ENV")
-	isProd := appEnv == "production"
+type service struct {
+	store       SessionStore
+	clock       clock.Clock
+	idleTimeout time.Duration
+	csrfKey     []byte
+}
 
-	store := sessions.NewCookieStore([]byte(sessionSecret))
-	store.MaxAge(maxAge)
-	store.Options.Path = "/"
-	store.Options.HttpOnly = true
-	store.Options.Secure = isProd
-	store.Options.SameSite = http.SameSiteLaxMode
+func NewAuth(cfg *config.Config, store SessionStore, clk clock.Clock) Service {
+	cookieStore := sessions.NewCookieStore([]byte(cfg.Session.Secret))
+	cookieStore.MaxAge(maxAge)
+	cookieStore.Options.Path = "/"
+	cookieStore.Options.HttpOnly = true
+	cookieStore.Options.Secure = cfg.IsProduction()
+	cookieStore.Options.SameSite = http.SameSiteLaxMode
 
-	gothic.Store = store
+	gothic.Store = cookieStore
 
-	callbackURL := fmt.Sprintf("%s/auth/google/callback", baseURL)
-	goth.UseProviders(google.New(googleClientId, googleClientSecret, callbackURL))
+	callbackURL := fmt.Sprintf("%s/auth/google/callback", cfg.BaseURL)
+	goth.UseProviders(google.New(cfg.Google.ClientID, cfg.Google.ClientSecret, callbackURL))
 
-	return &service{}
+	return &service{
+		store:       store,
+		clock:       clk,
+		idleTimeout: cfg.Session.IdleTimeout,
+		csrfKey:     []byte(cfg.Session.Secret),
+	}
 }
 
-func (s *service) StoreUserInSession(w http.ResponseWriter, r *http.Request, user *goth.User) error {
-	session, err := gothic.Store.Get(r, "user-session")
+// StoreUserInSession starts a new server-side session for the user. Any
+// session referenced by the current cookie is revoked first, so the session
+// token always rotates on login.
+func (s *service) StoreUserInSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	cookieSession, err := gothic.Store.Get(r, sessionName)
 	if err != nil {
 		return err
 	}
 
-	// Store the marshaled user data
-	userJSON, err := json.Marshal(user)
+	if token, ok := cookieSession.Values[sessionTokenKey].(string); ok && token != "" {
+		if err = s.store.DeleteSession(r.Context(), hashToken(token)); err != nil {
+			return err
+		}
+	}
+
+	token, err := newSessionToken()
 	if err != nil {
 		return err
 	}
 
-	session.Values["user"] = string(userJSON)
-	return session.Save(r, w)
+	session := models.NewSession(hashToken(token), user, r.UserAgent(), clientIP(r), s.clock.Now(), maxAge*time.Second)
+	if err = s.store.CreateSession(r.Context(), session); err != nil {
+		return err
+	}
+
+	// Drop the user JSON stored by cookie-only sessions
+	delete(cookieSession.Values, "user")
+	cookieSession.Values[sessionTokenKey] = token
+	return cookieSession.Save(r, w)
 }
 
 func (s *service) ClearUserSession(w http.ResponseWriter, r *http.Request) error {
-	session, err := gothic.Store.Get(r, "user-session")
+	cookieSession, err := gothic.Store.Get(r, sessionName)
 	if err != nil {
 		return err
 	}
 
+	if token, ok := cookieSession.Values[sessionTokenKey].(string); ok && token != "" {
+		if err = s.store.DeleteSession(r.Context(), hashToken(token)); err != nil {
+			return err
+		}
+	}
+
 	// Clear the session by setting MaxAge to -1
-	session.Options.MaxAge = -1
-	delete(session.Values, "user")
+	cookieSession.Options.MaxAge = -1
+	delete(cookieSession.Values, sessionTokenKey)
+	delete(cookieSession.Values, "user")
+
+	return cookieSession.Save(r, w)
+}
 
-	return session.Save(r, w)
+// CurrentSession returns the live session of the request's bearer or cookie
+// token. Each call looks it up again, so callers keep the result for the
+// rest of the request.
+func (s *service) CurrentSession(r *http.Request) (*models.Session, error) {
+	return s.currentSession(r)
 }
 
-func (s *service) GetUserFromSession(r *http.Request) (*goth.User, error) {
-	// Get the session
-	session, err := gothic.Store.Get(r, "user-session")
+// ListUserSessions returns every active session of the current user along
+// with the ID of the session making the request
+func (s *service) ListUserSessions(r *http.Request) ([]*models.Session, string, error) {
+	current, err := s.currentSession(r)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	userSessions, err := s.store.FindUserSessions(r.Context(), current.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	return userSessions, current.ID, nil
+}
+
+// RevokeSession deletes one of the current user's sessions. CurrentSessionID
+// names the session making the request, which lets API clients log out.
+func (s *service) RevokeSession(r *http.Request, id string) error {
+	current, err := s.currentSession(r)
+	if err != nil {
+		return err
+	}
+	if id == CurrentSessionID {
+		return s.store.DeleteSession(r.Context(), current.ID)
 	}
 
-	userValue := session.Values["user"]
-	if userValue == nil {
-		return nil, fmt.Errorf("no user in session")
+	target, err := s.store.FindSession(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	if target == nil || target.UserID != current.UserID {
+		return ErrSessionNotFound
 	}
+	return s.store.DeleteSession(r.Context(), id)
+}
 
-	// Unmarshal the user data
-	userString, ok := userValue.(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid session data")
+// RevokeOtherSessions logs the current user out of every other device
+func (s *service) RevokeOtherSessions(r *http.Request) error {
+	current, err := s.currentSession(r)
+	if err != nil {
+		return err
 	}
+	return s.store.DeleteUserSessions(r.Context(), current.UserID, current.ID)
+}
 
-	var user goth.User
-	if err = json.Unmarshal([]byte(userString), &user); err != nil {
+// CSRFToken returns the anti-forgery token for session. It is derived from
+// the session ID, so it changes whenever the session rotates.
+func (s *service) CSRFToken(session *models.Session) string {
+	return s.csrfTokenFor(session.ID)
+}
+
+// VerifyCSRFToken checks token against the CSRF token of session, the
+// request's current session. Requests authenticated with a bearer token need
+// none: browsers never attach that header on their own, so it cannot be
+// forged cross-site.
+func (s *service) VerifyCSRFToken(r *http.Request, session *models.Session, token string) error {
+	if _, ok := bearerToken(r); ok {
+		return nil
+	}
+	if !hmac.Equal([]byte(token), []byte(s.csrfTokenFor(session.ID))) {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+func (s *service) csrfTokenFor(sessionID string) string {
+	mac := hmac.New(sha256.New, s.csrfKey)
+	mac.Write([]byte("csrf:" + sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// currentSession resolves the request's bearer or cookie token to a live
+// server-side session, deleting it if it has expired or been idle for too long
+func (s *service) currentSession(r *http.Request) (*models.Session, error) {
+	token, err := requestToken(r)
+	if err != nil {
 		return nil, err
 	}
 
-	return &user, nil
+	session, err := s.store.FindSession(r.Context(), hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrNoSession
+	}
+
+	now := s.clock.Now()
+	if now.After(session.ExpiresAt) || now.Sub(session.LastSeenAt) > s.idleTimeout {
+		if err = s.store.DeleteSession(r.Context(), session.ID); err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting expired session", "err", err)
+		}
+		return nil, ErrSessionExpired
+	}
+
+	if now.Sub(session.LastSeenAt) > touchInterval {
+		if err = s.store.TouchSession(r.Context(), session.ID, now); err != nil {
+			slog.ErrorContext(r.Context(), "Error updating session last seen time", "err", err)
+		}
+		session.LastSeenAt = now
+	}
+
+	return session, nil
+}
+
+// requestToken returns the session token from the Authorization header, used
+// by the command-line client, or else from the session cookie
+func requestToken(r *http.Request) (string, error) {
+	if token, ok := bearerToken(r); ok {
+		return token, nil
+	}
+
+	// The cookie store only fails to decode tampered or stale cookies
+	cookieSession, err := gothic.Store.Get(r, sessionName)
+	if err != nil {
+		return "", ErrNoSession
+	}
+
+	token, ok := cookieSession.Values[sessionTokenKey].(string)
+	if !ok || token == "" {
+		return "", ErrNoSession
+	}
+	return token, nil
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+const (
+	// deviceCodeTTL is how long a command-line login waits for approval
+	deviceCodeTTL = 10 * time.Minute
+	// DevicePollInterval is how often clients should poll for their token
+	DevicePollInterval = 5 * time.Second
+
+	// userCodeAlphabet leaves out vowels and look-alike characters so codes
+	// are easy to read aloud and cannot spell words
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+var (
+	// ErrAuthorizationPending is returned while a device login awaits approval
+	ErrAuthorizationPending = errors.New("authorization pending")
+	// ErrInvalidDeviceCode is returned for unknown or expired device logins
+	ErrInvalidDeviceCode = errors.New("device code not found or expired")
+)
+
+// DeviceGrant is a started command-line login. DeviceCode is secret to the
+// client; UserCode is shown to the user to enter in the browser.
+type DeviceGrant struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresAt  time.Time
+}
+
+// StartDeviceAuthorization begins a command-line login
+func (s *service) StartDeviceAuthorization(ctx context.Context) (*DeviceGrant, error) {
+	deviceCode, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	authorization := models.NewDeviceAuthorization(hashToken(deviceCode), userCode, s.clock.Now(), deviceCodeTTL)
+	if err = s.store.CreateDeviceAuthorization(ctx, authorization); err != nil {
+		return nil, err
+	}
+
+	return &DeviceGrant{
+		DeviceCode: deviceCode,
+		UserCode:   formatUserCode(userCode),
+		ExpiresAt:  authorization.ExpiresAt,
+	}, nil
+}
+
+// ApproveDevice lets the browser session's user approve the command-line
+// login showing userCode
+func (s *service) ApproveDevice(r *http.Request, userCode string) error {
+	current, err := s.currentSession(r)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := s.store.FindDeviceAuthorizationByUserCode(r.Context(), normalizeUserCode(userCode))
+	if err != nil {
+		return err
+	}
+	if authorization == nil || authorization.Approved() {
+		return ErrInvalidDeviceCode
+	}
+	return s.store.ApproveDeviceAuthorization(r.Context(), authorization.ID, &current.User)
+}
+
+// ExchangeDeviceCode trades an approved device code for an API token. The
+// token names a regular server-side session, so it can be revoked from the
+// devices page like any browser login.
+func (s *service) ExchangeDeviceCode(r *http.Request, deviceCode string) (string, error) {
+	ctx := r.Context()
+
+	authorization, err := s.store.FindDeviceAuthorization(ctx, hashToken(deviceCode))
+	if err != nil {
+		return "", err
+	}
+	if authorization == nil {
+		return "", ErrInvalidDeviceCode
+	}
+
+	now := s.clock.Now()
+	if now.After(authorization.ExpiresAt) {
+		if err = s.store.DeleteDeviceAuthorization(ctx, authorization.ID); err != nil {
+			return "", err
+		}
+		return "", ErrInvalidDeviceCode
+	}
+	if !authorization.Approved() {
+		return "", ErrAuthorizationPending
+	}
+
+	// Device codes are single use: of several polls racing here only one
+	// claims the authorization
+	authorization, err = s.store.ClaimDeviceAuthorization(ctx, authorization.ID)
+	if err != nil {
+		return "", err
+	}
+	if authorization == nil {
+		return "", ErrInvalidDeviceCode
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+	session := models.NewSession(hashToken(token), authorization.User, r.UserAgent(), clientIP(r), now, maxAge*time.Second)
+	if err = s.store.CreateSession(ctx, session); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// bearerToken returns the API token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func newUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// formatUserCode splits a user code in two halves for display, e.g. BCDF-GHJK
+func formatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts codes typed in lower case, with or without the dash
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func newTestService(t *testing.T) (*service, *clock.Fake) {
+	t.Helper()
+	cfg := &config.Config{
+		Env:     config.EnvDevelopment,
+		BaseURL: "http://localhost:8080",
+		Session: config.Session{Secret: "test-session-secret", IdleTimeout: 24 * time.Hour},
+	}
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	return NewAuth(cfg, NewMemorySessionStore(clk), clk).(*service), clk
+}
+
+// browserRequest returns a request carrying the cookie of a fresh login
+func browserRequest(t *testing.T, s *service, user *models.User) *http.Request {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := s.StoreUserInSession(rec, httptest.NewRequest(http.MethodGet, "/auth/google/callback", nil), user); err != nil {
+		t.Fatalf("StoreUserInSession: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/device", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/timer/start", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestDeviceFlow(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	user := &models.User{ID: "user-1", Email: "ada@example.com"}
+
+	grant, err := s.StartDeviceAuthorization(ctx)
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	if len(grant.UserCode) != userCodeLength+1 || grant.UserCode[4] != '-' {
+		t.Errorf("user code = %q, want XXXX-XXXX", grant.UserCode)
+	}
+
+	tokenRequest := httptest.NewRequest(http.MethodPost, "/api/v1/device/token", nil)
+	if _, err = s.ExchangeDeviceCode(tokenRequest, grant.DeviceCode); !errors.Is(err, ErrAuthorizationPending) {
+		t.Fatalf("exchange before approval: err = %v, want ErrAuthorizationPending", err)
+	}
+
+	anonymous := httptest.NewRequest(http.MethodPost, "/device", nil)
+	if err = s.ApproveDevice(anonymous, grant.UserCode); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("approve without login: err = %v, want ErrNoSession", err)
+	}
+
+	browser := browserRequest(t, s, user)
+	if err = s.ApproveDevice(browser, "ZZZZ-ZZZZ"); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Fatalf("approve unknown code: err = %v, want ErrInvalidDeviceCode", err)
+	}
+	// Codes are accepted however the user types them
+	if err = s.ApproveDevice(browser, strings.ToLower(strings.ReplaceAll(grant.UserCode, "-", " "))); err != nil {
+		t.Fatalf("ApproveDevice: %v", err)
+	}
+
+	token, err := s.ExchangeDeviceCode(tokenRequest, grant.DeviceCode)
+	if err != nil {
+		t.Fatalf("ExchangeDeviceCode: %v", err)
+	}
+	if _, err = s.ExchangeDeviceCode(tokenRequest, grant.DeviceCode); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("second exchange: err = %v, want ErrInvalidDeviceCode", err)
+	}
+
+	got, err := s.CurrentSession(bearerRequest(token))
+	if err != nil {
+		t.Fatalf("CurrentSession with bearer token: %v", err)
+	}
+	if got.User.ID != user.ID {
+		t.Errorf("bearer user = %s, want %s", got.User.ID, user.ID)
+	}
+	if _, err = s.CurrentSession(bearerRequest("forged")); !errors.Is(err, ErrNoSession) {
+		t.Errorf("CurrentSession with unknown bearer token: err = %v, want ErrNoSession", err)
+	}
+
+	// Bearer requests cannot be forged cross-site, so need no CSRF token
+	if err = s.VerifyCSRFToken(bearerRequest(token), got, ""); err != nil {
+		t.Errorf("VerifyCSRFToken with bearer token: %v", err)
+	}
+	browserSession, err := s.CurrentSession(browser)
+	if err != nil {
+		t.Fatalf("CurrentSession with cookie: %v", err)
+	}
+	if err = s.VerifyCSRFToken(browser, browserSession, ""); !errors.Is(err, ErrInvalidCSRFToken) {
+		t.Errorf("VerifyCSRFToken with cookie and no token: err = %v, want ErrInvalidCSRFToken", err)
+	}
+	if err = s.VerifyCSRFToken(browser, browserSession, s.CSRFToken(browserSession)); err != nil {
+		t.Errorf("VerifyCSRFToken with cookie and its token: %v", err)
+	}
+
+	// The token is a regular session the browser can revoke
+	sessions, _, err := s.ListUserSessions(browser)
+	if err != nil {
+		t.Fatalf("ListUserSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %d, want browser and CLI", len(sessions))
+	}
+	if err = s.RevokeOtherSessions(browser); err != nil {
+		t.Fatalf("RevokeOtherSessions: %v", err)
+	}
+	if _, err = s.CurrentSession(bearerRequest(token)); !errors.Is(err, ErrNoSession) {
+		t.Errorf("revoked bearer token: err = %v, want ErrNoSession", err)
+	}
+}
+
+func TestDeviceCodeSingleUse(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+	user := &models.User{ID: "user-1"}
+
+	grant, err := s.StartDeviceAuthorization(ctx)
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	if err = s.ApproveDevice(browserRequest(t, s, user), grant.UserCode); err != nil {
+		t.Fatalf("ApproveDevice: %v", err)
+	}
+
+	// Of several polls racing for the approved code, exactly one gets a token
+	var wg sync.WaitGroup
+	tokens := make(chan string, 8)
+	for range cap(tokens) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := s.ExchangeDeviceCode(httptest.NewRequest(http.MethodPost, "/api/v1/device/token", nil), grant.DeviceCode)
+			if err == nil {
+				tokens <- token
+			} else if !errors.Is(err, ErrInvalidDeviceCode) {
+				t.Errorf("ExchangeDeviceCode: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(tokens)
+	if len(tokens) != 1 {
+		t.Fatalf("%d polls got a token, want 1", len(tokens))
+	}
+
+	// The client logs its own token out
+	token := <-tokens
+	if err = s.RevokeSession(bearerRequest(token), CurrentSessionID); err != nil {
+		t.Fatalf("RevokeSession(current): %v", err)
+	}
+	if _, err = s.CurrentSession(bearerRequest(token)); !errors.Is(err, ErrNoSession) {
+		t.Errorf("logged out bearer token: err = %v, want ErrNoSession", err)
+	}
+}
+
+func TestDeviceCodeExpires(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestService(t)
+	user := &models.User{ID: "user-1"}
+
+	grant, err := s.StartDeviceAuthorization(ctx)
+	if err != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", err)
+	}
+	clk.Advance(deviceCodeTTL + time.Second)
+
+	if err = s.ApproveDevice(browserRequest(t, s, user), grant.UserCode); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("approve expired code: err = %v, want ErrInvalidDeviceCode", err)
+	}
+	tokenRequest := httptest.NewRequest(http.MethodPost, "/api/v1/device/token", nil)
+	if _, err = s.ExchangeDeviceCode(tokenRequest, grant.DeviceCode); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("exchange expired code: err = %v, want ErrInvalidDeviceCode", err)
+	}
+}
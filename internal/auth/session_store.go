@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+// SessionStore persists server-side login sessions and pending device logins.
+// database.Service satisfies it for Mongo; NewMemorySessionStore is meant for
+// development.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	FindSession(ctx context.Context, id string) (*models.Session, error)
+	TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteUserSessions(ctx context.Context, userId, exceptID string) error
+	FindUserSessions(ctx context.Context, userId string) ([]*models.Session, error)
+	CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error
+	FindDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error)
+	FindDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*models.DeviceAuthorization, error)
+	ApproveDeviceAuthorization(ctx context.Context, id string, user *models.User) error
+	DeleteDeviceAuthorization(ctx context.Context, id string) error
+	ClaimDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error)
+}
+
+// NewMemorySessionStore returns a SessionStore that keeps sessions in process
+// memory, using the in-memory database's session methods. Sessions are lost
+// on restart and not shared between instances.
+func NewMemorySessionStore(clk clock.Clock) SessionStore {
+	return database.NewMemory(clk)
+}
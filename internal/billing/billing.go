@@ -0,0 +1,183 @@
+// Package billing turns tracked time into billable amounts using the hourly
+// rates set on tags.
+package billing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+// Rounding modes
+const (
+	RoundUp      = "up"
+	RoundNearest = "nearest"
+	RoundDown    = "down"
+)
+
+// Rounding rounds each session's duration to a multiple of Increment before
+// it is billed. The zero value bills exact durations.
+type Rounding struct {
+	Increment time.Duration
+	Mode      string
+}
+
+// ParseRounding reads an increment in whole minutes ("15"; empty or "0" for
+// none) and a mode ("up", "nearest" or "down"; empty for up)
+func ParseRounding(minutes, mode string) (Rounding, error) {
+	minutes = strings.TrimSpace(minutes)
+	if minutes == "" || minutes == "0" {
+		return Rounding{}, nil
+	}
+	n, err := strconv.Atoi(minutes)
+	if err != nil || n < 0 || n > 24*60 {
+		return Rounding{}, fmt.Errorf("%w: round must be a number of minutes up to 1440", models.ErrValidation)
+	}
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		mode = RoundUp
+	case RoundUp, RoundNearest, RoundDown:
+	default:
+		return Rounding{}, fmt.Errorf("%w: rounding must be up, nearest or down", models.ErrValidation)
+	}
+	return Rounding{Increment: time.Duration(n) * time.Minute, Mode: mode}, nil
+}
+
+// Apply rounds a duration in seconds
+func (r Rounding) Apply(seconds int64) int64 {
+	increment := int64(r.Increment / time.Second)
+	if increment <= 0 || seconds <= 0 {
+		return seconds
+	}
+	switch r.Mode {
+	case RoundDown:
+		return seconds / increment * increment
+	case RoundNearest:
+		return (seconds + increment/2) / increment * increment
+	default:
+		return (seconds + increment - 1) / increment * increment
+	}
+}
+
+func (r Rounding) String() string {
+	if r.Increment <= 0 {
+		return "exact durations"
+	}
+	return fmt.Sprintf("each session rounded %s to %d minutes", r.Mode, int(r.Increment/time.Minute))
+}
+
+// Rate returns the hourly rate in cents that applies to tag: that of the
+// nearest billable tag among tag and its ancestors, so "client-a/backend" is
+// billed at "client-a"'s rate unless it has a billable rate of its own. ok
+// is false when none of them is billable.
+func Rate(tag string, settings map[string]models.TagSettings) (cents int64, ok bool) {
+	for ; tag != ""; tag = models.ParentTag(tag) {
+		if s := settings[tag]; s.Billable {
+			return s.HourlyRateCents, true
+		}
+	}
+	return 0, false
+}
+
+// Line is the billable time of one tag
+type Line struct {
+	Tag            string `json:"tag"`
+	Client         string `json:"client"` // The tag's top level
+	Sessions       int    `json:"sessions"`
+	TrackedSeconds int64  `json:"trackedSeconds"`
+	BilledSeconds  int64  `json:"billedSeconds"` // After rounding
+	RateCents      int64  `json:"rateCents"`     // Per hour
+	AmountCents    int64  `json:"amountCents"`
+}
+
+// ClientTotal sums the lines of one client
+type ClientTotal struct {
+	Client         string `json:"client"`
+	TrackedSeconds int64  `json:"trackedSeconds"`
+	BilledSeconds  int64  `json:"billedSeconds"`
+	AmountCents    int64  `json:"amountCents"`
+}
+
+// Report is the billable time within a period
+type Report struct {
+	Start          time.Time     `json:"start"`
+	End            time.Time     `json:"end"`
+	Rounding       string        `json:"rounding"`
+	Lines          []Line        `json:"lines"`
+	Clients        []ClientTotal `json:"clients"`
+	TrackedSeconds int64         `json:"trackedSeconds"`
+	BilledSeconds  int64         `json:"billedSeconds"`
+	AmountCents    int64         `json:"amountCents"`
+}
+
+// Generate bills the sessions on billable tags. Each session is rounded on
+// its own; amounts are worked out per tag and rounded to the nearest cent.
+func Generate(sessions []*models.TimerSession, settings map[string]models.TagSettings, rounding Rounding, start, end time.Time) *Report {
+	report := &Report{Start: start, End: end, Rounding: rounding.String(), Lines: []Line{}, Clients: []ClientTotal{}}
+
+	byTag := make(map[string]*Line)
+	for _, session := range sessions {
+		rate, ok := Rate(session.Tag, settings)
+		if !ok {
+			continue
+		}
+		line, ok := byTag[session.Tag]
+		if !ok {
+			line = &Line{Tag: session.Tag, Client: client(session.Tag), RateCents: rate}
+			byTag[session.Tag] = line
+		}
+		line.Sessions++
+		line.TrackedSeconds += session.Duration
+		line.BilledSeconds += rounding.Apply(session.Duration)
+	}
+
+	for _, line := range byTag {
+		line.AmountCents = (line.BilledSeconds*line.RateCents + 1800) / 3600
+		report.Lines = append(report.Lines, *line)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		if report.Lines[i].Client != report.Lines[j].Client {
+			return report.Lines[i].Client < report.Lines[j].Client
+		}
+		return report.Lines[i].Tag < report.Lines[j].Tag
+	})
+
+	for _, line := range report.Lines {
+		if n := len(report.Clients); n == 0 || report.Clients[n-1].Client != line.Client {
+			report.Clients = append(report.Clients, ClientTotal{Client: line.Client})
+		}
+		total := &report.Clients[len(report.Clients)-1]
+		total.TrackedSeconds += line.TrackedSeconds
+		total.BilledSeconds += line.BilledSeconds
+		total.AmountCents += line.AmountCents
+
+		report.TrackedSeconds += line.TrackedSeconds
+		report.BilledSeconds += line.BilledSeconds
+		report.AmountCents += line.AmountCents
+	}
+	return report
+}
+
+// client returns the top level of tag
+func client(tag string) string {
+	top, _, _ := strings.Cut(tag, models.TagSeparator)
+	return top
+}
+
+// FormatAmount formats cents as a decimal amount such as "1234.50"
+func FormatAmount(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// FormatHours formats seconds as decimal hours such as "1.25"
+func FormatHours(seconds int64) string {
+	return strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64)
+}
@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		minutes, mode string
+		seconds, want int64
+	}{
+		{"", "", 125, 125},
+		{"15", "", 60, 900},
+		{"15", "up", 900, 900},
+		{"15", "up", 901, 1800},
+		{"15", "nearest", 449, 0},
+		{"15", "nearest", 450, 900},
+		{"15", "down", 1799, 900},
+		{"15", "up", 0, 0},
+	}
+	for _, tt := range tests {
+		rounding, err := ParseRounding(tt.minutes, tt.mode)
+		if err != nil {
+			t.Fatalf("ParseRounding(%q, %q): %v", tt.minutes, tt.mode, err)
+		}
+		if got := rounding.Apply(tt.seconds); got != tt.want {
+			t.Errorf("%s: Apply(%d) = %d, want %d", rounding, tt.seconds, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"quarter", ""}, {"-5", ""}, {"15", "sideways"}} {
+		if _, err := ParseRounding(bad[0], bad[1]); !errors.Is(err, models.ErrValidation) {
+			t.Errorf("ParseRounding(%q, %q) err = %v, want ErrValidation", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	settings := map[string]models.TagSettings{
+		"client-a":          {Billable: true, HourlyRateCents: 10000},
+		"client-a/meetings": {Billable: true, HourlyRateCents: 5000},
+		"client-b":          {Billable: true, HourlyRateCents: 8550},
+		"reading":           {Color: "#2196f3"},
+	}
+	session := func(tag string, seconds int64) *models.TimerSession {
+		return &models.TimerSession{Tag: tag, Duration: seconds}
+	}
+	sessions := []*models.TimerSession{
+		session("client-a/backend", 50*60),
+		session("client-a/backend", 5*60),
+		session("client-a/meetings", 20*60),
+		session("client-b", 60*60),
+		session("reading", 60*60),
+		session("personal", 60*60),
+	}
+	rounding, _ := ParseRounding("15", "up")
+	report := Generate(sessions, settings, rounding, time.Time{}, time.Time{})
+
+	want := []Line{
+		{Tag: "client-a/backend", Client: "client-a", Sessions: 2, TrackedSeconds: 3300, BilledSeconds: 4500, RateCents: 10000, AmountCents: 12500},
+		{Tag: "client-a/meetings", Client: "client-a", Sessions: 1, TrackedSeconds: 1200, BilledSeconds: 1800, RateCents: 5000, AmountCents: 2500},
+		{Tag: "client-b", Client: "client-b", Sessions: 1, TrackedSeconds: 3600, BilledSeconds: 3600, RateCents: 8550, AmountCents: 8550},
+	}
+	if len(report.Lines) != len(want) {
+		t.Fatalf("lines = %+v, want %+v", report.Lines, want)
+	}
+	for i := range want {
+		if report.Lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, report.Lines[i], want[i])
+		}
+	}
+	if len(report.Clients) != 2 || report.Clients[0].AmountCents != 15000 || report.Clients[1].AmountCents != 8550 {
+		t.Errorf("clients = %+v", report.Clients)
+	}
+	if report.AmountCents != 23550 || report.BilledSeconds != 9900 || report.TrackedSeconds != 8100 {
+		t.Errorf("totals = %d cents, %ds billed, %ds tracked", report.AmountCents, report.BilledSeconds, report.TrackedSeconds)
+	}
+
+	var csv strings.Builder
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	for _, row := range []string{
+		"client,tag,sessions,tracked_hours,billed_hours,hourly_rate,amount\n",
+		"client-a,client-a/backend,2,0.92,1.25,100.00,125.00\n",
+		"TOTAL,,,2.25,2.75,,235.50\n",
+	} {
+		if !strings.Contains(csv.String(), row) {
+			t.Errorf("CSV does not contain %q:\n%s", row, csv.String())
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package billing
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteCSV writes one row per line and a closing total. Hours and amounts
+// are decimals, so spreadsheets can sum them.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	rows := [][]string{{"client", "tag", "sessions", "tracked_hours", "billed_hours", "hourly_rate", "amount"}}
+	for _, line := range r.Lines {
+		rows = append(rows, []string{
+			spreadsheetSafe(line.Client),
+			spreadsheetSafe(line.Tag),
+			strconv.Itoa(line.Sessions),
+			FormatHours(line.TrackedSeconds),
+			FormatHours(line.BilledSeconds),
+			FormatAmount(line.RateCents),
+			FormatAmount(line.AmountCents),
+		})
+	}
+	rows = append(rows, []string{"TOTAL", "", "", FormatHours(r.TrackedSeconds), FormatHours(r.BilledSeconds), "", FormatAmount(r.AmountCents)})
+
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// spreadsheetSafe stops spreadsheets reading a tag such as "=1+1" as a formula
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
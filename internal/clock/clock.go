@@ -0,0 +1,53 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Code that needs "now" takes a Clock instead of
+// calling time.Now so tests can control time.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+// New returns a Clock backed by the system time
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a Clock that only moves when told to. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to t
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -0,0 +1,467 @@
+// Package config loads and validates the application's settings.
+package config
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/neilsmahajan/productivity-timer/internal/logging"
+	"github.com/neilsmahajan/productivity-timer/internal/ratelimit"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	SessionStoreMongo  = "mongo"
+	SessionStoreMemory = "memory"
+
+	defaultPort = 8080
+	// defaultIdleTimeout logs a session out after this long without a request
+	defaultIdleTimeout = 7 * 24 * time.Hour
+	// minSecretLength is the shortest SESSION_SECRET accepted in production
+	minSecretLength = 32
+	// exampleSecret is the placeholder shipped in .env.example
+	exampleSecret = "your-session-secret-here"
+
+	// Default request budgets: per user for the API, per IP address for the
+	// login routes
+	defaultAPIRate   = "300/m"
+	defaultAPIBurst  = 30
+	defaultAuthRate  = "30/m"
+	defaultAuthBurst = 10
+
+	// defaultLongTimerAlert is how long a timer runs without a break before
+	// its user is notified
+	defaultLongTimerAlert = 4 * time.Hour
+)
+
+type Config struct {
+	Env     string
+	Port    int
+	BaseURL string
+	// AllowedOrigins may make credentialed cross-origin requests
+	AllowedOrigins []string
+	// TrustedProxies may set X-Forwarded-For; when empty no peer may
+	TrustedProxies []string
+	Database       Database
+	Session        Session
+	Google         Google
+	Log            Log
+	RateLimit      RateLimit
+	Push           Push
+	Webhooks       Webhooks
+	Metrics        Metrics
+}
+
+type Database struct {
+	// URI is a full connection string, e.g. for MongoDB Atlas. When empty
+	// one is built from Host, Port, Username and Password.
+	URI      string
+	Host     string
+	Port     string
+	Name     string
+	Username string
+	Password string
+}
+
+type Session struct {
+	Secret      string
+	Store       string
+	IdleTimeout time.Duration
+}
+
+type Log struct {
+	// Format is "text" or "json"
+	Format string
+	Level  slog.Level
+}
+
+// RateLimit holds the token buckets requests are limited by. A zero Limit
+// turns limiting off.
+type RateLimit struct {
+	// API is applied per user to the authenticated API
+	API ratelimit.Limit
+	// Auth is applied per IP address to the login routes
+	Auth ratelimit.Limit
+}
+
+// Push holds the VAPID keys Web Push notifications are signed with. Push is
+// off without them.
+type Push struct {
+	PublicKey  string
+	PrivateKey string
+	// Subject is a mailto: or https: URL push services can reach the
+	// operator at
+	Subject string
+	// LongTimerAlert notifies users whose timer has run this long without a
+	// break; zero turns it off
+	LongTimerAlert time.Duration
+}
+
+// Enabled reports whether push notifications can be sent
+func (p Push) Enabled() bool {
+	return p.PrivateKey != ""
+}
+
+type Webhooks struct {
+	// AllowPrivateNetworks lets webhooks and push subscriptions reach
+	// loopback and private addresses, e.g. tools on the same network as a
+	// self-hosted server
+	AllowPrivateNetworks bool
+}
+
+type Metrics struct {
+	// Token must be sent as a bearer token to scrape /metrics; without one
+	// /metrics is not served
+	Token string
+}
+
+type Google struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// IsProduction reports whether the app runs with production settings
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// ConnectionURI returns the MongoDB connection string
+func (d Database) ConnectionURI() string {
+	if d.URI != "" {
+		return d.URI
+	}
+	if d.Username != "" && d.Password != "" {
+		return fmt.Sprintf("mongodb://%s:%s@%s:%s", url.QueryEscape(d.Username), url.QueryEscape(d.Password), d.Host, d.Port)
+	}
+	return fmt.Sprintf("mongodb://%s:%s", d.Host, d.Port)
+}
+
+// Load reads the configuration from the environment. Variables that are not
+// set fall back to the file named by CONFIG_FILE, then to .env; both use the
+// .env format and are optional.
+func Load() (*Config, error) {
+	if err := loadFiles(); err != nil {
+		return nil, err
+	}
+	return parse(os.Getenv)
+}
+
+// LoadDatabase reads only the database settings, from the same sources as
+// Load, for tools that need nothing else
+func LoadDatabase() (*Database, error) {
+	if err := loadFiles(); err != nil {
+		return nil, err
+	}
+	return parseDatabase(os.Getenv)
+}
+
+// loadFiles sets the variables of CONFIG_FILE and .env that are not set yet
+func loadFiles() error {
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := godotenv.Load(file); err != nil {
+			return fmt.Errorf("config: reading %s: %w", file, err)
+		}
+	}
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("config: reading .env: %w", err)
+	}
+	return nil
+}
+
+// parseDatabase builds and validates the database settings from getenv
+func parseDatabase(getenv func(string) string) (*Database, error) {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	production := strings.ToLower(getenv("APP_ENV")) == EnvProduction
+	database := readDatabase(getenv, production, fail)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid database configuration:\n%w", errors.Join(errs...))
+	}
+	return &database, nil
+}
+
+// readDatabase reads the database settings, reporting problems to fail
+func readDatabase(getenv func(string) string, production bool, fail func(string, ...any)) Database {
+	database := Database{
+		URI:      getenv("MONGODB_URI"),
+		Host:     getenv("DB_HOST"),
+		Port:     getenv("DB_PORT"),
+		Name:     getenv("DB_DATABASE"),
+		Username: getenv("DB_USERNAME"),
+		Password: getenv("DB_ROOT_PASSWORD"),
+	}
+	if database.URI == "" {
+		if production {
+			fail("MONGODB_URI is required in production")
+		} else if database.Host == "" || database.Port == "" {
+			fail("MONGODB_URI, or DB_HOST and DB_PORT, are required")
+		}
+	}
+	if database.Name == "" {
+		fail("DB_DATABASE is required")
+	}
+	return database
+}
+
+// parse builds and validates a Config from getenv. Every problem is
+// reported, not just the first.
+func parse(getenv func(string) string) (*Config, error) {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	cfg := &Config{
+		Env:     strings.ToLower(getenv("APP_ENV")),
+		BaseURL: strings.TrimSuffix(getenv("BASE_URL"), "/"),
+		Session: Session{
+			Secret:      getenv("SESSION_SECRET"),
+			Store:       getenv("SESSION_STORE"),
+			IdleTimeout: defaultIdleTimeout,
+		},
+		Google: Google{
+			ClientID:     getenv("GOOGLE_KEY"),
+			ClientSecret: getenv("GOOGLE_SECRET"),
+		},
+		Push: Push{
+			PublicKey:      getenv("VAPID_PUBLIC_KEY"),
+			PrivateKey:     getenv("VAPID_PRIVATE_KEY"),
+			Subject:        getenv("VAPID_SUBJECT"),
+			LongTimerAlert: defaultLongTimerAlert,
+		},
+	}
+
+	switch cfg.Env {
+	case "":
+		cfg.Env = EnvDevelopment
+	case EnvDevelopment, EnvProduction:
+	default:
+		fail("APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, cfg.Env)
+	}
+	production := cfg.IsProduction()
+
+	// Server
+	if raw := getenv("PORT"); raw != "" {
+		port, err := strconv.Atoi(raw)
+		if err != nil || port < 1 || port > 65535 {
+			fail("PORT must be a port number, got %q", raw)
+		}
+		cfg.Port = port
+	} else if production {
+		fail("PORT is required in production")
+	} else {
+		cfg.Port = defaultPort
+	}
+
+	if cfg.BaseURL == "" {
+		if production {
+			fail("BASE_URL is required in production")
+		} else {
+			cfg.BaseURL = fmt.Sprintf("http://localhost:%d", cfg.Port)
+		}
+	} else if u, err := url.Parse(cfg.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		fail("BASE_URL must be an absolute URL, got %q", cfg.BaseURL)
+	} else if production && u.Scheme != "https" {
+		fail("BASE_URL must use https in production, got %q", cfg.BaseURL)
+	}
+
+	for _, origin := range strings.Split(getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(cfg.AllowedOrigins) == 0 && cfg.BaseURL != "" {
+		cfg.AllowedOrigins = []string{cfg.BaseURL}
+	}
+
+	for _, proxy := range strings.Split(getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("TRUSTED_PROXIES must list IP addresses or CIDR ranges, got %q", proxy)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+	}
+
+	// Database
+	cfg.Database = readDatabase(getenv, production, fail)
+
+	// Sessions
+	switch cfg.Session.Store {
+	case "":
+		cfg.Session.Store = SessionStoreMongo
+	case SessionStoreMongo:
+	case SessionStoreMemory:
+		if production {
+			fail("SESSION_STORE=memory is for development only")
+		}
+	default:
+		fail("SESSION_STORE must be %q or %q, got %q", SessionStoreMongo, SessionStoreMemory, cfg.Session.Store)
+	}
+
+	if raw := getenv("SESSION_IDLE_TIMEOUT"); raw != "" {
+		idleTimeout, err := time.ParseDuration(raw)
+		if err != nil || idleTimeout <= 0 {
+			fail("SESSION_IDLE_TIMEOUT must be a positive duration such as 168h, got %q", raw)
+		}
+		cfg.Session.IdleTimeout = idleTimeout
+	}
+
+	switch {
+	case production && (cfg.Session.Secret == "" || cfg.Session.Secret == exampleSecret):
+		fail("SESSION_SECRET is required in production, generate one with: openssl rand -base64 32")
+	case production && len(cfg.Session.Secret) < minSecretLength:
+		fail("SESSION_SECRET must be at least %d characters in production", minSecretLength)
+	case cfg.Session.Secret == "":
+		secret, err := randomSecret()
+		if err != nil {
+			fail("generating a session secret: %v", err)
+		}
+		cfg.Session.Secret = secret
+		log.Println("SESSION_SECRET is not set, using a random one; sessions end when the server restarts")
+	}
+
+	// Logging; JSON suits log collectors, text suits terminals
+	cfg.Log.Format = strings.ToLower(getenv("LOG_FORMAT"))
+	switch cfg.Log.Format {
+	case "":
+		cfg.Log.Format = logging.FormatText
+		if production {
+			cfg.Log.Format = logging.FormatJSON
+		}
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		fail("LOG_FORMAT must be %q or %q, got %q", logging.FormatText, logging.FormatJSON, cfg.Log.Format)
+	}
+	if raw := getenv("LOG_LEVEL"); raw != "" {
+		if err := cfg.Log.Level.UnmarshalText([]byte(raw)); err != nil {
+			fail("LOG_LEVEL must be debug, info, warn or error, got %q", raw)
+		}
+	}
+
+	// Rate limiting
+	cfg.RateLimit.API = parseLimit(getenv, "RATE_LIMIT", defaultAPIRate, defaultAPIBurst, fail)
+	cfg.RateLimit.Auth = parseLimit(getenv, "AUTH_RATE_LIMIT", defaultAuthRate, defaultAuthBurst, fail)
+
+	// Push notifications
+	switch {
+	case cfg.Push.PublicKey == "" && cfg.Push.PrivateKey == "":
+		log.Println("VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY are not set; push notifications are off, generate keys with: admin vapid-keys")
+	case !matchingKeys(cfg.Push.PublicKey, cfg.Push.PrivateKey):
+		fail("VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY must be a P-256 key pair, generate one with: admin vapid-keys")
+	}
+	if cfg.Push.Subject == "" {
+		cfg.Push.Subject = cfg.BaseURL
+	} else if !strings.HasPrefix(cfg.Push.Subject, "mailto:") && !strings.HasPrefix(cfg.Push.Subject, "https://") {
+		fail("VAPID_SUBJECT must be a mailto: or https: URL, got %q", cfg.Push.Subject)
+	}
+	if raw := getenv("LONG_TIMER_ALERT"); raw == "off" {
+		cfg.Push.LongTimerAlert = 0
+	} else if raw != "" {
+		alert, err := time.ParseDuration(raw)
+		if err != nil || alert <= 0 {
+			fail("LONG_TIMER_ALERT must be a positive duration such as 4h, or off, got %q", raw)
+		}
+		cfg.Push.LongTimerAlert = alert
+	}
+
+	// Webhooks
+	if raw := getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS"); raw != "" {
+		allow, err := strconv.ParseBool(raw)
+		if err != nil {
+			fail("WEBHOOK_ALLOW_PRIVATE_NETWORKS must be true or false, got %q", raw)
+		}
+		cfg.Webhooks.AllowPrivateNetworks = allow
+	}
+
+	// Metrics
+	cfg.Metrics.Token = getenv("METRICS_TOKEN")
+	if cfg.Metrics.Token == "" {
+		log.Println("METRICS_TOKEN is not set; /metrics is off")
+	} else if production && len(cfg.Metrics.Token) < minSecretLength {
+		fail("METRICS_TOKEN must be at least %d characters in production", minSecretLength)
+	}
+
+	// OAuth
+	if cfg.Google.ClientID == "" || cfg.Google.ClientSecret == "" {
+		if production {
+			fail("GOOGLE_KEY and GOOGLE_SECRET are required in production")
+		} else {
+			log.Println("GOOGLE_KEY or GOOGLE_SECRET is not set; Google login will fail")
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration (APP_ENV=%s):\n%w", cfg.Env, errors.Join(errs...))
+	}
+	return cfg, nil
+}
+
+// parseLimit reads a rate such as "300/m" from name and a burst size from
+// name_BURST. A rate of "off" disables the limit.
+func parseLimit(getenv func(string) string, name, defaultRate string, defaultBurst int, fail func(string, ...any)) ratelimit.Limit {
+	raw := getenv(name)
+	if raw == "off" {
+		return ratelimit.Limit{}
+	}
+	if raw == "" {
+		raw = defaultRate
+	}
+	limit := ratelimit.Limit{Burst: defaultBurst}
+
+	rate, err := ratelimit.ParseRate(raw)
+	if err != nil {
+		fail("%s must be a rate such as %s, or off: %v", name, defaultRate, err)
+	}
+	limit.Rate = rate
+
+	if raw := getenv(name + "_BURST"); raw != "" {
+		burst, err := strconv.Atoi(raw)
+		if err != nil || burst < 1 {
+			fail("%s_BURST must be a positive number, got %q", name, raw)
+		}
+		limit.Burst = burst
+	}
+	return limit
+}
+
+// matchingKeys reports whether publicKey is the public half of privateKey,
+// both base64url encoded
+func matchingKeys(publicKey, privateKey string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return false
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return false
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()) == strings.TrimRight(publicKey, "=")
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+// UserDataCounts counts the documents belonging to one user
+type UserDataCounts struct {
+	Users         int64
+	TimerSessions int64
+	TagStats      int64
+	Sessions      int64
+	// PushSubscriptions are the browsers the user gets notifications on
+	PushSubscriptions int64
+	Webhooks          int64
+	// WebhookDeliveries are the logged and pending deliveries to the user's
+	// webhooks
+	WebhookDeliveries int64
+}
+
+// ListUsers returns every user, oldest first
+func (s *service) ListUsers(ctx context.Context) ([]*models.User, error) {
+	collection := s.getUsersCollection()
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var users []*models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// CountUserData counts what PurgeUser would delete
+func (s *service) CountUserData(ctx context.Context, userId string) (*UserDataCounts, error) {
+	var counts UserDataCounts
+	var err error
+	if counts.Users, err = s.getUsersCollection().CountDocuments(ctx, bson.M{"_id": userId}); err != nil {
+		return nil, err
+	}
+	ownerFilter := bson.M{"user_id": userId}
+	if counts.TimerSessions, err = s.getTimerSessionsCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	if counts.TagStats, err = s.getUserTagStatsCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	if counts.Sessions, err = s.getSessionsCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	if counts.PushSubscriptions, err = s.getPushSubscriptionsCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	if counts.Webhooks, err = s.getWebhooksCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	if counts.WebhookDeliveries, err = s.getWebhookDeliveriesCollection().CountDocuments(ctx, ownerFilter); err != nil {
+		return nil, err
+	}
+	return &counts, nil
+}
+
+// PurgeUser deletes a user together with their timer sessions, tag stats,
+// login sessions, pending device logins, push subscriptions and webhooks
+func (s *service) PurgeUser(ctx context.Context, userId string) (*UserDataCounts, error) {
+	var counts UserDataCounts
+	ownerFilter := bson.M{"user_id": userId}
+
+	// Delete the user last so a failed purge can simply be rerun
+	result, err := s.getSessionsCollection().DeleteMany(ctx, ownerFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	counts.Sessions = result.DeletedCount
+
+	if _, err = s.getDeviceAuthorizationsCollection().DeleteMany(ctx, bson.M{"user._id": userId}); err != nil {
+		return nil, fmt.Errorf("failed to delete device logins: %w", err)
+	}
+
+	if result, err = s.getPushSubscriptionsCollection().DeleteMany(ctx, ownerFilter); err != nil {
+		return nil, fmt.Errorf("failed to delete push subscriptions: %w", err)
+	}
+	counts.PushSubscriptions = result.DeletedCount
+
+	// Deliveries go first so none is sent to a webhook about to be deleted
+	if result, err = s.getWebhookDeliveriesCollection().DeleteMany(ctx, ownerFilter); err != nil {
+		return nil, fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	counts.WebhookDeliveries = result.DeletedCount
+
+	if result, err = s.getWebhooksCollection().DeleteMany(ctx, ownerFilter); err != nil {
+		return nil, fmt.Errorf("failed to delete webhooks: %w", err)
+	}
+	counts.Webhooks = result.DeletedCount
+
+	if result, err = s.getTimerSessionsCollection().DeleteMany(ctx, ownerFilter); err != nil {
+		return nil, fmt.Errorf("failed to delete timer sessions: %w", err)
+	}
+	counts.TimerSessions = result.DeletedCount
+
+	if result, err = s.getUserTagStatsCollection().DeleteMany(ctx, ownerFilter); err != nil {
+		return nil, fmt.Errorf("failed to delete tag stats: %w", err)
+	}
+	counts.TagStats = result.DeletedCount
+
+	if result, err = s.getUsersCollection().DeleteOne(ctx, bson.M{"_id": userId}); err != nil {
+		return nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+	counts.Users = result.DeletedCount
+
+	return &counts, nil
+}
+
+// RecomputeUserTagStats derives what every user's tag stats should be from
+// their timer sessions. The results have no IDs.
+func (s *service) RecomputeUserTagStats(ctx context.Context) ([]*models.UserTagStats, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":            bson.M{"user_id": "$user_id", "tag": "$tag"},
+			"total_duration": bson.M{"$sum": "$duration"},
+			"session_count":  bson.M{"$sum": 1},
+			"last_updated":   bson.M{"$max": "$last_updated"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":            0,
+			"user_id":        "$_id.user_id",
+			"tag":            "$_id.tag",
+			"total_duration": 1,
+			"session_count":  1,
+			"last_updated":   1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "user_id", Value: 1}, {Key: "tag", Value: 1}}}},
+	}
+
+	cursor, err := s.getTimerSessionsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate timer sessions: %w", err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var tagStats []*models.UserTagStats
+	if err = cursor.All(ctx, &tagStats); err != nil {
+		return nil, err
+	}
+	return tagStats, nil
+}
+
+// ListAllUserTagStats returns the tag stats of every user in insertion order
+func (s *service) ListAllUserTagStats(ctx context.Context) ([]*models.UserTagStats, error) {
+	collection := s.getUserTagStatsCollection()
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag stats: %w", err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var tagStats []*models.UserTagStats
+	if err = cursor.All(ctx, &tagStats); err != nil {
+		return nil, err
+	}
+	return tagStats, nil
+}
+
+func (s *service) DeleteUserTagStatsByID(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := s.getUserTagStatsCollection().DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	return nil
+}
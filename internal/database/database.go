@@ -3,85 +3,142 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/joho/godotenv/autoload"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
 	"github.com/neilsmahajan/productivity-timer/internal/models"
 )
 
 type Service interface {
-	Health() map[string]string
+	Health(ctx context.Context) (*Health, error)
 	FindOrCreateUser(ctx context.Context, user *models.User) (*models.User, error)
 	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	SetConcurrentTimers(ctx context.Context, userId string, enabled bool) error
 	UpdateTimerSession(ctx context.Context, timerSession *models.TimerSession) error
+	UpdateTimerSessionNote(ctx context.Context, id primitive.ObjectID, note string) error
+	PauseTimerSession(ctx context.Context, timerSession *models.TimerSession, runningSince time.Time) error
 	CreateTimerSession(ctx context.Context, timerSession *models.TimerSession) error
 	FindTimerSession(ctx context.Context, userId, tag string, status models.TimerStatus) (*models.TimerSession, error)
+	FindActiveTimerSession(ctx context.Context, userId string) (*models.TimerSession, error)
+	FindActiveTimerSessions(ctx context.Context, userId string) ([]*models.TimerSession, error)
+	FindDueTimerSessions(ctx context.Context, now time.Time) ([]*models.TimerSession, error)
+	ClaimLongRunningTimerSession(ctx context.Context, runningSince, now time.Time) (*models.TimerSession, error)
 	AbandonRunningTimers(ctx context.Context, userId, tag string) error
+	CountRunningTimers(ctx context.Context) (int64, error)
 	UpdateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error
 	CreateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error
+	AddToUserTagStats(ctx context.Context, userId, tag string, duration int64, sessions int, now time.Time) error
 	FindUserTagStats(ctx context.Context, userId string, tag string) (*models.UserTagStats, error)
 	FindAllUserTagStats(ctx context.Context, userId string) ([]*models.UserTagStats, error)
-	GetStatsSummary(ctx context.Context, userId string, startDate, endDate time.Time) (*models.StatsSummary, error)
+	UpdateTagSettings(ctx context.Context, userId, tag string, settings models.TagSettings) (*models.UserTagStats, error)
+	GetStatsSummary(ctx context.Context, userId, tag string, startDate, endDate time.Time, wallClock bool) (*models.StatsSummary, error)
 	GetTagSessions(ctx context.Context, userId, tag string, startDate, endDate time.Time) ([]*models.TimerSession, error)
+	SearchTimerSessions(ctx context.Context, userId string, terms []string, startDate, endDate time.Time) ([]*models.TimerSession, error)
 	DeleteUserTagStats(ctx context.Context, userId, tag string) error
 	DeleteTimerSession(ctx context.Context, userId, tag string) error
+	CreateSession(ctx context.Context, session *models.Session) error
+	FindSession(ctx context.Context, id string) (*models.Session, error)
+	TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteUserSessions(ctx context.Context, userId, exceptID string) error
+	FindUserSessions(ctx context.Context, userId string) ([]*models.Session, error)
+	CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error
+	FindDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error)
+	FindDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*models.DeviceAuthorization, error)
+	ApproveDeviceAuthorization(ctx context.Context, id string, user *models.User) error
+	DeleteDeviceAuthorization(ctx context.Context, id string) error
+	ClaimDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error)
+	SavePushSubscription(ctx context.Context, subscription *models.PushSubscription) error
+	FindPushSubscriptions(ctx context.Context, userId string) ([]*models.PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, userId, endpoint string) error
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	FindWebhooks(ctx context.Context, userId string) ([]*models.Webhook, error)
+	FindWebhook(ctx context.Context, userId string, id primitive.ObjectID) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userId string, id primitive.ObjectID) error
+	CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error
+	ClaimWebhookDelivery(ctx context.Context, now, leaseUntil time.Time, skipWebhooks []primitive.ObjectID) (*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	FindWebhookDeliveries(ctx context.Context, userId string, webhookID primitive.ObjectID, limit int) ([]*models.WebhookDelivery, error)
+	EnsureIndexes(ctx context.Context) ([]string, error)
+	MissingIndexes(ctx context.Context) ([]string, error)
+	ListUsers(ctx context.Context) ([]*models.User, error)
+	CountUserData(ctx context.Context, userId string) (*UserDataCounts, error)
+	PurgeUser(ctx context.Context, userId string) (*UserDataCounts, error)
+	RecomputeUserTagStats(ctx context.Context) ([]*models.UserTagStats, error)
+	ListAllUserTagStats(ctx context.Context) ([]*models.UserTagStats, error)
+	DeleteUserTagStatsByID(ctx context.Context, id primitive.ObjectID) error
+	PendingMigrations(ctx context.Context) ([]Migration, error)
+	Migrate(ctx context.Context) ([]Migration, error)
 }
 
-type service struct {
-	db *mongo.Client
+// Health describes the database connection as seen by a readiness check
+type Health struct {
+	Latency          time.Duration
+	OpenConnections  int64
+	InUseConnections int64
 }
 
-var (
-	// MongoDB Atlas connection string (preferred for production)
-	mongoURI = os.Getenv("MONGODB_URI")
-	// Legacy environment variables for local development
-	host     = os.Getenv("DB_HOST")
-	port     = os.Getenv("DB_PORT")
-	database = os.Getenv("DB_DATABASE")
-	username = os.Getenv("DB_USERNAME")
-	password = os.Getenv("DB_ROOT_PASSWORD")
-)
-
-func New() Service {
-	var uri string
-
-	// Use MONGODB_URI if provided (Atlas), otherwise construct from parts (local)
-	if mongoURI != "" {
-		uri = mongoURI
-	} else if username != "" && password != "" {
-		uri = fmt.Sprintf("mongodb://%s:%s@%s:%s", username, password, host, port)
-	} else {
-		uri = fmt.Sprintf("mongodb://%s:%s", host, port)
-	}
+type service struct {
+	db    *mongo.Client
+	name  string
+	clock clock.Clock
+	pool  *poolStats
+}
 
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+// New configures a client for the database. The driver connects lazily, so
+// an unreachable server is not an error here; use Health to wait for it.
+func New(cfg config.Database, clk clock.Clock) (Service, error) {
+	pool := &poolStats{}
+	opts := options.Client().
+		ApplyURI(cfg.ConnectionURI()).
+		SetPoolMonitor(&event.PoolMonitor{Event: pool.observe})
+	client, err := mongo.Connect(context.Background(), opts)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("configuring database client: %w", err)
 	}
 	return &service{
-		db: client,
-	}
+		db:    client,
+		name:  cfg.Name,
+		clock: clk,
+		pool:  pool,
+	}, nil
 }
 
-func (s *service) Health() map[string]string {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
-
-	err := s.db.Ping(ctx, nil)
-	if err != nil {
-		return map[string]string{
-			"status":  "unhealthy",
-			"message": "Database connection failed",
-		}
+// Health pings the primary and reports the round trip and connection pool
+func (s *service) Health(ctx context.Context) (*Health, error) {
+	start := time.Now()
+	if err := s.db.Ping(ctx, nil); err != nil {
+		return nil, err
 	}
+	return &Health{
+		Latency:          time.Since(start),
+		OpenConnections:  s.pool.open.Load(),
+		InUseConnections: s.pool.inUse.Load(),
+	}, nil
+}
+
+// poolStats tracks the driver's connection pools from their events
+type poolStats struct {
+	open  atomic.Int64
+	inUse atomic.Int64
+}
 
-	return map[string]string{
-		"status":  "healthy",
-		"message": "It's healthy",
+func (p *poolStats) observe(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		p.open.Add(1)
+	case event.ConnectionClosed:
+		p.open.Add(-1)
+	case event.GetSucceeded:
+		p.inUse.Add(1)
+	case event.ConnectionReturned:
+		p.inUse.Add(-1)
 	}
 }
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func (s *service) getDeviceAuthorizationsCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("device_authorizations")
+}
+
+func (s *service) CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error {
+	collection := s.getDeviceAuthorizationsCollection()
+	if _, err := collection.InsertOne(ctx, authorization); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindDeviceAuthorization returns the authorization with the given ID, or nil
+// if it does not exist
+func (s *service) FindDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error) {
+	return s.findDeviceAuthorization(ctx, bson.M{"_id": id})
+}
+
+// FindDeviceAuthorizationByUserCode returns the unexpired authorization with
+// the given user code, or nil if there is none
+func (s *service) FindDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*models.DeviceAuthorization, error) {
+	return s.findDeviceAuthorization(ctx, bson.M{
+		"user_code":  userCode,
+		"expires_at": bson.M{"$gt": s.clock.Now()},
+	})
+}
+
+func (s *service) findDeviceAuthorization(ctx context.Context, filter bson.M) (*models.DeviceAuthorization, error) {
+	collection := s.getDeviceAuthorizationsCollection()
+
+	var authorization models.DeviceAuthorization
+	err := collection.FindOne(ctx, filter).Decode(&authorization)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &authorization, nil
+}
+
+func (s *service) ApproveDeviceAuthorization(ctx context.Context, id string, user *models.User) error {
+	collection := s.getDeviceAuthorizationsCollection()
+	update := bson.M{"$set": bson.M{"user": user}}
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *service) DeleteDeviceAuthorization(ctx context.Context, id string) error {
+	collection := s.getDeviceAuthorizationsCollection()
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClaimDeviceAuthorization deletes the approved authorization with the given
+// ID and returns it, or nil if it is not approved or was claimed already.
+// Only one of several concurrent claims gets the authorization.
+func (s *service) ClaimDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error) {
+	collection := s.getDeviceAuthorizationsCollection()
+
+	var authorization models.DeviceAuthorization
+	err := collection.FindOneAndDelete(ctx, bson.M{"_id": id, "user": bson.M{"$ne": nil}}).Decode(&authorization)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &authorization, nil
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// namespaceNotFound is the server error code for a missing collection
+	namespaceNotFound = 26
+	// runningTimersIndex holds only the running timer sessions
+	runningTimersIndex = "running_last_updated"
+	// webhookDeliveryRetention is how long webhook deliveries are logged
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+)
+
+// ErrRequiredIndexMissing means a unique index the application relies on for
+// correctness could not be created, e.g. because of duplicate documents
+var ErrRequiredIndexMissing = errors.New("required index missing")
+
+// index is an index the application's queries rely on. Names are fixed so
+// existing indexes can be recognised. A required index enforces uniqueness
+// the code depends on; the others only speed up queries.
+type index struct {
+	collection string
+	model      mongo.IndexModel
+	required   bool
+}
+
+var indexes = []index{
+	{
+		// FindOrCreateUser. Unique, so racing first logins cannot create two
+		// users. migrateLegacyUser moves a legacy user's provider ID out of
+		// provider_id, which takes them out of the index, before copying them.
+		collection: "users",
+		model: mongo.IndexModel{
+			Keys: bson.D{{Key: "provider", Value: 1}, {Key: "provider_id", Value: 1}},
+			Options: options.Index().SetName("provider_provider_id").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"provider_id": bson.M{"$type": "string"}}),
+		},
+		required: true,
+	},
+	{
+		// GetStatsSummary, FindActiveTimerSession, GetTagSessions for every tag
+		collection: "timers",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "start_time", Value: 1}},
+			Options: options.Index().SetName("user_id_status_start_time"),
+		},
+	},
+	{
+		// FindTimerSession, GetTagSessions, DeleteTimerSession
+		collection: "timers",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "tag", Value: 1}, {Key: "status", Value: 1}, {Key: "start_time", Value: -1}},
+			Options: options.Index().SetName("user_id_tag_status_start_time"),
+		},
+	},
+	{
+		// SearchTimerSessions. A collection has at most one text index; the
+		// user_id prefix keeps each search within one user's sessions.
+		// Language "none" indexes words as written, without stemming or
+		// stop words.
+		collection: "timers",
+		model: mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "tag", Value: "text"}, {Key: "note", Value: "text"}},
+			Options: options.Index().SetName("user_id_tag_note_text").
+				SetDefaultLanguage("none").
+				SetWeights(bson.D{{Key: "tag", Value: 2}, {Key: "note", Value: 1}}),
+		},
+	},
+	{
+		// FindDueTimerSessions. Only countdowns have a due_at.
+		collection: "timers",
+		model: mongo.IndexModel{
+			Keys: bson.D{{Key: "due_at", Value: 1}},
+			Options: options.Index().SetName("due_at").
+				SetPartialFilterExpression(bson.M{"due_at": bson.M{"$type": "date"}}),
+		},
+	},
+	{
+		// ClaimLongRunningTimerSession, CountRunningTimers. Few sessions are
+		// running at once.
+		collection: "timers",
+		model: mongo.IndexModel{
+			Keys: bson.D{{Key: "last_updated", Value: 1}},
+			Options: options.Index().SetName(runningTimersIndex).
+				SetPartialFilterExpression(bson.M{"status": "running"}),
+		},
+	},
+	{
+		// One stats document per tag
+		collection: "tagstats",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "tag", Value: 1}},
+			Options: options.Index().SetName("user_id_tag").SetUnique(true),
+		},
+		required: true,
+	},
+	{
+		// FindUserSessions, DeleteUserSessions
+		collection: "sessions",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}},
+			Options: options.Index().SetName("user_id_last_seen_at"),
+		},
+	},
+	{
+		// SavePushSubscription, DeletePushSubscription. A browser has one
+		// subscription per endpoint.
+		collection: "push_subscriptions",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "endpoint", Value: 1}},
+			Options: options.Index().SetName("endpoint").SetUnique(true),
+		},
+		required: true,
+	},
+	{
+		// FindPushSubscriptions
+		collection: "push_subscriptions",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_id"),
+		},
+	},
+	{
+		// FindWebhooks
+		collection: "webhooks",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("user_id_created_at"),
+		},
+	},
+	{
+		// ClaimWebhookDelivery. Only pending deliveries are due.
+		collection: "webhook_deliveries",
+		model: mongo.IndexModel{
+			Keys: bson.D{{Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("pending_next_attempt_at").
+				SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+	},
+	{
+		// FindWebhookDeliveries, DeleteWebhook
+		collection: "webhook_deliveries",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("webhook_id_created_at"),
+		},
+	},
+	{
+		// The delivery log is kept for a month
+		collection: "webhook_deliveries",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("created_at_ttl").SetExpireAfterSeconds(int32(webhookDeliveryRetention / time.Second)),
+		},
+	},
+	{
+		// FindDeviceAuthorizationByUserCode
+		collection: "device_authorizations",
+		model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_code", Value: 1}},
+			Options: options.Index().SetName("user_code"),
+		},
+	},
+}
+
+// MissingIndexes returns the collection.name of every declared index that
+// does not exist yet
+func (s *service) MissingIndexes(ctx context.Context) ([]string, error) {
+	existing := make(map[string]map[string]bool)
+	var missing []string
+	for _, idx := range indexes {
+		names, ok := existing[idx.collection]
+		if !ok {
+			var err error
+			if names, err = s.indexNames(ctx, idx.collection); err != nil {
+				return nil, err
+			}
+			existing[idx.collection] = names
+		}
+		if name := *idx.model.Options.Name; !names[name] {
+			missing = append(missing, idx.collection+"."+name)
+		}
+	}
+	return missing, nil
+}
+
+// EnsureIndexes creates every missing index and returns the collection.name
+// of those it created. An index that cannot be created does not stop the
+// others; the failures are joined, and wrap ErrRequiredIndexMissing if a
+// required index is among them.
+func (s *service) EnsureIndexes(ctx context.Context) ([]string, error) {
+	missing, err := s.MissingIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isMissing := make(map[string]bool, len(missing))
+	for _, name := range missing {
+		isMissing[name] = true
+	}
+
+	var created []string
+	var errs []error
+	for _, idx := range indexes {
+		name := idx.collection + "." + *idx.model.Options.Name
+		if !isMissing[name] {
+			continue
+		}
+		collection := s.db.Database(s.name).Collection(idx.collection)
+		if _, err = collection.Indexes().CreateOne(ctx, idx.model); err != nil {
+			if idx.required {
+				err = fmt.Errorf("%w: %w", ErrRequiredIndexMissing, err)
+			}
+			errs = append(errs, fmt.Errorf("failed to create index %s: %w", name, err))
+			continue
+		}
+		created = append(created, name)
+	}
+	return created, errors.Join(errs...)
+}
+
+func (s *service) indexNames(ctx context.Context, collectionName string) (map[string]bool, error) {
+	collection := s.db.Database(s.name).Collection(collectionName)
+	cursor, err := collection.Indexes().List(ctx)
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) && commandErr.Code == namespaceNotFound {
+		// Collections are created on first insert
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes on %s: %w", collectionName, err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var specs []struct {
+		Name string `bson:"name"`
+	}
+	if err = cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		names[spec.Name] = true
+	}
+	return names, nil
+}
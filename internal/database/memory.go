@@ -0,0 +1,976 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/search"
+)
+
+// memoryService is an in-process Service for tests and local development.
+// It mirrors the Mongo implementation's semantics but keeps nothing on disk.
+type memoryService struct {
+	mu       sync.RWMutex
+	clock    clock.Clock
+	users    map[string]models.User
+	timers   map[primitive.ObjectID]models.TimerSession
+	tagStats map[primitive.ObjectID]models.UserTagStats
+	sessions map[string]models.Session
+	devices  map[string]models.DeviceAuthorization
+	// pushSubscriptions are keyed by endpoint
+	pushSubscriptions map[string]models.PushSubscription
+	webhooks          map[primitive.ObjectID]models.Webhook
+	// webhookDeliveries are kept until their webhook is deleted
+	webhookDeliveries map[primitive.ObjectID]models.WebhookDelivery
+	// migrations holds the versions of applied migrations
+	migrations map[int]bool
+}
+
+// NewMemory returns a Service that keeps all data in process memory
+func NewMemory(clk clock.Clock) Service {
+	return &memoryService{
+		clock:             clk,
+		users:             make(map[string]models.User),
+		timers:            make(map[primitive.ObjectID]models.TimerSession),
+		tagStats:          make(map[primitive.ObjectID]models.UserTagStats),
+		sessions:          make(map[string]models.Session),
+		devices:           make(map[string]models.DeviceAuthorization),
+		pushSubscriptions: make(map[string]models.PushSubscription),
+		webhooks:          make(map[primitive.ObjectID]models.Webhook),
+		webhookDeliveries: make(map[primitive.ObjectID]models.WebhookDelivery),
+		migrations:        make(map[int]bool),
+	}
+}
+
+func (m *memoryService) Health(_ context.Context) (*Health, error) {
+	return &Health{}, nil
+}
+
+func (m *memoryService) FindOrCreateUser(_ context.Context, user *models.User) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for id, existingUser := range m.users {
+		if existingUser.Provider == user.Provider && existingUser.ProviderID == user.ProviderID {
+			existingUser.LastLoginAt = now
+			existingUser.Email = user.Email
+			existingUser.Name = user.Name
+			existingUser.FirstName = user.FirstName
+			existingUser.LastName = user.LastName
+			existingUser.NickName = user.NickName
+			existingUser.AvatarURL = user.AvatarURL
+			m.users[id] = existingUser
+			return &existingUser, nil
+		}
+	}
+
+	user.ID = models.NewUserID()
+	user.CreatedAt = now
+	user.LastLoginAt = now
+	m.users[user.ID] = *user
+	return user, nil
+}
+
+func (m *memoryService) GetUserByID(_ context.Context, id string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (m *memoryService) SetConcurrentTimers(_ context.Context, userId string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userId]
+	if !ok {
+		return fmt.Errorf("%w: no user %s", models.ErrNotFound, userId)
+	}
+	user.ConcurrentTimers = enabled
+	m.users[userId] = user
+	return nil
+}
+
+// migrateLegacyUserIDs mirrors the Mongo migration. Callers must hold the
+// lock.
+func (m *memoryService) migrateLegacyUserIDs() {
+	for id, user := range m.users {
+		if user.ID != user.ProviderID {
+			continue
+		}
+		user.ID = models.NewUserID()
+		m.users[user.ID] = user
+		delete(m.users, id)
+		for timerID, timerSession := range m.timers {
+			if timerSession.UserID == id {
+				timerSession.UserID = user.ID
+				m.timers[timerID] = timerSession
+			}
+		}
+		for statsID, userTagStats := range m.tagStats {
+			if userTagStats.UserID == id {
+				userTagStats.UserID = user.ID
+				m.tagStats[statsID] = userTagStats
+			}
+		}
+	}
+}
+
+func (m *memoryService) PendingMigrations(_ context.Context) ([]Migration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return pendingMigrations(m.migrations), nil
+}
+
+func (m *memoryService) Migrate(_ context.Context) ([]Migration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if m.migrations[migration.Version] {
+			continue
+		}
+		migration.memory(m)
+		m.migrations[migration.Version] = true
+		ran = append(ran, migration.Migration)
+	}
+	return ran, nil
+}
+
+func (m *memoryService) UpdateTimerSession(_ context.Context, timerSession *models.TimerSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.timers[timerSession.ID]; ok {
+		m.timers[timerSession.ID] = *timerSession
+	}
+	return nil
+}
+
+func (m *memoryService) UpdateTimerSessionNote(_ context.Context, id primitive.ObjectID, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	timerSession, ok := m.timers[id]
+	if !ok {
+		return fmt.Errorf("%w: timer session %s", models.ErrNotFound, id.Hex())
+	}
+	timerSession.Note = note
+	m.timers[id] = timerSession
+	return nil
+}
+
+func (m *memoryService) PauseTimerSession(_ context.Context, timerSession *models.TimerSession, runningSince time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.timers[timerSession.ID]
+	if !ok || stored.Status != models.StatusRunning || !stored.LastUpdated.Equal(runningSince) {
+		return fmt.Errorf("%w: timer session %s is no longer running", models.ErrConflict, timerSession.ID.Hex())
+	}
+	m.timers[timerSession.ID] = *timerSession
+	return nil
+}
+
+func (m *memoryService) CreateTimerSession(_ context.Context, timerSession *models.TimerSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.timers[timerSession.ID]; ok {
+		return fmt.Errorf("%w: duplicate timer session %s", models.ErrConflict, timerSession.ID.Hex())
+	}
+	m.timers[timerSession.ID] = *timerSession
+	return nil
+}
+
+func (m *memoryService) FindTimerSession(_ context.Context, userId, tag string, status models.TimerStatus) (*models.TimerSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, timerSession := range m.timers {
+		if timerSession.UserID == userId && timerSession.Tag == tag && timerSession.Status == status {
+			return &timerSession, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no %s timer for tag %q", models.ErrNotFound, status, tag)
+}
+
+func (m *memoryService) FindActiveTimerSession(_ context.Context, userId string) (*models.TimerSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var active *models.TimerSession
+	for _, timerSession := range m.timers {
+		if timerSession.UserID != userId {
+			continue
+		}
+		if timerSession.Status != models.StatusRunning && timerSession.Status != models.StatusStopped {
+			continue
+		}
+		if active == nil || timerSession.LastUpdated.After(active.LastUpdated) {
+			active = &timerSession
+		}
+	}
+	if active == nil {
+		return nil, fmt.Errorf("%w: no active timer", models.ErrNotFound)
+	}
+	return active, nil
+}
+
+func (m *memoryService) FindActiveTimerSessions(_ context.Context, userId string) ([]*models.TimerSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var timerSessions []*models.TimerSession
+	for _, timerSession := range m.timers {
+		if timerSession.UserID == userId && (timerSession.Status == models.StatusRunning || timerSession.Status == models.StatusStopped) {
+			timerSessions = append(timerSessions, &timerSession)
+		}
+	}
+	sort.Slice(timerSessions, func(i, j int) bool {
+		return timerSessions[i].StartTime.Before(timerSessions[j].StartTime)
+	})
+	return timerSessions, nil
+}
+
+func (m *memoryService) FindDueTimerSessions(_ context.Context, now time.Time) ([]*models.TimerSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var timerSessions []*models.TimerSession
+	for _, timerSession := range m.timers {
+		if timerSession.Status == models.StatusRunning && timerSession.DueAt != nil && !timerSession.DueAt.After(now) {
+			timerSessions = append(timerSessions, &timerSession)
+		}
+	}
+	return timerSessions, nil
+}
+
+func (m *memoryService) ClaimLongRunningTimerSession(_ context.Context, runningSince, now time.Time) (*models.TimerSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, timerSession := range m.timers {
+		if timerSession.Status == models.StatusRunning && timerSession.AlertedAt == nil && !timerSession.LastUpdated.After(runningSince) {
+			alertedAt := now
+			timerSession.AlertedAt = &alertedAt
+			m.timers[id] = timerSession
+			return &timerSession, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no long-running timer", models.ErrNotFound)
+}
+
+func (m *memoryService) AbandonRunningTimers(_ context.Context, userId, tag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for id, timerSession := range m.timers {
+		if timerSession.UserID == userId && timerSession.Tag == tag && timerSession.Status == models.StatusRunning {
+			timerSession.Status = models.StatusCompleted
+			timerSession.LastUpdated = now
+			m.timers[id] = timerSession
+		}
+	}
+	return nil
+}
+
+func (m *memoryService) CountRunningTimers(_ context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, timerSession := range m.timers {
+		if timerSession.Status == models.StatusRunning {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memoryService) UpdateUserTagStats(_ context.Context, userTagStats *models.UserTagStats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.tagStats[userTagStats.ID]; ok {
+		existing.TotalDuration = userTagStats.TotalDuration
+		existing.SessionCount = userTagStats.SessionCount
+		existing.LastUpdated = userTagStats.LastUpdated
+		m.tagStats[userTagStats.ID] = existing
+	}
+	return nil
+}
+
+func (m *memoryService) AddToUserTagStats(_ context.Context, userId, tag string, duration int64, sessions int, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId && userTagStats.Tag == tag {
+			userTagStats.TotalDuration += duration
+			userTagStats.SessionCount += sessions
+			if now.After(userTagStats.LastUpdated) {
+				userTagStats.LastUpdated = now
+			}
+			m.tagStats[id] = userTagStats
+			return nil
+		}
+	}
+
+	userTagStats := models.NewUserTagStats(userId, tag, now)
+	userTagStats.TotalDuration = duration
+	userTagStats.SessionCount = sessions
+	m.tagStats[userTagStats.ID] = *userTagStats
+	return nil
+}
+
+func (m *memoryService) UpdateTagSettings(_ context.Context, userId, tag string, settings models.TagSettings) (*models.UserTagStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId && userTagStats.Tag == tag {
+			userTagStats.TagSettings = settings
+			m.tagStats[id] = userTagStats
+			return &userTagStats, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no tag %q", models.ErrNotFound, tag)
+}
+
+func (m *memoryService) CreateUserTagStats(_ context.Context, userTagStats *models.UserTagStats) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tagStats[userTagStats.ID] = *userTagStats
+	return nil
+}
+
+func (m *memoryService) FindUserTagStats(_ context.Context, userId, tag string) (*models.UserTagStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId && userTagStats.Tag == tag {
+			return &userTagStats, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no stats for tag %q", models.ErrNotFound, tag)
+}
+
+func (m *memoryService) FindAllUserTagStats(_ context.Context, userId string) ([]*models.UserTagStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var tagStats []*models.UserTagStats
+	for _, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId {
+			tagStats = append(tagStats, &userTagStats)
+		}
+	}
+	// Mongo returns documents in insertion order; ObjectIDs sort the same way
+	sort.Slice(tagStats, func(i, j int) bool {
+		return tagStats[i].ID.Hex() < tagStats[j].ID.Hex()
+	})
+	return tagStats, nil
+}
+
+func (m *memoryService) GetStatsSummary(_ context.Context, userId, tag string, startDate, endDate time.Time, wallClock bool) (*models.StatsSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if wallClock {
+		var sessions []*models.TimerSession
+		for _, timerSession := range m.completedSessions(userId, startDate, endDate) {
+			if models.TagWithin(timerSession.Tag, tag) {
+				sessions = append(sessions, timerSession)
+			}
+		}
+		return wallClockSummary(sessions, tag), nil
+	}
+
+	byTag := make(map[string]*models.TagStats)
+	for _, timerSession := range m.completedSessions(userId, startDate, endDate) {
+		if !models.TagWithin(timerSession.Tag, tag) {
+			continue
+		}
+		tagStats, ok := byTag[timerSession.Tag]
+		if !ok {
+			tagStats = &models.TagStats{Tag: timerSession.Tag}
+			byTag[timerSession.Tag] = tagStats
+		}
+		tagStats.TotalDuration += timerSession.Duration
+		tagStats.SessionCount++
+	}
+
+	tagStatsList := make([]models.TagStats, 0, len(byTag))
+	for _, tagStats := range byTag {
+		tagStatsList = append(tagStatsList, *tagStats)
+	}
+	sort.Slice(tagStatsList, func(i, j int) bool {
+		if tagStatsList[i].TotalDuration != tagStatsList[j].TotalDuration {
+			return tagStatsList[i].TotalDuration > tagStatsList[j].TotalDuration
+		}
+		return tagStatsList[i].Tag < tagStatsList[j].Tag
+	})
+
+	return summarizeTagStats(tagStatsList, tag), nil
+}
+
+func (m *memoryService) GetTagSessions(_ context.Context, userId, tag string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []*models.TimerSession
+	for _, timerSession := range m.completedSessions(userId, startDate, endDate) {
+		if models.TagWithin(timerSession.Tag, tag) {
+			sessions = append(sessions, timerSession)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.After(sessions[j].StartTime)
+	})
+	return sessions, nil
+}
+
+// SearchTimerSessions matches terms as plain substrings, where MongoDB's
+// text index matches whole words
+func (m *memoryService) SearchTimerSessions(_ context.Context, userId string, terms []string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []*models.TimerSession
+	for _, timerSession := range m.completedSessions(userId, startDate, endDate) {
+		if search.Matches(terms, timerSession.Tag, timerSession.Note) {
+			sessions = append(sessions, timerSession)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.After(sessions[j].StartTime)
+	})
+	return sessions, nil
+}
+
+// completedSessions returns the user's completed sessions that started within
+// [startDate, endDate]. Callers must hold the lock.
+func (m *memoryService) completedSessions(userId string, startDate, endDate time.Time) []*models.TimerSession {
+	var sessions []*models.TimerSession
+	for _, timerSession := range m.timers {
+		if timerSession.UserID != userId || timerSession.Status != models.StatusCompleted {
+			continue
+		}
+		if timerSession.StartTime.Before(startDate) || timerSession.StartTime.After(endDate) {
+			continue
+		}
+		sessions = append(sessions, &timerSession)
+	}
+	return sessions
+}
+
+func (m *memoryService) DeleteUserTagStats(_ context.Context, userId, tag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId && userTagStats.Tag == tag {
+			delete(m.tagStats, id)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *memoryService) DeleteTimerSession(_ context.Context, userId, tag string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, timerSession := range m.timers {
+		if timerSession.UserID == userId && timerSession.Tag == tag {
+			delete(m.timers, id)
+		}
+	}
+	return nil
+}
+
+func (m *memoryService) CreateSession(_ context.Context, session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *memoryService) FindSession(_ context.Context, id string) (*models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (m *memoryService) TouchSession(_ context.Context, id string, lastSeenAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, ok := m.sessions[id]; ok {
+		session.LastSeenAt = lastSeenAt
+		m.sessions[id] = session
+	}
+	return nil
+}
+
+func (m *memoryService) DeleteSession(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memoryService) DeleteUserSessions(_ context.Context, userId, exceptID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, session := range m.sessions {
+		if session.UserID == userId && id != exceptID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (m *memoryService) FindUserSessions(_ context.Context, userId string) ([]*models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.clock.Now()
+	var sessions []*models.Session
+	for _, session := range m.sessions {
+		if session.UserID == userId && session.ExpiresAt.After(now) {
+			sessions = append(sessions, &session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (m *memoryService) CreateDeviceAuthorization(_ context.Context, authorization *models.DeviceAuthorization) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.devices[authorization.ID] = *authorization
+	return nil
+}
+
+func (m *memoryService) FindDeviceAuthorization(_ context.Context, id string) (*models.DeviceAuthorization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	authorization, ok := m.devices[id]
+	if !ok {
+		return nil, nil
+	}
+	return &authorization, nil
+}
+
+func (m *memoryService) FindDeviceAuthorizationByUserCode(_ context.Context, userCode string) (*models.DeviceAuthorization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.clock.Now()
+	for _, authorization := range m.devices {
+		if authorization.UserCode == userCode && authorization.ExpiresAt.After(now) {
+			return &authorization, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryService) ApproveDeviceAuthorization(_ context.Context, id string, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if authorization, ok := m.devices[id]; ok {
+		approvedBy := *user
+		authorization.User = &approvedBy
+		m.devices[id] = authorization
+	}
+	return nil
+}
+
+func (m *memoryService) DeleteDeviceAuthorization(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.devices, id)
+	return nil
+}
+
+func (m *memoryService) ClaimDeviceAuthorization(_ context.Context, id string) (*models.DeviceAuthorization, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	authorization, ok := m.devices[id]
+	if !ok || !authorization.Approved() {
+		return nil, nil
+	}
+	delete(m.devices, id)
+	return &authorization, nil
+}
+
+func (m *memoryService) SavePushSubscription(_ context.Context, subscription *models.PushSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := *subscription
+	if existing, ok := m.pushSubscriptions[subscription.Endpoint]; ok {
+		saved.ID, saved.CreatedAt = existing.ID, existing.CreatedAt
+	}
+	m.pushSubscriptions[subscription.Endpoint] = saved
+	return nil
+}
+
+func (m *memoryService) FindPushSubscriptions(_ context.Context, userId string) ([]*models.PushSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var subscriptions []*models.PushSubscription
+	for _, subscription := range m.pushSubscriptions {
+		if subscription.UserID == userId {
+			subscriptions = append(subscriptions, &subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].CreatedAt.Before(subscriptions[j].CreatedAt)
+	})
+	return subscriptions, nil
+}
+
+func (m *memoryService) DeletePushSubscription(_ context.Context, userId, endpoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if subscription, ok := m.pushSubscriptions[endpoint]; !ok || subscription.UserID != userId {
+		return fmt.Errorf("%w: no push subscription for this endpoint", models.ErrNotFound)
+	}
+	delete(m.pushSubscriptions, endpoint)
+	return nil
+}
+
+func (m *memoryService) CreateWebhook(_ context.Context, webhook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[webhook.ID]; ok {
+		return fmt.Errorf("%w: webhook already exists", models.ErrConflict)
+	}
+	saved := *webhook
+	saved.Events = slices.Clone(webhook.Events)
+	m.webhooks[webhook.ID] = saved
+	return nil
+}
+
+func (m *memoryService) FindWebhooks(_ context.Context, userId string) ([]*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var webhooks []*models.Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userId {
+			webhook.Events = slices.Clone(webhook.Events)
+			webhooks = append(webhooks, &webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (m *memoryService) FindWebhook(_ context.Context, userId string, id primitive.ObjectID) (*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok || webhook.UserID != userId {
+		return nil, fmt.Errorf("%w: no webhook with this ID", models.ErrNotFound)
+	}
+	webhook.Events = slices.Clone(webhook.Events)
+	return &webhook, nil
+}
+
+func (m *memoryService) DeleteWebhook(_ context.Context, userId string, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if webhook, ok := m.webhooks[id]; !ok || webhook.UserID != userId {
+		return fmt.Errorf("%w: no webhook with this ID", models.ErrNotFound)
+	}
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.webhookDeliveries {
+		if delivery.WebhookID == id {
+			delete(m.webhookDeliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (m *memoryService) CreateWebhookDeliveries(_ context.Context, deliveries []*models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		m.webhookDeliveries[delivery.ID] = *delivery
+	}
+	return nil
+}
+
+func (m *memoryService) ClaimWebhookDelivery(_ context.Context, now, leaseUntil time.Time, skipWebhooks []primitive.ObjectID) (*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var due *models.WebhookDelivery
+	for _, delivery := range m.webhookDeliveries {
+		if delivery.Status != models.DeliveryPending || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if slices.Contains(skipWebhooks, delivery.WebhookID) {
+			continue
+		}
+		if due == nil || delivery.NextAttemptAt.Before(*due.NextAttemptAt) ||
+			(delivery.NextAttemptAt.Equal(*due.NextAttemptAt) && delivery.ID.Hex() < due.ID.Hex()) {
+			due = &delivery
+		}
+	}
+	if due == nil {
+		return nil, fmt.Errorf("%w: no webhook delivery due", models.ErrNotFound)
+	}
+	due.NextAttemptAt = &leaseUntil
+	m.webhookDeliveries[due.ID] = *due
+	return due, nil
+}
+
+func (m *memoryService) UpdateWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like Mongo's update, a delivery deleted meanwhile stays deleted
+	if _, ok := m.webhookDeliveries[delivery.ID]; ok {
+		m.webhookDeliveries[delivery.ID] = *delivery
+	}
+	return nil
+}
+
+func (m *memoryService) FindWebhookDeliveries(_ context.Context, userId string, webhookID primitive.ObjectID, limit int) ([]*models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var deliveries []*models.WebhookDelivery
+	for _, delivery := range m.webhookDeliveries {
+		if delivery.WebhookID == webhookID && delivery.UserID == userId {
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID.Hex() > deliveries[j].ID.Hex()
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// EnsureIndexes is a no-op; maps need no indexes
+func (m *memoryService) EnsureIndexes(_ context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *memoryService) MissingIndexes(_ context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *memoryService) ListUsers(_ context.Context) ([]*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []*models.User
+	for _, user := range m.users {
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+	return users, nil
+}
+
+func (m *memoryService) CountUserData(_ context.Context, userId string) (*UserDataCounts, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.userDataCounts(userId), nil
+}
+
+func (m *memoryService) PurgeUser(_ context.Context, userId string) (*UserDataCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := m.userDataCounts(userId)
+	delete(m.users, userId)
+	for id, timerSession := range m.timers {
+		if timerSession.UserID == userId {
+			delete(m.timers, id)
+		}
+	}
+	for id, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId {
+			delete(m.tagStats, id)
+		}
+	}
+	for id, session := range m.sessions {
+		if session.UserID == userId {
+			delete(m.sessions, id)
+		}
+	}
+	for id, authorization := range m.devices {
+		if authorization.User != nil && authorization.User.ID == userId {
+			delete(m.devices, id)
+		}
+	}
+	for endpoint, subscription := range m.pushSubscriptions {
+		if subscription.UserID == userId {
+			delete(m.pushSubscriptions, endpoint)
+		}
+	}
+	for id, webhook := range m.webhooks {
+		if webhook.UserID == userId {
+			delete(m.webhooks, id)
+		}
+	}
+	for id, delivery := range m.webhookDeliveries {
+		if delivery.UserID == userId {
+			delete(m.webhookDeliveries, id)
+		}
+	}
+	return counts, nil
+}
+
+// userDataCounts counts the user's documents. Callers must hold the lock.
+func (m *memoryService) userDataCounts(userId string) *UserDataCounts {
+	var counts UserDataCounts
+	if _, ok := m.users[userId]; ok {
+		counts.Users = 1
+	}
+	for _, timerSession := range m.timers {
+		if timerSession.UserID == userId {
+			counts.TimerSessions++
+		}
+	}
+	for _, userTagStats := range m.tagStats {
+		if userTagStats.UserID == userId {
+			counts.TagStats++
+		}
+	}
+	for _, session := range m.sessions {
+		if session.UserID == userId {
+			counts.Sessions++
+		}
+	}
+	for _, subscription := range m.pushSubscriptions {
+		if subscription.UserID == userId {
+			counts.PushSubscriptions++
+		}
+	}
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userId {
+			counts.Webhooks++
+		}
+	}
+	for _, delivery := range m.webhookDeliveries {
+		if delivery.UserID == userId {
+			counts.WebhookDeliveries++
+		}
+	}
+	return &counts
+}
+
+func (m *memoryService) RecomputeUserTagStats(_ context.Context) ([]*models.UserTagStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type key struct{ userID, tag string }
+	byTag := make(map[key]*models.UserTagStats)
+	for _, timerSession := range m.timers {
+		k := key{timerSession.UserID, timerSession.Tag}
+		userTagStats, ok := byTag[k]
+		if !ok {
+			userTagStats = &models.UserTagStats{UserID: timerSession.UserID, Tag: timerSession.Tag}
+			byTag[k] = userTagStats
+		}
+		userTagStats.TotalDuration += timerSession.Duration
+		userTagStats.SessionCount++
+		if timerSession.LastUpdated.After(userTagStats.LastUpdated) {
+			userTagStats.LastUpdated = timerSession.LastUpdated
+		}
+	}
+
+	tagStats := make([]*models.UserTagStats, 0, len(byTag))
+	for _, userTagStats := range byTag {
+		tagStats = append(tagStats, userTagStats)
+	}
+	sort.Slice(tagStats, func(i, j int) bool {
+		if tagStats[i].UserID != tagStats[j].UserID {
+			return tagStats[i].UserID < tagStats[j].UserID
+		}
+		return tagStats[i].Tag < tagStats[j].Tag
+	})
+	return tagStats, nil
+}
+
+func (m *memoryService) ListAllUserTagStats(_ context.Context) ([]*models.UserTagStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tagStats := make([]*models.UserTagStats, 0, len(m.tagStats))
+	for _, userTagStats := range m.tagStats {
+		tagStats = append(tagStats, &userTagStats)
+	}
+	sort.Slice(tagStats, func(i, j int) bool {
+		return tagStats[i].ID.Hex() < tagStats[j].ID.Hex()
+	})
+	return tagStats, nil
+}
+
+func (m *memoryService) DeleteUserTagStatsByID(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tagStats, id)
+	return nil
+}
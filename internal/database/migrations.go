@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Migration is a versioned change to stored documents. Migrations run in
+// version order and each is recorded once it succeeds, so a failed run
+// resumes from the migration that failed. A migration must therefore be safe
+// to rerun after being interrupted part way.
+type Migration struct {
+	Version     int
+	Description string
+}
+
+// migrationRecord is stored in the migrations collection for every applied
+// migration
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// migrations lists every migration in version order. Append new ones with the
+// next version; never renumber or remove an entry.
+var migrations = []struct {
+	Migration
+	mongo  func(*service, context.Context) error
+	memory func(*memoryService)
+}{
+	{
+		Migration: Migration{Version: 1, Description: "Move users keyed by OAuth provider ID onto application-generated IDs"},
+		mongo:     (*service).migrateLegacyUserIDs,
+		memory:    (*memoryService).migrateLegacyUserIDs,
+	},
+}
+
+// pendingMigrations returns the migrations whose versions are not in applied
+func pendingMigrations(applied map[int]bool) []Migration {
+	var pending []Migration
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration.Migration)
+		}
+	}
+	return pending
+}
+
+func (s *service) getMigrationsCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("migrations")
+}
+
+func (s *service) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pendingMigrations(applied), nil
+}
+
+// Migrate runs every pending migration and returns those it applied. It
+// stops at the first failure.
+func (s *service) Migrate(ctx context.Context) ([]Migration, error) {
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err = migration.mongo(s, ctx); err != nil {
+			return ran, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		record := migrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   s.clock.Now(),
+		}
+		// Another instance may have finished the same migration concurrently
+		if _, err = s.getMigrationsCollection().InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return ran, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+		ran = append(ran, migration.Migration)
+	}
+	return ran, nil
+}
+
+func (s *service) appliedMigrations(ctx context.Context) (map[int]bool, error) {
+	cursor, err := s.getMigrationsCollection().Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var records []migrationRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+	return applied, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func TestMigrationVersionsAreOrdered(t *testing.T) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d, want %d", i, migration.Version, i+1)
+		}
+		if migration.mongo == nil || migration.memory == nil {
+			t.Errorf("migration %d is missing an implementation", migration.Version)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+	db := NewMemory(clock.NewFake(now))
+	m := db.(*memoryService)
+
+	// A user from before application-generated IDs
+	m.users["google-123"] = models.User{ID: "google-123", Provider: "google", ProviderID: "google-123"}
+	if err := db.CreateTimerSession(ctx, models.NewTimerSession("google-123", "coding", now)); err != nil {
+		t.Fatalf("CreateTimerSession: %v", err)
+	}
+
+	pending, err := db.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatalf("PendingMigrations: %v", err)
+	}
+	if len(pending) != len(migrations) {
+		t.Fatalf("pending = %d migrations, want %d", len(pending), len(migrations))
+	}
+
+	applied, err := db.Migrate(ctx)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("applied = %d migrations, want %d", len(applied), len(migrations))
+	}
+
+	users, _ := db.ListUsers(ctx)
+	if len(users) != 1 || users[0].ID == "google-123" {
+		t.Fatalf("users after migration = %+v, want one user with a new ID", users)
+	}
+	if _, err = db.FindTimerSession(ctx, users[0].ID, "coding", models.StatusRunning); err != nil {
+		t.Errorf("timer session did not follow the user: %v", err)
+	}
+
+	// Applied migrations are not run again
+	if applied, err = db.Migrate(ctx); err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate = %v, %v; want nothing applied", applied, err)
+	}
+	if pending, err = db.PendingMigrations(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("PendingMigrations after Migrate = %v, %v; want none", pending, err)
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func (s *service) getPushSubscriptionsCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("push_subscriptions")
+}
+
+// SavePushSubscription stores a subscription, replacing the one with the same
+// endpoint. A browser keeps its endpoint when another user logs in to it, so
+// the subscription moves to whoever saved it last.
+func (s *service) SavePushSubscription(ctx context.Context, subscription *models.PushSubscription) error {
+	collection := s.getPushSubscriptionsCollection()
+	filter := bson.M{"endpoint": subscription.Endpoint}
+	update := bson.M{
+		"$set": bson.M{
+			"user_id":    subscription.UserID,
+			"p256dh":     subscription.P256dh,
+			"auth":       subscription.Auth,
+			"user_agent": subscription.UserAgent,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        subscription.ID,
+			"created_at": subscription.CreatedAt,
+		},
+	}
+	if _, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return fmt.Errorf("failed to save push subscription: %w", err)
+	}
+	return nil
+}
+
+// FindPushSubscriptions returns the user's subscriptions, oldest first
+func (s *service) FindPushSubscriptions(ctx context.Context, userId string) ([]*models.PushSubscription, error) {
+	collection := s.getPushSubscriptionsCollection()
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var subscriptions []*models.PushSubscription
+	if err = cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeletePushSubscription removes the user's subscription with the given
+// endpoint
+func (s *service) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	collection := s.getPushSubscriptionsCollection()
+	result, err := collection.DeleteOne(ctx, bson.M{"user_id": userId, "endpoint": endpoint})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("%w: no push subscription for this endpoint", models.ErrNotFound)
+	}
+	return nil
+}
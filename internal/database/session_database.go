@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func (s *service) getSessionsCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("sessions")
+}
+
+func (s *service) CreateSession(ctx context.Context, session *models.Session) error {
+	collection := s.getSessionsCollection()
+	if _, err := collection.InsertOne(ctx, session); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindSession returns the session with the given ID, or nil if it does not exist
+func (s *service) FindSession(ctx context.Context, id string) (*models.Session, error) {
+	collection := s.getSessionsCollection()
+
+	var session models.Session
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *service) TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error {
+	collection := s.getSessionsCollection()
+	update := bson.M{"$set": bson.M{"last_seen_at": lastSeenAt}}
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *service) DeleteSession(ctx context.Context, id string) error {
+	collection := s.getSessionsCollection()
+	if _, err := collection.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteUserSessions deletes every session of a user except exceptID, which
+// may be empty to delete them all
+func (s *service) DeleteUserSessions(ctx context.Context, userId, exceptID string) error {
+	collection := s.getSessionsCollection()
+	filter := bson.M{"user_id": userId}
+	if exceptID != "" {
+		filter["_id"] = bson.M{"$ne": exceptID}
+	}
+	if _, err := collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindUserSessions returns the unexpired sessions of a user, most recently used first
+func (s *service) FindUserSessions(ctx context.Context, userId string) ([]*models.Session, error) {
+	collection := s.getSessionsCollection()
+	filter := bson.M{
+		"user_id":    userId,
+		"expires_at": bson.M{"$gt": s.clock.Now()},
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"last_seen_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var sessions []*models.Session
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+const (
+	benchUsers           = 100
+	benchTags            = 20
+	benchSessionsPerUser = 2000
+)
+
+// BenchmarkStatsQueries measures the stats queries against a seeded
+// database, first without and then with the application's indexes. It needs
+// the same database configuration as the server and writes to a scratch database
+// that is dropped afterwards:
+//
+//	go test -run '^$' -bench StatsQueries ./internal/database
+func BenchmarkStatsQueries(b *testing.B) {
+	cfg, err := config.Load()
+	if err != nil {
+		b.Skipf("no usable database configuration: %v", err)
+	}
+	cfg.Database.Name = fmt.Sprintf("productivity_timer_bench_%d", time.Now().UnixNano())
+
+	ctx := context.Background()
+	db, err := New(cfg.Database, clock.New())
+	if err != nil {
+		b.Fatalf("New: %v", err)
+	}
+	s := db.(*service)
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	_, err = s.Health(pingCtx)
+	cancel()
+	if err != nil {
+		b.Skipf("database unreachable: %v", err)
+	}
+	b.Cleanup(func() {
+		if err := s.db.Database(s.name).Drop(ctx); err != nil {
+			b.Logf("dropping %s: %v", s.name, err)
+		}
+	})
+
+	end := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err = seedBenchData(ctx, s, end); err != nil {
+		b.Fatalf("seeding: %v", err)
+	}
+
+	userID := benchUserID(benchUsers / 2)
+	weekStart := end.AddDate(0, 0, -7)
+	queries := []struct {
+		name string
+		run  func() error
+	}{
+		{"GetStatsSummary/week", func() error {
+			_, err := s.GetStatsSummary(ctx, userID, "", weekStart, end, false)
+			return err
+		}},
+		{"GetStatsSummary/all", func() error {
+			_, err := s.GetStatsSummary(ctx, userID, "", time.Time{}, end, false)
+			return err
+		}},
+		{"GetTagSessions/week", func() error {
+			_, err := s.GetTagSessions(ctx, userID, benchTag(0), weekStart, end)
+			return err
+		}},
+		{"FindTimerSession", func() error {
+			_, err := s.FindTimerSession(ctx, userID, benchTag(0), models.StatusCompleted)
+			return err
+		}},
+		{"FindAllUserTagStats", func() error {
+			_, err := s.FindAllUserTagStats(ctx, userID)
+			return err
+		}},
+	}
+
+	for _, indexed := range []bool{false, true} {
+		if indexed {
+			if _, err := s.EnsureIndexes(ctx); err != nil {
+				b.Fatalf("EnsureIndexes: %v", err)
+			}
+		}
+		for _, query := range queries {
+			b.Run(fmt.Sprintf("%s/indexed=%t", query.name, indexed), func(b *testing.B) {
+				for b.Loop() {
+					if err := query.run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// seedBenchData inserts benchSessionsPerUser completed sessions for each user
+// spread over the year before end, plus their tag stats
+func seedBenchData(ctx context.Context, s *service, end time.Time) error {
+	random := rand.New(rand.NewSource(1))
+	timers := s.getTimerSessionsCollection()
+	tagStats := s.getUserTagStatsCollection()
+	insertOptions := options.InsertMany().SetOrdered(false)
+
+	for u := range benchUsers {
+		userID := benchUserID(u)
+		totals := make([]int64, benchTags)
+		counts := make([]int, benchTags)
+
+		documents := make([]any, 0, benchSessionsPerUser)
+		for range benchSessionsPerUser {
+			tag := random.Intn(benchTags)
+			start := end.Add(-time.Duration(random.Int63n(int64(365 * 24 * time.Hour))))
+			duration := 60 + random.Int63n(2*60*60)
+			stop := start.Add(time.Duration(duration) * time.Second)
+			documents = append(documents, models.TimerSession{
+				ID:          primitive.NewObjectID(),
+				UserID:      userID,
+				Tag:         benchTag(tag),
+				StartTime:   start,
+				EndTime:     &stop,
+				Duration:    duration,
+				Status:      models.StatusCompleted,
+				CreatedAt:   start,
+				LastUpdated: stop,
+			})
+			totals[tag] += duration
+			counts[tag]++
+		}
+		if _, err := timers.InsertMany(ctx, documents, insertOptions); err != nil {
+			return err
+		}
+
+		documents = documents[:0]
+		for tag := range benchTags {
+			documents = append(documents, models.UserTagStats{
+				ID:            primitive.NewObjectID(),
+				UserID:        userID,
+				Tag:           benchTag(tag),
+				TotalDuration: totals[tag],
+				SessionCount:  counts[tag],
+				LastUpdated:   end,
+			})
+		}
+		if _, err := tagStats.InsertMany(ctx, documents, insertOptions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func benchUserID(n int) string {
+	return fmt.Sprintf("bench-user-%03d", n)
+}
+
+func benchTag(n int) string {
+	return fmt.Sprintf("tag-%02d", n)
+}
@@ -2,17 +2,22 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/search"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func (s *service) getTimerSessionsCollection() *mongo.Collection {
-	return s.db.Database(database).Collection("timers")
+	return s.db.Database(s.name).Collection("timers")
 }
 
 func (s *service) UpdateTimerSession(ctx context.Context, timerSession *models.TimerSession) error {
@@ -26,6 +31,37 @@ func (s *service) UpdateTimerSession(ctx context.Context, timerSession *models.T
 	return nil
 }
 
+// UpdateTimerSessionNote sets only a session's note, so it cannot undo a
+// concurrent pause or stop of the same timer. It returns ErrNotFound when the
+// session is gone.
+func (s *service) UpdateTimerSessionNote(ctx context.Context, id primitive.ObjectID, note string) error {
+	collection := s.getTimerSessionsCollection()
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"note": note}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: timer session %s", models.ErrNotFound, id.Hex())
+	}
+	return nil
+}
+
+// PauseTimerSession saves timerSession over the stored session only while
+// that is still running since runningSince, so of several requests stopping
+// the same run exactly one succeeds. The others get ErrConflict.
+func (s *service) PauseTimerSession(ctx context.Context, timerSession *models.TimerSession, runningSince time.Time) error {
+	collection := s.getTimerSessionsCollection()
+	filter := bson.M{"_id": timerSession.ID, "status": models.StatusRunning, "last_updated": runningSince}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": timerSession})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: timer session %s is no longer running", models.ErrConflict, timerSession.ID.Hex())
+	}
+	return nil
+}
+
 func (s *service) CreateTimerSession(ctx context.Context, timerSession *models.TimerSession) error {
 	collection := s.getTimerSessionsCollection()
 	if _, err := collection.InsertOne(ctx, timerSession); err != nil {
@@ -41,6 +77,107 @@ func (s *service) FindTimerSession(ctx context.Context, userId, tag string, stat
 	var timerSession models.TimerSession
 	err := collection.FindOne(ctx, filter).Decode(&timerSession)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: no %s timer for tag %q", models.ErrNotFound, status, tag)
+		}
+		return nil, err
+	}
+	return &timerSession, nil
+}
+
+// FindActiveTimerSession returns the user's most recently updated running or
+// stopped (not yet reset) timer session
+func (s *service) FindActiveTimerSession(ctx context.Context, userId string) (*models.TimerSession, error) {
+	collection := s.getTimerSessionsCollection()
+	filter := bson.M{
+		"user_id": userId,
+		"status":  bson.M{"$in": bson.A{models.StatusRunning, models.StatusStopped}},
+	}
+	opts := options.FindOne().SetSort(bson.M{"last_updated": -1})
+
+	var timerSession models.TimerSession
+	err := collection.FindOne(ctx, filter, opts).Decode(&timerSession)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: no active timer", models.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &timerSession, nil
+}
+
+// FindActiveTimerSessions returns all of the user's running or stopped timer
+// sessions in the order they were started
+func (s *service) FindActiveTimerSessions(ctx context.Context, userId string) ([]*models.TimerSession, error) {
+	collection := s.getTimerSessionsCollection()
+	filter := bson.M{
+		"user_id": userId,
+		"status":  bson.M{"$in": bson.A{models.StatusRunning, models.StatusStopped}},
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"start_time": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			return
+		}
+	}(cursor, ctx)
+
+	var timerSessions []*models.TimerSession
+	if err = cursor.All(ctx, &timerSessions); err != nil {
+		return nil, err
+	}
+	return timerSessions, nil
+}
+
+// FindDueTimerSessions returns every user's running countdowns that reached
+// zero by now
+func (s *service) FindDueTimerSessions(ctx context.Context, now time.Time) ([]*models.TimerSession, error) {
+	collection := s.getTimerSessionsCollection()
+	// $type matches the due_at index's partial filter, so the index is used
+	filter := bson.M{
+		"status": models.StatusRunning,
+		"due_at": bson.M{"$type": "date", "$lte": now},
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			return
+		}
+	}(cursor, ctx)
+
+	var timerSessions []*models.TimerSession
+	if err = cursor.All(ctx, &timerSessions); err != nil {
+		return nil, err
+	}
+	return timerSessions, nil
+}
+
+// ClaimLongRunningTimerSession marks one of any user's timers that has run
+// without a break since runningSince as alerted at now and returns it. Each
+// run is claimed once, however many servers ask. It returns ErrNotFound when
+// there are none left.
+func (s *service) ClaimLongRunningTimerSession(ctx context.Context, runningSince, now time.Time) (*models.TimerSession, error) {
+	collection := s.getTimerSessionsCollection()
+	filter := bson.M{
+		"status":       models.StatusRunning,
+		"last_updated": bson.M{"$lte": runningSince},
+		"alerted_at":   nil,
+	}
+	update := bson.M{"$set": bson.M{"alerted_at": now}}
+
+	var timerSession models.TimerSession
+	err := collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&timerSession)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no long-running timer", models.ErrNotFound)
+	} else if err != nil {
 		return nil, err
 	}
 	return &timerSession, nil
@@ -53,28 +190,51 @@ func (s *service) AbandonRunningTimers(ctx context.Context, userId, tag string)
 	filter := bson.M{"user_id": userId, "tag": tag, "status": models.StatusRunning}
 	update := bson.M{"$set": bson.M{
 		"status":       models.StatusCompleted,
-		"last_updated": time.Now(),
+		"last_updated": s.clock.Now(),
 	}}
 
 	_, err := collection.UpdateMany(ctx, filter, update)
 	return err
 }
 
-// GetStatsSummary aggregates timer sessions for a user within a time period
-func (s *service) GetStatsSummary(ctx context.Context, userId string, startDate, endDate time.Time) (*models.StatsSummary, error) {
+// CountRunningTimers returns the number of running timers across all users.
+// It counts the entries of the partial index of running sessions; no index
+// has status as its first key, so the query alone would scan every session.
+func (s *service) CountRunningTimers(ctx context.Context) (int64, error) {
+	opts := options.Count().SetHint(runningTimersIndex)
+	return s.getTimerSessionsCollection().CountDocuments(ctx, bson.M{"status": models.StatusRunning}, opts)
+}
+
+// GetStatsSummary aggregates timer sessions for a user within a time period.
+// The wall-clock summary shares the time when several timers ran at once
+// between their tags instead of counting it for each.
+func (s *service) GetStatsSummary(ctx context.Context, userId, tag string, startDate, endDate time.Time, wallClock bool) (*models.StatsSummary, error) {
+	if wallClock {
+		sessions, err := s.GetTagSessions(ctx, userId, tag, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		return wallClockSummary(sessions, tag), nil
+	}
+
 	collection := s.getTimerSessionsCollection()
 
+	match := bson.M{
+		"user_id": userId,
+		"status":  models.StatusCompleted,
+		"start_time": bson.M{
+			"$gte": startDate,
+			"$lte": endDate,
+		},
+	}
+	if tag != "" {
+		match["tag"] = tagTreeFilter(tag)
+	}
+
 	// MongoDB aggregation pipeline to group by tag and sum durations
 	pipeline := mongo.Pipeline{
 		// Match user's completed sessions within the time range
-		{{Key: "$match", Value: bson.M{
-			"user_id": userId,
-			"status":  models.StatusCompleted,
-			"start_time": bson.M{
-				"$gte": startDate,
-				"$lte": endDate,
-			},
-		}}},
+		{{Key: "$match", Value: match}},
 		// Group by tag and calculate totals
 		{{Key: "$group", Value: bson.M{
 			"_id":            "$tag",
@@ -100,6 +260,22 @@ func (s *service) GetStatsSummary(ctx context.Context, userId string, startDate,
 		return nil, err
 	}
 
+	return summarizeTagStats(tagStatsList, tag), nil
+}
+
+// tagTreeFilter matches tag and every tag nested under it. The anchored
+// prefix regex can still use the tag indexes.
+func tagTreeFilter(tag string) bson.M {
+	return bson.M{"$in": bson.A{
+		tag,
+		primitive.Regex{Pattern: "^" + regexp.QuoteMeta(tag+models.TagSeparator)},
+	}}
+}
+
+// summarizeTagStats builds a summary from per-tag totals sorted by duration
+// descending, filling in percentages and averages and rolling them up into a
+// tree below root
+func summarizeTagStats(tagStatsList []models.TagStats, root string) *models.StatsSummary {
 	// Calculate summary statistics
 	summary := &models.StatsSummary{
 		TagBreakdown: tagStatsList,
@@ -120,6 +296,8 @@ func (s *service) GetStatsSummary(ctx context.Context, userId string, startDate,
 		}
 	}
 
+	summary.TagTree = models.BuildTagTree(tagStatsList, root, summary.TotalDuration)
+
 	// Set most used tag (first one after sorting by duration desc)
 	if len(tagStatsList) > 0 {
 		summary.MostUsedTag = tagStatsList[0].Tag
@@ -130,22 +308,34 @@ func (s *service) GetStatsSummary(ctx context.Context, userId string, startDate,
 		summary.AverageSession = summary.TotalDuration / int64(summary.TotalSessions)
 	}
 
-	return summary, nil
+	return summary
+}
+
+// wallClockSummary summarizes sessions by the time they ran, counting each
+// moment once
+func wallClockSummary(sessions []*models.TimerSession, root string) *models.StatsSummary {
+	summary := summarizeTagStats(models.WallClockTagStats(sessions), root)
+	summary.WallClock = true
+	return summary
 }
 
-// GetTagSessions retrieves individual timer sessions for a specific tag within a time period
+// GetTagSessions retrieves individual timer sessions for a specific tag, and
+// the tags nested under it, within a time period. An empty tag matches every
+// session.
 func (s *service) GetTagSessions(ctx context.Context, userId, tag string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
 	collection := s.getTimerSessionsCollection()
 
 	filter := bson.M{
 		"user_id": userId,
-		"tag":     tag,
 		"status":  models.StatusCompleted,
 		"start_time": bson.M{
 			"$gte": startDate,
 			"$lte": endDate,
 		},
 	}
+	if tag != "" {
+		filter["tag"] = tagTreeFilter(tag)
+	}
 
 	// Sort by start_time descending (most recent first)
 	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"start_time": -1}))
@@ -166,6 +356,43 @@ func (s *service) GetTagSessions(ctx context.Context, userId, tag string, startD
 	return sessions, nil
 }
 
+// SearchTimerSessions returns the user's completed sessions within the period
+// whose tag or note contains every term, most recent first. It relies on the
+// user_id_tag_note_text index.
+func (s *service) SearchTimerSessions(ctx context.Context, userId string, terms []string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	collection := s.getTimerSessionsCollection()
+
+	filter := bson.M{
+		"user_id": userId,
+		"$text":   bson.M{"$search": search.TextSearch(terms)},
+		"status":  models.StatusCompleted,
+		"start_time": bson.M{
+			"$gte": startDate,
+			"$lte": endDate,
+		},
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"start_time": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			return
+		}
+	}(cursor, ctx)
+
+	var sessions []*models.TimerSession
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
 func (s *service) DeleteTimerSession(ctx context.Context, userId, tag string) error {
 	collection := s.getTimerSessionsCollection()
 
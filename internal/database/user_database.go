@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/neilsmahajan/productivity-timer/internal/models"
 
@@ -13,7 +12,7 @@ import (
 )
 
 func (s *service) getUsersCollection() *mongo.Collection {
-	return s.db.Database(database).Collection("users")
+	return s.db.Database(s.name).Collection("users")
 }
 
 func (s *service) FindOrCreateUser(ctx context.Context, user *models.User) (*models.User, error) {
@@ -25,6 +24,7 @@ func (s *service) FindOrCreateUser(ctx context.Context, user *models.User) (*mod
 		"provider_id": user.ProviderID,
 	}
 
+	now := s.clock.Now()
 	var existingUser models.User
 	err := collection.FindOne(ctx, filter).Decode(&existingUser)
 
@@ -32,7 +32,7 @@ func (s *service) FindOrCreateUser(ctx context.Context, user *models.User) (*mod
 		// User exists, update last login
 		update := bson.M{
 			"$set": bson.M{
-				"last_login_at": time.Now(),
+				"last_login_at": now,
 				"email":         user.Email,
 				"name":          user.Name,
 				"first_name":    user.FirstName,
@@ -45,7 +45,7 @@ func (s *service) FindOrCreateUser(ctx context.Context, user *models.User) (*mod
 		if err != nil {
 			return nil, fmt.Errorf("failed to update user: %w", err)
 		}
-		existingUser.LastLoginAt = time.Now()
+		existingUser.LastLoginAt = now
 		return &existingUser, nil
 	}
 
@@ -53,12 +53,20 @@ func (s *service) FindOrCreateUser(ctx context.Context, user *models.User) (*mod
 		return nil, fmt.Errorf("database error: %w", err)
 	}
 
-	// User doesn't exist, create new one
-	now := time.Now()
+	// User doesn't exist, create new one with an application-generated ID
+	user.ID = models.NewUserID()
 	user.CreatedAt = now
 	user.LastLoginAt = now
 
 	_, err = collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent first login created the user since the lookup, and
+		// set the same profile and login time
+		if err = collection.FindOne(ctx, filter).Decode(&existingUser); err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		return &existingUser, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -80,3 +88,97 @@ func (s *service) GetUserByID(ctx context.Context, id string) (*models.User, err
 
 	return &user, nil
 }
+
+// SetConcurrentTimers turns the user's concurrent timers on or off
+func (s *service) SetConcurrentTimers(ctx context.Context, userId string, enabled bool) error {
+	collection := s.getUsersCollection()
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": userId}, bson.M{"$set": bson.M{"concurrent_timers": enabled}})
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: no user %s", models.ErrNotFound, userId)
+	}
+	return nil
+}
+
+// legacyUser is a user still keyed by their OAuth provider ID.
+// LegacyProviderID holds the provider ID once migrateLegacyUser has moved it
+// out of provider_id, so the legacy user and their new copy never share one.
+type legacyUser struct {
+	models.User      `bson:",inline"`
+	LegacyProviderID string `bson:"legacy_provider_id,omitempty"`
+}
+
+// migrateLegacyUserIDs moves users whose ID is still their OAuth provider ID
+// onto an application-generated ID, rewriting the user_id of their timer
+// sessions and tag stats. It resumes cleanly after a partial run.
+func (s *service) migrateLegacyUserIDs(ctx context.Context) error {
+	collection := s.getUsersCollection()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$provider_id"}}},
+		bson.M{"legacy_provider_id": bson.M{"$exists": true}},
+	}}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find legacy users: %w", err)
+	}
+	var legacyUsers []legacyUser
+	if err = cursor.All(ctx, &legacyUsers); err != nil {
+		return fmt.Errorf("failed to decode legacy users: %w", err)
+	}
+
+	for i := range legacyUsers {
+		if err = s.migrateLegacyUser(ctx, &legacyUsers[i]); err != nil {
+			return fmt.Errorf("failed to migrate user %s: %w", legacyUsers[i].ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *service) migrateLegacyUser(ctx context.Context, legacy *legacyUser) error {
+	users := s.getUsersCollection()
+
+	// Move the provider ID aside first, so the new user can take it without
+	// two users ever sharing it. An interrupted earlier run may have done so.
+	if legacy.LegacyProviderID == "" {
+		filter := bson.M{"_id": legacy.ID, "provider_id": legacy.ProviderID}
+		update := bson.M{"$rename": bson.M{"provider_id": "legacy_provider_id"}}
+		if _, err := users.UpdateOne(ctx, filter, update); err != nil {
+			return fmt.Errorf("failed to set aside provider ID: %w", err)
+		}
+	} else {
+		legacy.ProviderID = legacy.LegacyProviderID
+	}
+
+	// Reuse the user created by an interrupted earlier run, or by a login
+	// since the provider ID was set aside, if any
+	var newUser models.User
+	filter := bson.M{"provider": legacy.Provider, "provider_id": legacy.ProviderID}
+	err := users.FindOne(ctx, filter).Decode(&newUser)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		newUser = legacy.User
+		newUser.ID = models.NewUserID()
+		if _, err = users.InsertOne(ctx, &newUser); err != nil {
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	ownerFilter := bson.M{"user_id": legacy.ID}
+	update := bson.M{"$set": bson.M{"user_id": newUser.ID}}
+	if _, err = s.getTimerSessionsCollection().UpdateMany(ctx, ownerFilter, update); err != nil {
+		return fmt.Errorf("failed to update timer sessions: %w", err)
+	}
+	if _, err = s.getUserTagStatsCollection().UpdateMany(ctx, ownerFilter, update); err != nil {
+		return fmt.Errorf("failed to update tag stats: %w", err)
+	}
+
+	if _, err = users.DeleteOne(ctx, bson.M{"_id": legacy.ID}); err != nil {
+		return fmt.Errorf("failed to delete legacy user: %w", err)
+	}
+	return nil
+}
@@ -2,30 +2,78 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/neilsmahajan/productivity-timer/internal/models"
 )
 
 func (s *service) getUserTagStatsCollection() *mongo.Collection {
-	return s.db.Database(database).Collection("tagstats")
+	return s.db.Database(s.name).Collection("tagstats")
 }
 
+// UpdateUserTagStats saves the totals of userTagStats. Settings are saved by
+// UpdateTagSettings, so a timer stopping cannot undo an edit made meanwhile.
 func (s *service) UpdateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error {
 	collection := s.getUserTagStatsCollection()
 	filter := bson.M{"_id": userTagStats.ID}
+	update := bson.M{"$set": bson.M{
+		"total_duration": userTagStats.TotalDuration,
+		"session_count":  userTagStats.SessionCount,
+		"last_updated":   userTagStats.LastUpdated,
+	}}
 
-	if _, err := collection.UpdateOne(ctx, filter, bson.M{"$set": userTagStats}); err != nil {
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// AddToUserTagStats adds duration seconds and sessions to the totals of the
+// user's tag, creating its stats on first use. The totals are incremented in
+// place, so timers of one tag stopping at once do not lose each other's time.
+func (s *service) AddToUserTagStats(ctx context.Context, userId, tag string, duration int64, sessions int, now time.Time) error {
+	collection := s.getUserTagStatsCollection()
+	filter := bson.M{"user_id": userId, "tag": tag}
+	update := bson.M{
+		"$inc":         bson.M{"total_duration": duration, "session_count": sessions},
+		"$max":         bson.M{"last_updated": now},
+		"$setOnInsert": models.TagSettings{},
+	}
+
+	// Two first uses of a tag can both try to insert it; the loser finds the
+	// winner's document when it tries again
+	opts := options.Update().SetUpsert(true)
+	_, err := collection.UpdateOne(ctx, filter, update, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		_, err = collection.UpdateOne(ctx, filter, update, opts)
+	}
+	return err
+}
+
+// UpdateTagSettings replaces the settings of the user's tag and returns it
+func (s *service) UpdateTagSettings(ctx context.Context, userId, tag string, settings models.TagSettings) (*models.UserTagStats, error) {
+	collection := s.getUserTagStatsCollection()
+	filter := bson.M{"user_id": userId, "tag": tag}
+
+	var updated models.UserTagStats
+	err := collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": settings},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no tag %q", models.ErrNotFound, tag)
+	} else if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
 func (s *service) CreateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error {
 	collection := s.getUserTagStatsCollection()
 	_, err := collection.InsertOne(ctx, userTagStats)
@@ -44,6 +92,9 @@ func (s *service) FindUserTagStats(ctx context.Context, userId, tag string) (*mo
 
 	var existingTagStats models.UserTagStats
 	if err := collection.FindOne(ctx, filter).Decode(&existingTagStats); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%w: no stats for tag %q", models.ErrNotFound, tag)
+		}
 		return nil, err
 	}
 
@@ -62,7 +113,7 @@ func (s *service) FindAllUserTagStats(ctx context.Context, userId string) ([]*mo
 	defer func(cursor *mongo.Cursor, ctx context.Context) {
 		err = cursor.Close(ctx)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
 		}
 	}(cursor, ctx)
 	var tagStats []*models.UserTagStats
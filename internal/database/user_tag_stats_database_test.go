@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+)
+
+func TestAddToUserTagStats(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+	db := NewMemory(clock.NewFake(now))
+
+	// Timers of a new tag stopping at once all count, in one document
+	const stops = 8
+	var wg sync.WaitGroup
+	for i := range stops {
+		wg.Go(func() {
+			if err := db.AddToUserTagStats(ctx, "user-1", "coding", 60, 1, now.Add(time.Duration(i)*time.Second)); err != nil {
+				t.Errorf("AddToUserTagStats: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	tagStats, err := db.FindAllUserTagStats(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("FindAllUserTagStats: %v", err)
+	}
+	if len(tagStats) != 1 {
+		t.Fatalf("tag stats = %+v, want one document", tagStats)
+	}
+	got := tagStats[0]
+	if got.TotalDuration != stops*60 || got.SessionCount != stops || !got.LastUpdated.Equal(now.Add((stops-1)*time.Second)) {
+		t.Errorf("tag stats = %+v, want %d sessions of 60s, last updated by the latest", got, stops)
+	}
+}
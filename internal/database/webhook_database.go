@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func (s *service) getWebhooksCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("webhooks")
+}
+
+func (s *service) getWebhookDeliveriesCollection() *mongo.Collection {
+	return s.db.Database(s.name).Collection("webhook_deliveries")
+}
+
+func (s *service) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	if _, err := s.getWebhooksCollection().InsertOne(ctx, webhook); err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// FindWebhooks returns the user's webhooks, oldest first
+func (s *service) FindWebhooks(ctx context.Context, userId string) ([]*models.Webhook, error) {
+	collection := s.getWebhooksCollection()
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userId}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var webhooks []*models.Webhook
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *service) FindWebhook(ctx context.Context, userId string, id primitive.ObjectID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := s.getWebhooksCollection().FindOne(ctx, bson.M{"_id": id, "user_id": userId}).Decode(&webhook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no webhook with this ID", models.ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook removes the user's webhook together with its deliveries, so
+// nothing more is sent to it
+func (s *service) DeleteWebhook(ctx context.Context, userId string, id primitive.ObjectID) error {
+	result, err := s.getWebhooksCollection().DeleteOne(ctx, bson.M{"_id": id, "user_id": userId})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("%w: no webhook with this ID", models.ErrNotFound)
+	}
+	if _, err = s.getWebhookDeliveriesCollection().DeleteMany(ctx, bson.M{"webhook_id": id}); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func (s *service) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	documents := make([]any, len(deliveries))
+	for i, delivery := range deliveries {
+		documents[i] = delivery
+	}
+	if _, err := s.getWebhookDeliveriesCollection().InsertMany(ctx, documents); err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// ClaimWebhookDelivery takes the pending delivery of any user that has been
+// due longest, if it is due by now, and postpones it to leaseUntil so no
+// other server sends it meanwhile. Deliveries to skipWebhooks, which the
+// caller is already sending to, are left alone. The caller records the
+// attempt with UpdateWebhookDelivery. It returns ErrNotFound when nothing is
+// due.
+func (s *service) ClaimWebhookDelivery(ctx context.Context, now, leaseUntil time.Time, skipWebhooks []primitive.ObjectID) (*models.WebhookDelivery, error) {
+	collection := s.getWebhookDeliveriesCollection()
+	filter := bson.M{
+		"status":          models.DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	if len(skipWebhooks) > 0 {
+		filter["webhook_id"] = bson.M{"$nin": skipWebhooks}
+	}
+	update := bson.M{"$set": bson.M{"next_attempt_at": leaseUntil}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no webhook delivery due", models.ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (s *service) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	collection := s.getWebhookDeliveriesCollection()
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": delivery.ID}, bson.M{"$set": delivery}); err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// FindWebhookDeliveries returns up to limit of the deliveries to the user's
+// webhook, newest first
+func (s *service) FindWebhookDeliveries(ctx context.Context, userId string, webhookID primitive.ObjectID, limit int) ([]*models.WebhookDelivery, error) {
+	collection := s.getWebhookDeliveriesCollection()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"webhook_id": webhookID, "user_id": userId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func(cursor *mongo.Cursor, ctx context.Context) {
+		if err = cursor.Close(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error closing cursor", "err", err)
+		}
+	}(cursor, ctx)
+
+	var deliveries []*models.WebhookDelivery
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,59 @@
+// Package logging configures structured logging and carries per-request
+// attributes such as the request ID through contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type contextKey struct{}
+
+// New returns a logger writing in format ("text" or "json") at level and
+// above. Records logged with a context include the attributes added to it
+// with With.
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// With returns a copy of ctx whose log records also carry attrs
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, contextKey{}, combined)
+}
+
+// contextHandler adds the attributes stored by With to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(contextKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
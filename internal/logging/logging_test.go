@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, FormatJSON, slog.LevelInfo)
+
+	ctx := With(context.Background(), slog.String("request_id", "abc"))
+	ctx = With(ctx, slog.String("user_id", "user-1"))
+	logger.InfoContext(ctx, "timer started", "tag", "coding")
+	logger.DebugContext(ctx, "below the level")
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("decoding %q: %v", out.String(), err)
+	}
+	for key, want := range map[string]string{
+		"msg":        "timer started",
+		"tag":        "coding",
+		"request_id": "abc",
+		"user_id":    "user-1",
+	} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %q", key, record[key], want)
+		}
+	}
+}
+
+func TestWithDoesNotShareAttributes(t *testing.T) {
+	var out bytes.Buffer
+	logger := New(&out, FormatText, slog.LevelInfo)
+
+	parent := With(context.Background(), slog.String("request_id", "abc"))
+	_ = With(parent, slog.String("user_id", "user-1"))
+	logger.InfoContext(parent, "hello")
+
+	if bytes.Contains(out.Bytes(), []byte("user_id")) {
+		t.Errorf("parent context picked up a child attribute: %s", out.String())
+	}
+}
@@ -0,0 +1,459 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+// instrumentedDatabase records the latency and errors of every operation of
+// the wrapped database.Service. It deliberately does not embed the interface,
+// so a new method fails to compile until it is instrumented here.
+type instrumentedDatabase struct {
+	next     database.Service
+	duration *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+}
+
+// InstrumentDatabase wraps db so its operations are measured in reg. It also
+// registers a gauge of the timers running across all users.
+func InstrumentDatabase(db database.Service, reg *Registry) database.Service {
+	reg.NewGaugeFunc("ptimer_running_timers", "Timers currently running across all users.", func() (float64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		count, err := db.CountRunningTimers(ctx)
+		return float64(count), err
+	})
+
+	return &instrumentedDatabase{
+		next: db,
+		duration: reg.NewHistogramVec("ptimer_db_operation_duration_seconds",
+			"Latency of database operations.", DefaultBuckets, "operation"),
+		failures: reg.NewCounterVec("ptimer_db_operation_errors_total",
+			"Database operations that failed, excluding lookups that found nothing.", "operation"),
+	}
+}
+
+func (d *instrumentedDatabase) observe(operation string, start time.Time, err error) {
+	d.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		d.failures.WithLabelValues(operation).Inc()
+	}
+}
+
+func (d *instrumentedDatabase) Health(ctx context.Context) (*database.Health, error) {
+	return d.next.Health(ctx)
+}
+
+func (d *instrumentedDatabase) FindOrCreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+	start := time.Now()
+	result, err := d.next.FindOrCreateUser(ctx, user)
+	d.observe("FindOrCreateUser", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	start := time.Now()
+	result, err := d.next.GetUserByID(ctx, id)
+	d.observe("GetUserByID", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) SetConcurrentTimers(ctx context.Context, userId string, enabled bool) error {
+	start := time.Now()
+	err := d.next.SetConcurrentTimers(ctx, userId, enabled)
+	d.observe("SetConcurrentTimers", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) UpdateTimerSession(ctx context.Context, timerSession *models.TimerSession) error {
+	start := time.Now()
+	err := d.next.UpdateTimerSession(ctx, timerSession)
+	d.observe("UpdateTimerSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) UpdateTimerSessionNote(ctx context.Context, id primitive.ObjectID, note string) error {
+	start := time.Now()
+	err := d.next.UpdateTimerSessionNote(ctx, id, note)
+	d.observe("UpdateTimerSessionNote", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) PauseTimerSession(ctx context.Context, timerSession *models.TimerSession, runningSince time.Time) error {
+	start := time.Now()
+	err := d.next.PauseTimerSession(ctx, timerSession, runningSince)
+	d.observe("PauseTimerSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CreateTimerSession(ctx context.Context, timerSession *models.TimerSession) error {
+	start := time.Now()
+	err := d.next.CreateTimerSession(ctx, timerSession)
+	d.observe("CreateTimerSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindTimerSession(ctx context.Context, userId, tag string, status models.TimerStatus) (*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.FindTimerSession(ctx, userId, tag, status)
+	d.observe("FindTimerSession", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindActiveTimerSession(ctx context.Context, userId string) (*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.FindActiveTimerSession(ctx, userId)
+	d.observe("FindActiveTimerSession", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindActiveTimerSessions(ctx context.Context, userId string) ([]*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.FindActiveTimerSessions(ctx, userId)
+	d.observe("FindActiveTimerSessions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindDueTimerSessions(ctx context.Context, now time.Time) ([]*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.FindDueTimerSessions(ctx, now)
+	d.observe("FindDueTimerSessions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) ClaimLongRunningTimerSession(ctx context.Context, runningSince, now time.Time) (*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.ClaimLongRunningTimerSession(ctx, runningSince, now)
+	d.observe("ClaimLongRunningTimerSession", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) AbandonRunningTimers(ctx context.Context, userId, tag string) error {
+	start := time.Now()
+	err := d.next.AbandonRunningTimers(ctx, userId, tag)
+	d.observe("AbandonRunningTimers", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CountRunningTimers(ctx context.Context) (int64, error) {
+	start := time.Now()
+	result, err := d.next.CountRunningTimers(ctx)
+	d.observe("CountRunningTimers", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) UpdateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error {
+	start := time.Now()
+	err := d.next.UpdateUserTagStats(ctx, userTagStats)
+	d.observe("UpdateUserTagStats", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) AddToUserTagStats(ctx context.Context, userId, tag string, duration int64, sessions int, now time.Time) error {
+	start := time.Now()
+	err := d.next.AddToUserTagStats(ctx, userId, tag, duration, sessions, now)
+	d.observe("AddToUserTagStats", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CreateUserTagStats(ctx context.Context, userTagStats *models.UserTagStats) error {
+	start := time.Now()
+	err := d.next.CreateUserTagStats(ctx, userTagStats)
+	d.observe("CreateUserTagStats", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindUserTagStats(ctx context.Context, userId string, tag string) (*models.UserTagStats, error) {
+	start := time.Now()
+	result, err := d.next.FindUserTagStats(ctx, userId, tag)
+	d.observe("FindUserTagStats", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindAllUserTagStats(ctx context.Context, userId string) ([]*models.UserTagStats, error) {
+	start := time.Now()
+	result, err := d.next.FindAllUserTagStats(ctx, userId)
+	d.observe("FindAllUserTagStats", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) UpdateTagSettings(ctx context.Context, userId, tag string, settings models.TagSettings) (*models.UserTagStats, error) {
+	start := time.Now()
+	result, err := d.next.UpdateTagSettings(ctx, userId, tag, settings)
+	d.observe("UpdateTagSettings", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) GetStatsSummary(ctx context.Context, userId, tag string, startDate, endDate time.Time, wallClock bool) (*models.StatsSummary, error) {
+	start := time.Now()
+	result, err := d.next.GetStatsSummary(ctx, userId, tag, startDate, endDate, wallClock)
+	d.observe("GetStatsSummary", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) GetTagSessions(ctx context.Context, userId, tag string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.GetTagSessions(ctx, userId, tag, startDate, endDate)
+	d.observe("GetTagSessions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) SearchTimerSessions(ctx context.Context, userId string, terms []string, startDate, endDate time.Time) ([]*models.TimerSession, error) {
+	start := time.Now()
+	result, err := d.next.SearchTimerSessions(ctx, userId, terms, startDate, endDate)
+	d.observe("SearchTimerSessions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) DeleteUserTagStats(ctx context.Context, userId, tag string) error {
+	start := time.Now()
+	err := d.next.DeleteUserTagStats(ctx, userId, tag)
+	d.observe("DeleteUserTagStats", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) DeleteTimerSession(ctx context.Context, userId, tag string) error {
+	start := time.Now()
+	err := d.next.DeleteTimerSession(ctx, userId, tag)
+	d.observe("DeleteTimerSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CreateSession(ctx context.Context, session *models.Session) error {
+	start := time.Now()
+	err := d.next.CreateSession(ctx, session)
+	d.observe("CreateSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindSession(ctx context.Context, id string) (*models.Session, error) {
+	start := time.Now()
+	result, err := d.next.FindSession(ctx, id)
+	d.observe("FindSession", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) TouchSession(ctx context.Context, id string, lastSeenAt time.Time) error {
+	start := time.Now()
+	err := d.next.TouchSession(ctx, id, lastSeenAt)
+	d.observe("TouchSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) DeleteSession(ctx context.Context, id string) error {
+	start := time.Now()
+	err := d.next.DeleteSession(ctx, id)
+	d.observe("DeleteSession", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) DeleteUserSessions(ctx context.Context, userId, exceptID string) error {
+	start := time.Now()
+	err := d.next.DeleteUserSessions(ctx, userId, exceptID)
+	d.observe("DeleteUserSessions", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindUserSessions(ctx context.Context, userId string) ([]*models.Session, error) {
+	start := time.Now()
+	result, err := d.next.FindUserSessions(ctx, userId)
+	d.observe("FindUserSessions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error {
+	start := time.Now()
+	err := d.next.CreateDeviceAuthorization(ctx, authorization)
+	d.observe("CreateDeviceAuthorization", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error) {
+	start := time.Now()
+	result, err := d.next.FindDeviceAuthorization(ctx, id)
+	d.observe("FindDeviceAuthorization", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (*models.DeviceAuthorization, error) {
+	start := time.Now()
+	result, err := d.next.FindDeviceAuthorizationByUserCode(ctx, userCode)
+	d.observe("FindDeviceAuthorizationByUserCode", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) ApproveDeviceAuthorization(ctx context.Context, id string, user *models.User) error {
+	start := time.Now()
+	err := d.next.ApproveDeviceAuthorization(ctx, id, user)
+	d.observe("ApproveDeviceAuthorization", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) DeleteDeviceAuthorization(ctx context.Context, id string) error {
+	start := time.Now()
+	err := d.next.DeleteDeviceAuthorization(ctx, id)
+	d.observe("DeleteDeviceAuthorization", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) ClaimDeviceAuthorization(ctx context.Context, id string) (*models.DeviceAuthorization, error) {
+	start := time.Now()
+	result, err := d.next.ClaimDeviceAuthorization(ctx, id)
+	d.observe("ClaimDeviceAuthorization", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) SavePushSubscription(ctx context.Context, subscription *models.PushSubscription) error {
+	start := time.Now()
+	err := d.next.SavePushSubscription(ctx, subscription)
+	d.observe("SavePushSubscription", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindPushSubscriptions(ctx context.Context, userId string) ([]*models.PushSubscription, error) {
+	start := time.Now()
+	result, err := d.next.FindPushSubscriptions(ctx, userId)
+	d.observe("FindPushSubscriptions", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	start := time.Now()
+	err := d.next.DeletePushSubscription(ctx, userId, endpoint)
+	d.observe("DeletePushSubscription", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	start := time.Now()
+	err := d.next.CreateWebhook(ctx, webhook)
+	d.observe("CreateWebhook", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindWebhooks(ctx context.Context, userId string) ([]*models.Webhook, error) {
+	start := time.Now()
+	result, err := d.next.FindWebhooks(ctx, userId)
+	d.observe("FindWebhooks", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) FindWebhook(ctx context.Context, userId string, id primitive.ObjectID) (*models.Webhook, error) {
+	start := time.Now()
+	result, err := d.next.FindWebhook(ctx, userId, id)
+	d.observe("FindWebhook", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) DeleteWebhook(ctx context.Context, userId string, id primitive.ObjectID) error {
+	start := time.Now()
+	err := d.next.DeleteWebhook(ctx, userId, id)
+	d.observe("DeleteWebhook", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) CreateWebhookDeliveries(ctx context.Context, deliveries []*models.WebhookDelivery) error {
+	start := time.Now()
+	err := d.next.CreateWebhookDeliveries(ctx, deliveries)
+	d.observe("CreateWebhookDeliveries", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) ClaimWebhookDelivery(ctx context.Context, now, leaseUntil time.Time, skipWebhooks []primitive.ObjectID) (*models.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := d.next.ClaimWebhookDelivery(ctx, now, leaseUntil, skipWebhooks)
+	d.observe("ClaimWebhookDelivery", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	start := time.Now()
+	err := d.next.UpdateWebhookDelivery(ctx, delivery)
+	d.observe("UpdateWebhookDelivery", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) FindWebhookDeliveries(ctx context.Context, userId string, webhookID primitive.ObjectID, limit int) ([]*models.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := d.next.FindWebhookDeliveries(ctx, userId, webhookID, limit)
+	d.observe("FindWebhookDeliveries", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) EnsureIndexes(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	result, err := d.next.EnsureIndexes(ctx)
+	d.observe("EnsureIndexes", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) MissingIndexes(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	result, err := d.next.MissingIndexes(ctx)
+	d.observe("MissingIndexes", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) ListUsers(ctx context.Context) ([]*models.User, error) {
+	start := time.Now()
+	result, err := d.next.ListUsers(ctx)
+	d.observe("ListUsers", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) CountUserData(ctx context.Context, userId string) (*database.UserDataCounts, error) {
+	start := time.Now()
+	result, err := d.next.CountUserData(ctx, userId)
+	d.observe("CountUserData", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) PurgeUser(ctx context.Context, userId string) (*database.UserDataCounts, error) {
+	start := time.Now()
+	result, err := d.next.PurgeUser(ctx, userId)
+	d.observe("PurgeUser", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) RecomputeUserTagStats(ctx context.Context) ([]*models.UserTagStats, error) {
+	start := time.Now()
+	result, err := d.next.RecomputeUserTagStats(ctx)
+	d.observe("RecomputeUserTagStats", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) ListAllUserTagStats(ctx context.Context) ([]*models.UserTagStats, error) {
+	start := time.Now()
+	result, err := d.next.ListAllUserTagStats(ctx)
+	d.observe("ListAllUserTagStats", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) DeleteUserTagStatsByID(ctx context.Context, id primitive.ObjectID) error {
+	start := time.Now()
+	err := d.next.DeleteUserTagStatsByID(ctx, id)
+	d.observe("DeleteUserTagStatsByID", start, err)
+	return err
+}
+
+func (d *instrumentedDatabase) PendingMigrations(ctx context.Context) ([]database.Migration, error) {
+	start := time.Now()
+	result, err := d.next.PendingMigrations(ctx)
+	d.observe("PendingMigrations", start, err)
+	return result, err
+}
+
+func (d *instrumentedDatabase) Migrate(ctx context.Context) ([]database.Migration, error) {
+	start := time.Now()
+	result, err := d.next.Migrate(ctx)
+	d.observe("Migrate", start, err)
+	return result, err
+}
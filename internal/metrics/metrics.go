@@ -0,0 +1,76 @@
+// Package metrics collects application metrics and serves them for
+// Prometheus to scrape.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are latency bucket upper bounds in seconds, suited to HTTP
+// requests and database operations
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds the application's metrics, along with the Go runtime and
+// process metrics, and serves them on /metrics
+type Registry struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+}
+
+func NewRegistry() *Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return &Registry{
+		registry: registry,
+		handler:  promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	}
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+// NewCounterVec registers a family of counters partitioned by labels
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	v := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	r.registry.MustRegister(v)
+	return v
+}
+
+// NewHistogramVec registers a family of histograms partitioned by labels
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	v := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)
+	r.registry.MustRegister(v)
+	return v
+}
+
+// NewGaugeFunc registers a gauge whose value fn computes on every scrape.
+// The gauge is left out of a scrape in which fn fails.
+func (r *Registry) NewGaugeFunc(name, help string, fn func() (float64, error)) {
+	r.registry.MustRegister(&gaugeFunc{desc: prometheus.NewDesc(name, help, nil, nil), fn: fn})
+}
+
+// gaugeFunc is a prometheus.Collector for NewGaugeFunc
+type gaugeFunc struct {
+	desc *prometheus.Desc
+	fn   func() (float64, error)
+}
+
+func (g *gaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	value, err := g.fn()
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, value)
+}
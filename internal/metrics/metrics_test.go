@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Requests served.", "route")
+	requests.WithLabelValues("/b").Inc()
+	requests.WithLabelValues("/a").Add(2)
+	requests.WithLabelValues(`we"ird\`).Inc()
+
+	latency := reg.NewHistogramVec("latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	latency.WithLabelValues("/a").Observe(0.05)
+	latency.WithLabelValues("/a").Observe(0.5)
+	latency.WithLabelValues("/a").Observe(3)
+
+	reg.NewGaugeFunc("running", "Things running.", func() (float64, error) { return 7, nil })
+	reg.NewGaugeFunc("broken", "Things that cannot be counted.", func() (float64, error) { return 0, errors.New("unreachable") })
+
+	got := scrape(t, reg)
+	for _, want := range []string{
+		"# HELP requests_total Requests served.\n# TYPE requests_total counter\n" +
+			`requests_total{route="/a"} 2` + "\n" +
+			`requests_total{route="/b"} 1` + "\n" +
+			`requests_total{route="we\"ird\\"} 1` + "\n",
+		"# HELP latency_seconds Request latency.\n# TYPE latency_seconds histogram\n" +
+			`latency_seconds_bucket{route="/a",le="0.1"} 1` + "\n" +
+			`latency_seconds_bucket{route="/a",le="1"} 2` + "\n" +
+			`latency_seconds_bucket{route="/a",le="+Inf"} 3` + "\n" +
+			`latency_seconds_sum{route="/a"} 3.55` + "\n" +
+			`latency_seconds_count{route="/a"} 3` + "\n",
+		"# HELP running Things running.\n# TYPE running gauge\nrunning 7\n",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("exposition lacks\n%s\ngot:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "\nbroken ") {
+		t.Error("a gauge that failed was reported")
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Requests served.", "route", "status")
+	defer func() {
+		if recover() == nil {
+			t.Error("WithLabelValues with too few values did not panic")
+		}
+	}()
+	requests.WithLabelValues("/a")
+}
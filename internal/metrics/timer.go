@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+)
+
+// instrumentedTimers counts successful timer actions of the wrapped
+// timer.Service
+type instrumentedTimers struct {
+	next    timer.Service
+	actions *prometheus.CounterVec
+}
+
+// InstrumentTimers wraps timers so started, stopped, reset and expired timers
+// are counted in reg
+func InstrumentTimers(timers timer.Service, reg *Registry) timer.Service {
+	return &instrumentedTimers{
+		next:    timers,
+		actions: reg.NewCounterVec("ptimer_timer_actions_total", "Timers started, stopped, reset and stopped by their countdown.", "action"),
+	}
+}
+
+func (t *instrumentedTimers) Start(ctx context.Context, userID, tag, note string, countdown time.Duration, now time.Time) (*timer.State, error) {
+	state, err := t.next.Start(ctx, userID, tag, note, countdown, now)
+	t.count("start", err)
+	return state, err
+}
+
+func (t *instrumentedTimers) Stop(ctx context.Context, userID, tag, note string, now time.Time) (*timer.State, error) {
+	state, err := t.next.Stop(ctx, userID, tag, note, now)
+	t.count("stop", err)
+	return state, err
+}
+
+func (t *instrumentedTimers) Annotate(ctx context.Context, userID, tag, note string, now time.Time) (*timer.State, error) {
+	return t.next.Annotate(ctx, userID, tag, note, now)
+}
+
+func (t *instrumentedTimers) Reset(ctx context.Context, userID, tag string, now time.Time) (*timer.State, error) {
+	state, err := t.next.Reset(ctx, userID, tag, now)
+	t.count("reset", err)
+	return state, err
+}
+
+func (t *instrumentedTimers) Current(ctx context.Context, userID string, now time.Time) (*timer.State, error) {
+	return t.next.Current(ctx, userID, now)
+}
+
+func (t *instrumentedTimers) Active(ctx context.Context, userID string, now time.Time) ([]*timer.State, error) {
+	return t.next.Active(ctx, userID, now)
+}
+
+func (t *instrumentedTimers) ExpireCountdowns(ctx context.Context, now time.Time) ([]*timer.State, error) {
+	states, err := t.next.ExpireCountdowns(ctx, now)
+	for range states {
+		t.actions.WithLabelValues("expire").Inc()
+	}
+	return states, err
+}
+
+func (t *instrumentedTimers) FlagLongRunning(ctx context.Context, limit time.Duration, now time.Time) ([]*timer.State, error) {
+	return t.next.FlagLongRunning(ctx, limit, now)
+}
+
+func (t *instrumentedTimers) Tags(ctx context.Context, userID string) ([]string, error) {
+	return t.next.Tags(ctx, userID)
+}
+
+func (t *instrumentedTimers) UpdateTag(ctx context.Context, userID, tag string, settings models.TagSettings) (*models.UserTagStats, error) {
+	return t.next.UpdateTag(ctx, userID, tag, settings)
+}
+
+func (t *instrumentedTimers) count(action string, err error) {
+	if err == nil {
+		t.actions.WithLabelValues(action).Inc()
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// DeviceAuthorization is a pending command-line login. The client holds a
+// secret device code whose SHA-256 hash is ID; the user approves the login in
+// the browser by entering the short UserCode.
+type DeviceAuthorization struct {
+	ID        string    `bson:"_id" json:"id"`
+	UserCode  string    `bson:"user_code" json:"userCode"`
+	User      *User     `bson:"user,omitempty" json:"user,omitempty"` // Set once the user approves
+	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expiresAt"`
+}
+
+func NewDeviceAuthorization(id, userCode string, now time.Time, ttl time.Duration) *DeviceAuthorization {
+	return &DeviceAuthorization{
+		ID:        id,
+		UserCode:  userCode,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+// Approved reports whether a user has approved the login
+func (d *DeviceAuthorization) Approved() bool {
+	return d.User != nil
+}
@@ -0,0 +1,11 @@
+package models
+
+import "errors"
+
+// Domain errors shared by the database and server layers. Wrap them with
+// fmt.Errorf("%w: ...") to add detail; the server maps them to HTTP statuses.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("invalid input")
+)
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PushSubscription is a browser's Web Push subscription. The push service
+// behind Endpoint delivers messages encrypted to P256dh and Auth, which the
+// browser generated for this subscription.
+type PushSubscription struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	Endpoint  string             `bson:"endpoint" json:"endpoint"`
+	P256dh    string             `bson:"p256dh" json:"-"` // The browser's public key, base64url
+	Auth      string             `bson:"auth" json:"-"`   // Authentication secret, base64url
+	UserAgent string             `bson:"user_agent" json:"userAgent"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// MaxPushSubscriptions is how many browsers a user can subscribe
+const MaxPushSubscriptions = 10
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Session is a server-side login session. The cookie only carries an opaque
+// token; ID is the SHA-256 hash of that token so a leaked sessions collection
+// cannot be replayed as cookies.
+type Session struct {
+	ID         string    `bson:"_id" json:"id"`
+	UserID     string    `bson:"user_id" json:"userId"`
+	User       User      `bson:"user" json:"user"` // Snapshot of the user taken at login
+	UserAgent  string    `bson:"user_agent" json:"userAgent"`
+	IPAddress  string    `bson:"ip_address" json:"ipAddress"`
+	CreatedAt  time.Time `bson:"created_at" json:"createdAt"`
+	LastSeenAt time.Time `bson:"last_seen_at" json:"lastSeenAt"`
+	ExpiresAt  time.Time `bson:"expires_at" json:"expiresAt"`
+}
+
+func NewSession(id string, user *User, userAgent, ipAddress string, now time.Time, maxAge time.Duration) *Session {
+	return &Session{
+		ID:         id,
+		UserID:     user.ID,
+		User:       *user,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(maxAge),
+	}
+}
@@ -7,11 +7,15 @@ type StatsSummary struct {
 	AverageSession int64      `json:"averageSession"` // Average session duration in seconds
 	MostUsedTag    string     `json:"mostUsedTag"`    // Tag with most time spent
 	TagBreakdown   []TagStats `json:"tagBreakdown"`   // Per-tag breakdown
+	TagTree        []*TagNode `json:"tagTree"`        // Breakdown rolled up along tag levels
+	WallClock      bool       `json:"wallClock"`      // Overlapping timers' time is shared rather than counted for each
 }
 
 // TagStats represents stats for a single tag within a time period
 type TagStats struct {
 	Tag               string  `bson:"_id" json:"tag"`
+	Color             string  `bson:"-" json:"color,omitempty"`            // From the tag's settings
+	Icon              string  `bson:"-" json:"icon,omitempty"`             // From the tag's settings
 	TotalDuration     int64   `bson:"total_duration" json:"totalDuration"` // Total seconds for this tag
 	SessionCount      int     `bson:"session_count" json:"sessionCount"`   // Number of sessions
 	AverageSession    int64   `json:"averageSession"`                      // Average session duration
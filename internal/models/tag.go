@@ -0,0 +1,133 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagSeparator splits hierarchical tags such as "client-a/backend" into
+// levels, so "client-a/backend" and "client-a/meetings" roll up into
+// "client-a"
+const TagSeparator = "/"
+
+// CleanTag trims the whitespace around each level of a tag. It reports false
+// when a level is empty, as in "client-a//backend" or "/backend".
+func CleanTag(tag string) (string, bool) {
+	levels := strings.Split(tag, TagSeparator)
+	for i, level := range levels {
+		levels[i] = strings.TrimSpace(level)
+		if levels[i] == "" {
+			return "", false
+		}
+	}
+	return strings.Join(levels, TagSeparator), true
+}
+
+// ParentTag returns the tag one level up, or "" for a top-level tag
+func ParentTag(tag string) string {
+	i := strings.LastIndex(tag, TagSeparator)
+	if i < 0 {
+		return ""
+	}
+	return tag[:i]
+}
+
+// TagWithin reports whether tag is root or nested under it. Every tag is
+// within the empty root.
+func TagWithin(tag, root string) bool {
+	return root == "" || tag == root || strings.HasPrefix(tag, root+TagSeparator)
+}
+
+// TagNode is a tag's stats rolled up with those of every tag nested under it
+type TagNode struct {
+	TagStats
+	Name     string     `json:"name"`               // Last level of the tag
+	Children []*TagNode `json:"children,omitempty"` // Nested tags, by duration descending
+}
+
+// BuildTagTree rolls per-tag stats up into a tree whose top level is root,
+// or every top-level tag when root is empty. Levels nobody tracked time
+// against directly, such as "client-a" when only "client-a/backend" was used,
+// get a node of their own. Percentages are of totalDuration.
+func BuildTagTree(tagStatsList []TagStats, root string, totalDuration int64) []*TagNode {
+	nodes := make(map[string]*TagNode)
+	var roots []*TagNode
+
+	var node func(tag string) *TagNode
+	node = func(tag string) *TagNode {
+		if n, ok := nodes[tag]; ok {
+			return n
+		}
+		n := &TagNode{TagStats: TagStats{Tag: tag}, Name: tag[strings.LastIndex(tag, TagSeparator)+1:]}
+		nodes[tag] = n
+		if parent := ParentTag(tag); tag != root && parent != "" {
+			p := node(parent)
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+		return n
+	}
+
+	for _, tagStats := range tagStatsList {
+		if !TagWithin(tagStats.Tag, root) {
+			continue
+		}
+		for n := node(tagStats.Tag); n != nil; n = nodes[ParentTag(n.Tag)] {
+			n.TotalDuration += tagStats.TotalDuration
+			n.SessionCount += tagStats.SessionCount
+			if n.Tag == root {
+				break
+			}
+		}
+	}
+
+	for _, n := range nodes {
+		if n.SessionCount > 0 {
+			n.AverageSession = n.TotalDuration / int64(n.SessionCount)
+		}
+		if totalDuration > 0 {
+			n.PercentageOfTotal = float64(n.TotalDuration) / float64(totalDuration) * 100
+		}
+		sortTagNodes(n.Children)
+	}
+	sortTagNodes(roots)
+	return roots
+}
+
+func sortTagNodes(nodes []*TagNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].TotalDuration != nodes[j].TotalDuration {
+			return nodes[i].TotalDuration > nodes[j].TotalDuration
+		}
+		return nodes[i].Tag < nodes[j].Tag
+	})
+}
+
+// ApplyTagSettings copies each tag's color and icon into the summary. Nested
+// tags without a color of their own take their nearest ancestor's.
+func (s *StatsSummary) ApplyTagSettings(settings map[string]TagSettings) {
+	color := func(tag string) string {
+		for ; tag != ""; tag = ParentTag(tag) {
+			if c := settings[tag].Color; c != "" {
+				return c
+			}
+		}
+		return ""
+	}
+
+	for i := range s.TagBreakdown {
+		s.TagBreakdown[i].Color = color(s.TagBreakdown[i].Tag)
+		s.TagBreakdown[i].Icon = settings[s.TagBreakdown[i].Tag].Icon
+	}
+
+	var walk func(nodes []*TagNode)
+	walk = func(nodes []*TagNode) {
+		for _, n := range nodes {
+			n.Color = color(n.Tag)
+			n.Icon = settings[n.Tag].Icon
+			walk(n.Children)
+		}
+	}
+	walk(s.TagTree)
+}
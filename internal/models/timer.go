@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,27 +15,70 @@ const (
 	StatusCompleted TimerStatus = "completed"
 )
 
+// MaxNoteLength is the longest note, in characters, a session can carry
+const MaxNoteLength = 1000
+
+// MaxCountdown is the longest countdown a timer can be started with
+const MaxCountdown = 24 * time.Hour
+
 type TimerSession struct {
 	ID          primitive.ObjectID `bson:"_id" json:"id"`
 	UserID      string             `bson:"user_id" json:"userId"`
 	Tag         string             `bson:"tag" json:"tag"`
+	Note        string             `bson:"note" json:"note,omitempty"` // What the time was spent on
 	StartTime   time.Time          `bson:"start_time" json:"startTime"`
 	EndTime     *time.Time         `bson:"end_time,omitempty" json:"endTime,omitempty"`
-	Duration    int64              `bson:"duration" json:"duration"` // Duration in seconds
-	Status      TimerStatus        `bson:"status" json:"status"`     // e.g., "running", "stopped"
+	Duration    int64              `bson:"duration" json:"duration"`                       // Duration in seconds
+	Intervals   []Interval         `bson:"intervals,omitempty" json:"intervals,omitempty"` // When it ran, one per stop
+	Status      TimerStatus        `bson:"status" json:"status"`                           // e.g., "running", "stopped"
+	Countdown   int64              `bson:"countdown" json:"countdown,omitempty"`           // Target duration in seconds; zero counts up
+	DueAt       *time.Time         `bson:"due_at" json:"dueAt,omitempty"`                  // When a running countdown reaches zero; null otherwise so updates clear it
+	AlertedAt   *time.Time         `bson:"alerted_at" json:"-"`                            // When the user was told this run is going long; cleared when it stops
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	LastUpdated time.Time          `bson:"last_updated" json:"lastUpdated"`
 }
 
-func NewTimerSession(userID, tag string) *TimerSession {
+// Interval is a stretch of time a session's timer ran for
+type Interval struct {
+	Start time.Time `bson:"start" json:"start"`
+	End   time.Time `bson:"end" json:"end"`
+}
+
+// RanDuring returns when the session's timer ran. Sessions recorded before
+// intervals were kept are assumed to have run without a break from their
+// start.
+func (t *TimerSession) RanDuring() []Interval {
+	if len(t.Intervals) > 0 {
+		return t.Intervals
+	}
+	if t.Duration <= 0 {
+		return nil
+	}
+	return []Interval{{Start: t.StartTime, End: t.StartTime.Add(time.Duration(t.Duration) * time.Second)}}
+}
+
+// CountdownFinished reports whether the session counted down to zero
+func (t *TimerSession) CountdownFinished() bool {
+	return t.Countdown > 0 && t.Duration >= t.Countdown
+}
+
+func NewTimerSession(userID, tag string, now time.Time) *TimerSession {
 	return &TimerSession{
 		ID:          primitive.NewObjectID(),
 		UserID:      userID,
 		Tag:         tag,
-		StartTime:   time.Now(),
+		StartTime:   now,
 		Duration:    0,
 		Status:      StatusRunning,
-		CreatedAt:   time.Now(),
-		LastUpdated: time.Now(),
+		CreatedAt:   now,
+		LastUpdated: now,
 	}
 }
+
+// FormatDuration renders a duration in seconds as HH:MM:SS
+func FormatDuration(seconds int64) string {
+	hours := seconds / 3600
+	minutes := (seconds % 3600) / 60
+	secs := seconds % 60
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, secs)
+}
@@ -4,8 +4,12 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// User is an application account. ID is generated by the application and is
+// what every other collection references; ProviderID is the identifier issued
+// by the OAuth provider and is only used to look the account up on login.
 type User struct {
 	ID          string    `bson:"_id" json:"id"`
 	Email       string    `bson:"email" json:"email"`
@@ -18,12 +22,21 @@ type User struct {
 	ProviderID  string    `bson:"provider_id" json:"providerId"`
 	CreatedAt   time.Time `bson:"created_at" json:"createdAt"`
 	LastLoginAt time.Time `bson:"last_login_at" json:"lastLoginAt"`
+
+	// ConcurrentTimers lets several tags' timers run at once. Otherwise
+	// starting a timer stops the one already running.
+	ConcurrentTimers bool `bson:"concurrent_timers" json:"concurrentTimers"`
+}
+
+// NewUserID returns a new application-generated user ID
+func NewUserID() string {
+	return primitive.NewObjectID().Hex()
 }
 
-// FromGothUser creates a User from a goth.User
+// FromGothUser creates a User from a goth.User. The returned user has no ID;
+// one is assigned when the user is first stored.
 func FromGothUser(gothUser goth.User) *User {
 	return &User{
-		ID:         gothUser.UserID,
 		Email:      gothUser.Email,
 		Name:       gothUser.Name,
 		FirstName:  gothUser.FirstName,
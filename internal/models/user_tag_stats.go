@@ -15,6 +15,8 @@ const (
 	PeriodCustom  Period = "custom"
 )
 
+// UserTagStats is a user's tag: its running totals and the settings the user
+// gave it on the tags page
 type UserTagStats struct {
 	ID            primitive.ObjectID `bson:"_id" json:"id"`
 	UserID        string             `bson:"user_id" json:"userId"`
@@ -22,15 +24,38 @@ type UserTagStats struct {
 	TotalDuration int64              `bson:"total_duration" json:"totalDuration"` // in seconds
 	SessionCount  int                `bson:"session_count" json:"sessionCount"`
 	LastUpdated   time.Time          `bson:"last_updated" json:"lastUpdated"`
+	TagSettings   `bson:",inline"`
 }
 
-func NewUserTagStats(userID, tag string) *UserTagStats {
+// Limits on tag settings
+const (
+	MaxTagIconLength        = 8
+	MaxTagDescriptionLength = 280
+)
+
+// TagSettings are the user-editable properties of a tag. Archived tags are
+// left out of the tag picker but keep their history and stats.
+type TagSettings struct {
+	Color           string `bson:"color" json:"color"` // "#rrggbb", or empty for the default
+	Icon            string `bson:"icon" json:"icon"`   // Usually a single emoji
+	Description     string `bson:"description" json:"description"`
+	Archived        bool   `bson:"archived" json:"archived"`
+	Billable        bool   `bson:"billable" json:"billable"`
+	HourlyRateCents int64  `bson:"hourly_rate_cents" json:"hourlyRateCents"` // Rate for billable time, in cents
+}
+
+// IsZero reports whether the tag has only default settings
+func (s TagSettings) IsZero() bool {
+	return s == TagSettings{}
+}
+
+func NewUserTagStats(userID, tag string, now time.Time) *UserTagStats {
 	return &UserTagStats{
 		ID:            primitive.NewObjectID(),
 		UserID:        userID,
 		Tag:           tag,
 		TotalDuration: 0,
 		SessionCount:  1,
-		LastUpdated:   time.Now(),
+		LastUpdated:   now,
 	}
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// WallClockTagStats totals sessions by tag without counting any moment twice.
+// Time when several tags' timers ran at once is shared evenly between them,
+// so the totals add up to the time during which any timer ran.
+func WallClockTagStats(sessions []*TimerSession) []TagStats {
+	type event struct {
+		at    time.Time
+		tag   string
+		delta int
+	}
+
+	byTag := make(map[string]*TagStats)
+	shares := make(map[string]time.Duration)
+	var events []event
+	for _, session := range sessions {
+		tagStats, ok := byTag[session.Tag]
+		if !ok {
+			tagStats = &TagStats{Tag: session.Tag}
+			byTag[session.Tag] = tagStats
+		}
+		tagStats.SessionCount++
+		for _, interval := range session.RanDuring() {
+			if interval.End.After(interval.Start) {
+				events = append(events, event{interval.Start, session.Tag, 1}, event{interval.End, session.Tag, -1})
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].at.Before(events[j].at)
+	})
+
+	// Sweep through the events, sharing each stretch between the tags
+	// running during it
+	running := make(map[string]int)
+	for i, e := range events {
+		if i > 0 && len(running) > 0 {
+			share := e.at.Sub(events[i-1].at) / time.Duration(len(running))
+			for tag := range running {
+				shares[tag] += share
+			}
+		}
+		running[e.tag] += e.delta
+		if running[e.tag] == 0 {
+			delete(running, e.tag)
+		}
+	}
+
+	tagStatsList := make([]TagStats, 0, len(byTag))
+	for tag, tagStats := range byTag {
+		tagStats.TotalDuration = int64(shares[tag].Round(time.Second) / time.Second)
+		tagStatsList = append(tagStatsList, *tagStats)
+	}
+	sort.Slice(tagStatsList, func(i, j int) bool {
+		if tagStatsList[i].TotalDuration != tagStatsList[j].TotalDuration {
+			return tagStatsList[i].TotalDuration > tagStatsList[j].TotalDuration
+		}
+		return tagStatsList[i].Tag < tagStatsList[j].Tag
+	})
+	return tagStatsList
+}
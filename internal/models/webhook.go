@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Events webhooks can subscribe to
+const (
+	EventTimerStarted   = "timer.started"
+	EventTimerStopped   = "timer.stopped"
+	EventTimerCompleted = "timer.completed"
+	EventTagDeleted     = "tag.deleted"
+)
+
+// WebhookEvents lists every event in the order they are offered
+var WebhookEvents = []string{EventTimerStarted, EventTimerStopped, EventTimerCompleted, EventTagDeleted}
+
+// MaxWebhooks is how many webhooks a user can register
+const MaxWebhooks = 10
+
+// Webhook is a URL a user registered to be sent their timer activity. Each
+// delivery is signed with Secret so the receiver can check where it came
+// from.
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	URL       string             `bson:"url" json:"url"`
+	Events    []string           `bson:"events" json:"events"`
+	Secret    string             `bson:"secret" json:"-"` // Shown once, when the webhook is created
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// Subscribed reports whether the webhook receives event
+func (w *Webhook) Subscribed(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed" // Out of attempts
+)
+
+// WebhookDelivery is one event sent, or still to be sent, to a webhook. It
+// doubles as the delivery log: attempts update it in place.
+type WebhookDelivery struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	WebhookID primitive.ObjectID `bson:"webhook_id" json:"webhookId"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	Event     string             `bson:"event" json:"event"`
+	// Payload is the exact body sent, so every attempt is signed over the
+	// same bytes
+	Payload  json.RawMessage `bson:"payload" json:"payload"`
+	Status   DeliveryStatus  `bson:"status" json:"status"`
+	Attempts int             `bson:"attempts" json:"attempts"`
+	// NextAttemptAt is when a pending delivery is due; null otherwise so
+	// updates clear it
+	NextAttemptAt  *time.Time `bson:"next_attempt_at" json:"nextAttemptAt,omitempty"`
+	LastAttemptAt  *time.Time `bson:"last_attempt_at" json:"lastAttemptAt,omitempty"`
+	ResponseStatus int        `bson:"response_status" json:"responseStatus,omitempty"` // Of the last attempt; zero without a response
+	Error          string     `bson:"error" json:"error,omitempty"`                    // Why the last attempt failed
+	CreatedAt      time.Time  `bson:"created_at" json:"createdAt"`
+	DeliveredAt    *time.Time `bson:"delivered_at" json:"deliveredAt,omitempty"`
+}
@@ -0,0 +1,96 @@
+package push
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// recordSize is the aes128gcm record size. Messages are sent as a single
+	// record, so it bounds the payload.
+	recordSize = 4096
+	// maxPayload leaves room in the record for the padding delimiter and the
+	// GCM tag
+	maxPayload = recordSize - 17
+	saltLength = 16
+)
+
+// encrypt encrypts plaintext for the browser that generated the public key
+// p256dh and the authentication secret auth, as RFC 8291 describes. The
+// result is an aes128gcm (RFC 8188) body with one record.
+func encrypt(plaintext, p256dh, auth []byte) ([]byte, error) {
+	if len(plaintext) > maxPayload {
+		return nil, fmt.Errorf("payload is %d bytes, at most %d fit", len(plaintext), maxPayload)
+	}
+	curve := ecdh.P256()
+	browserKey, err := curve.NewPublicKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("browser key: %w", err)
+	}
+	// A fresh key per message, so messages cannot be linked to each other
+	serverKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := serverKey.ECDH(browserKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	serverPublic := serverKey.PublicKey().Bytes()
+	contentKey, nonce, err := deriveKeys(sharedSecret, auth, salt, p256dh, serverPublic)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, and the key the browser derives the secret
+	// with
+	body := make([]byte, 0, saltLength+5+len(serverPublic)+len(plaintext)+17)
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(serverPublic)))
+	body = append(body, serverPublic...)
+
+	// 0x02 marks the last record; no padding follows it
+	record := append(append([]byte{}, plaintext...), 0x02)
+	return gcm.Seal(body, nonce, record, nil), nil
+}
+
+// deriveKeys returns the content encryption key and nonce shared by the
+// server and the browser
+func deriveKeys(sharedSecret, auth, salt, browserPublic, serverPublic []byte) (contentKey, nonce []byte, err error) {
+	keyInfo := "WebPush: info\x00" + string(browserPublic) + string(serverPublic)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, auth, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentKey, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16); err != nil {
+		return nil, nil, err
+	}
+	if nonce, err = hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12); err != nil {
+		return nil, nil, err
+	}
+	return contentKey, nonce, nil
+}
@@ -0,0 +1,196 @@
+// Package push sends Web Push notifications (RFC 8030) to the browsers users
+// subscribed on. Messages are encrypted for each browser (RFC 8291) and
+// signed with the server's VAPID keys (RFC 8292), so no third-party push
+// account is needed.
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+const (
+	// messageTTL is how long push services hold a message for a browser
+	// that is offline. Timer alerts are stale after that.
+	messageTTL = time.Hour
+	// authSecretLength is the size of a browser's authentication secret
+	authSecretLength = 16
+)
+
+var (
+	ErrInvalidEndpoint      = fmt.Errorf("%w: endpoint must be an https URL", models.ErrValidation)
+	ErrInvalidKeys          = fmt.Errorf("%w: keys must hold the browser's p256dh key and auth secret", models.ErrValidation)
+	ErrTooManySubscriptions = fmt.Errorf("%w: at most %d browsers can be subscribed", models.ErrValidation, models.MaxPushSubscriptions)
+
+	// errGone means the browser unsubscribed or the subscription expired
+	errGone = errors.New("push subscription is gone")
+)
+
+// Notification is what the service worker shows
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Tag replaces an earlier notification with the same tag instead of
+	// stacking another one
+	Tag string `json:"tag,omitempty"`
+	// URL is opened when the notification is clicked
+	URL string `json:"url,omitempty"`
+}
+
+// Service notifies users on every browser they subscribed on
+type Service interface {
+	// PublicKey is the VAPID key browsers subscribe with
+	PublicKey() string
+	// Subscribe validates and stores a browser's subscription. A browser
+	// subscribing again replaces its earlier subscription.
+	Subscribe(ctx context.Context, subscription *models.PushSubscription) error
+	// Notify sends n to each of the user's subscriptions and returns how
+	// many accepted it. Subscriptions the push service reports as gone are
+	// deleted.
+	Notify(ctx context.Context, userID string, n Notification) (int, error)
+}
+
+type service struct {
+	db      database.Service
+	keys    *Keys
+	subject string
+	client  *http.Client
+	clock   clock.Clock
+}
+
+// New returns a Service that signs with keys. subject is a mailto: or
+// https: URL push services can contact the operator at.
+func New(db database.Service, keys *Keys, subject string, client *http.Client, clk clock.Clock) Service {
+	return &service{db: db, keys: keys, subject: subject, client: client, clock: clk}
+}
+
+func (s *service) PublicKey() string {
+	return s.keys.PublicKey()
+}
+
+func (s *service) Subscribe(ctx context.Context, subscription *models.PushSubscription) error {
+	if err := Validate(subscription); err != nil {
+		return err
+	}
+
+	subscriptions, err := s.db.FindPushSubscriptions(ctx, subscription.UserID)
+	if err != nil {
+		return err
+	}
+	resubscribing := slices.ContainsFunc(subscriptions, func(existing *models.PushSubscription) bool {
+		return existing.Endpoint == subscription.Endpoint
+	})
+	if !resubscribing && len(subscriptions) >= models.MaxPushSubscriptions {
+		return ErrTooManySubscriptions
+	}
+	return s.db.SavePushSubscription(ctx, subscription)
+}
+
+func (s *service) Notify(ctx context.Context, userID string, n Notification) (int, error) {
+	subscriptions, err := s.db.FindPushSubscriptions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return 0, err
+	}
+
+	var delivered int
+	var errs []error
+	for _, subscription := range subscriptions {
+		err = s.send(ctx, subscription, payload)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, errGone):
+			if err = s.db.DeletePushSubscription(ctx, userID, subscription.Endpoint); err != nil && !errors.Is(err, models.ErrNotFound) {
+				errs = append(errs, err)
+			}
+			slog.InfoContext(ctx, "Removed expired push subscription", "user_id", userID, "subscription_id", subscription.ID.Hex())
+		default:
+			errs = append(errs, err)
+		}
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// send delivers one encrypted message
+func (s *service) send(ctx context.Context, subscription *models.PushSubscription, payload []byte) error {
+	p256dh, err := decodeBase64(subscription.P256dh)
+	if err != nil {
+		return fmt.Errorf("subscription key: %w", err)
+	}
+	auth, err := decodeBase64(subscription.Auth)
+	if err != nil {
+		return fmt.Errorf("subscription secret: %w", err)
+	}
+	body, err := encrypt(payload, p256dh, auth)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.keys.authorization(subscription.Endpoint, s.subject, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(messageTTL/time.Second)))
+	req.Header.Set("Urgency", "high")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errGone
+	case resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push service answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// Validate checks a subscription a browser handed over before it is stored
+func Validate(subscription *models.PushSubscription) error {
+	u, err := url.Parse(subscription.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return ErrInvalidEndpoint
+	}
+	p256dh, err := decodeBase64(subscription.P256dh)
+	if err != nil {
+		return ErrInvalidKeys
+	}
+	if _, err = ecdh.P256().NewPublicKey(p256dh); err != nil {
+		return ErrInvalidKeys
+	}
+	if auth, err := decodeBase64(subscription.Auth); err != nil || len(auth) != authSecretLength {
+		return ErrInvalidKeys
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package push_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+	"github.com/neilsmahajan/productivity-timer/internal/push/pushtest"
+)
+
+const userID = "user-1"
+
+func newKeys(t *testing.T) *push.Keys {
+	t.Helper()
+	publicKey, privateKey, err := push.GenerateKeys()
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+	keys, err := push.ParseKeys(publicKey, privateKey)
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if keys.PublicKey() != publicKey {
+		t.Errorf("PublicKey = %q, want %q", keys.PublicKey(), publicKey)
+	}
+	return keys
+}
+
+func TestParseKeys(t *testing.T) {
+	newKeys(t)
+
+	publicKey, _, _ := push.GenerateKeys()
+	_, privateKey, _ := push.GenerateKeys()
+	if _, err := push.ParseKeys(publicKey, privateKey); err == nil {
+		t.Error("ParseKeys accepted keys from different pairs")
+	}
+	if _, err := push.ParseKeys(publicKey, "not-a-key"); err == nil {
+		t.Error("ParseKeys accepted a malformed private key")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	db := database.NewMemory(clk)
+	pushService := pushtest.NewServer(t)
+	svc := push.New(db, newKeys(t), "mailto:admin@example.com", pushService.Client(), clk)
+
+	laptop := pushService.Subscribe(t, userID)
+	phone := pushService.Subscribe(t, userID)
+	for _, subscription := range []*models.PushSubscription{laptop, phone} {
+		if err := svc.Subscribe(ctx, subscription); err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+	}
+
+	notification := push.Notification{Title: "Countdown finished", Body: "coding: 00:25:00 is up", Tag: "countdown:coding", URL: "/"}
+	delivered, err := svc.Notify(ctx, userID, notification)
+	if err != nil || delivered != 2 {
+		t.Fatalf("Notify = %d, %v; want 2 delivered", delivered, err)
+	}
+	messages := pushService.Messages()
+	if len(messages) != 2 || messages[0].Notification != notification || messages[1].Endpoint != phone.Endpoint {
+		t.Fatalf("messages = %+v, want the notification on both devices", messages)
+	}
+	if got := messages[0].Header.Get("Urgency"); got != "high" {
+		t.Errorf("Urgency = %q, want high", got)
+	}
+
+	// A subscription the push service reports as gone is forgotten
+	pushService.Unsubscribe(laptop.Endpoint)
+	if delivered, err = svc.Notify(ctx, userID, notification); err != nil || delivered != 1 {
+		t.Fatalf("Notify after unsubscribing = %d, %v; want 1 delivered", delivered, err)
+	}
+	subscriptions, err := db.FindPushSubscriptions(ctx, userID)
+	if err != nil || len(subscriptions) != 1 || subscriptions[0].Endpoint != phone.Endpoint {
+		t.Errorf("subscriptions = %+v, %v; want only the phone", subscriptions, err)
+	}
+
+	if delivered, err = svc.Notify(ctx, "someone-else", notification); err != nil || delivered != 0 {
+		t.Errorf("Notify without subscriptions = %d, %v; want 0", delivered, err)
+	}
+}
+
+func TestSubscribeLimit(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	pushService := pushtest.NewServer(t)
+	svc := push.New(database.NewMemory(clk), newKeys(t), "mailto:admin@example.com", pushService.Client(), clk)
+
+	var first *models.PushSubscription
+	for i := range models.MaxPushSubscriptions {
+		subscription := pushService.Subscribe(t, userID)
+		if err := svc.Subscribe(ctx, subscription); err != nil {
+			t.Fatalf("Subscribe %d: %v", i+1, err)
+		}
+		if first == nil {
+			first = subscription
+		}
+	}
+	if err := svc.Subscribe(ctx, pushService.Subscribe(t, userID)); !errors.Is(err, push.ErrTooManySubscriptions) || !errors.Is(err, models.ErrValidation) {
+		t.Errorf("Subscribe over the limit: err = %v, want ErrTooManySubscriptions", err)
+	}
+
+	// A subscribed browser can still renew its subscription
+	if err := svc.Subscribe(ctx, first); err != nil {
+		t.Errorf("Subscribe again at the limit: %v", err)
+	}
+	if err := svc.Subscribe(ctx, pushService.Subscribe(t, "someone-else")); err != nil {
+		t.Errorf("Subscribe for another user: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	pushService := pushtest.NewServer(t)
+	valid := pushService.Subscribe(t, userID)
+
+	tests := []struct {
+		name   string
+		modify func(*models.PushSubscription)
+		want   error
+	}{
+		{"plain http", func(s *models.PushSubscription) { s.Endpoint = "http://push.example.com/x" }, push.ErrInvalidEndpoint},
+		{"no endpoint", func(s *models.PushSubscription) { s.Endpoint = "" }, push.ErrInvalidEndpoint},
+		{"key not on the curve", func(s *models.PushSubscription) { s.P256dh = s.Auth }, push.ErrInvalidKeys},
+		{"short auth", func(s *models.PushSubscription) { s.Auth = "AAAA" }, push.ErrInvalidKeys},
+	}
+	for _, tt := range tests {
+		subscription := *valid
+		tt.modify(&subscription)
+		if err := push.Validate(&subscription); !errors.Is(err, tt.want) || !errors.Is(err, models.ErrValidation) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
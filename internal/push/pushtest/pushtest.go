@@ -0,0 +1,266 @@
+// Package pushtest provides a mock push service for tests. It stands in for
+// both the push service and the subscribed browsers: messages sent to it are
+// checked for a valid VAPID signature, decrypted with the browser's keys and
+// recorded.
+package pushtest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+)
+
+// Message is a notification the server received
+type Message struct {
+	Endpoint     string
+	Header       http.Header
+	Notification push.Notification
+}
+
+// Server is a TLS push service. Use its Client to send to it.
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	browsers map[string]*browser // By endpoint path
+	messages []Message
+}
+
+// browser holds the keys a subscribed browser decrypts messages with
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+	gone bool
+}
+
+// NewServer starts a push service that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	s := &Server{browsers: make(map[string]*browser)}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.receive))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Subscribe creates a browser subscription for userID whose endpoint is on
+// this server
+func (s *Server) Subscribe(t testing.TB, userID string) *models.PushSubscription {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating browser key: %v", err)
+	}
+	auth := make([]byte, 16)
+	if _, err = rand.Read(auth); err != nil {
+		t.Fatalf("generating auth secret: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := fmt.Sprintf("/push/%d", len(s.browsers)+1)
+	s.browsers[path] = &browser{key: key, auth: auth}
+	return &models.PushSubscription{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Endpoint:  s.URL + path,
+		P256dh:    base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:      base64.RawURLEncoding.EncodeToString(auth),
+		UserAgent: "pushtest",
+		CreatedAt: time.Now(),
+	}
+}
+
+// Unsubscribe makes the push service answer 410 Gone for endpoint, as it
+// does once a browser unsubscribed
+func (s *Server) Unsubscribe(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.browsers[strings.TrimPrefix(endpoint, s.URL)]; ok {
+		b.gone = true
+	}
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+func (s *Server) receive(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b, ok := s.browsers[r.URL.Path]
+	s.mu.Unlock()
+	switch {
+	case !ok || r.Method != http.MethodPost:
+		http.NotFound(w, r)
+		return
+	case b.gone:
+		http.Error(w, "subscription expired", http.StatusGone)
+		return
+	}
+
+	if err := s.verifyVAPID(r.Header.Get("Authorization")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("Content-Encoding") != "aes128gcm" || r.Header.Get("TTL") == "" {
+		http.Error(w, "Content-Encoding aes128gcm and TTL are required", http.StatusBadRequest)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	plaintext, err := b.decrypt(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var notification push.Notification
+	if err = json.Unmarshal(plaintext, &notification); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, Message{Endpoint: s.URL + r.URL.Path, Header: r.Header.Clone(), Notification: notification})
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+// verifyVAPID checks the ES256 token of an Authorization header of the form
+// "vapid t=<jwt>, k=<public key>"
+func (s *Server) verifyVAPID(header string) error {
+	params, ok := strings.CutPrefix(header, "vapid ")
+	if !ok {
+		return errors.New("missing vapid authorization")
+	}
+	var token, key string
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+
+	rawKey, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("vapid key: %w", err)
+	}
+	publicKey, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), rawKey)
+	if err != nil {
+		return fmt.Errorf("vapid key: %w", err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("vapid token is not a JWT")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return errors.New("vapid signature is malformed")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, sig := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(publicKey, digest[:], r, sig) {
+		return errors.New("vapid signature does not verify")
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("vapid claims: %w", err)
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err = json.Unmarshal(rawClaims, &claims); err != nil {
+		return fmt.Errorf("vapid claims: %w", err)
+	}
+	if claims.Aud != s.URL || claims.Exp == 0 || claims.Sub == "" {
+		return fmt.Errorf("vapid claims %+v are not for %s", claims, s.URL)
+	}
+	return nil
+}
+
+// decrypt reverses RFC 8291 encryption of a single-record aes128gcm body
+func (b *browser) decrypt(body []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("body is too short")
+	}
+	salt, idLength := body[:16], int(body[20])
+	recordSize := binary.BigEndian.Uint32(body[16:20])
+	if len(body) < 21+idLength || uint32(len(body)-21-idLength) > recordSize {
+		return nil, errors.New("body does not hold one record")
+	}
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLength])
+	if err != nil {
+		return nil, fmt.Errorf("server key: %w", err)
+	}
+	sharedSecret, err := b.key.ECDH(serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := "WebPush: info\x00" + string(b.key.PublicKey().Bytes()) + string(serverKey.Bytes())
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, b.auth, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	contentKey, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record, err := gcm.Open(nil, nonce, body[21+idLength:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+
+	// Strip the padding back to the last-record delimiter
+	end := len(record) - 1
+	for end >= 0 && record[end] == 0 {
+		end--
+	}
+	if end < 0 || record[end] != 0x02 {
+		return nil, errors.New("record has no last-record delimiter")
+	}
+	return record[:end], nil
+}
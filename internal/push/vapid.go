@@ -0,0 +1,106 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// vapidTokenLifetime is how long a VAPID token is valid; push services
+// reject tokens that expire more than 24 hours out
+const vapidTokenLifetime = 12 * time.Hour
+
+// Keys is the application server's VAPID key pair (RFC 8292). Browsers
+// subscribe with the public key and push services only accept messages
+// signed with the matching private key.
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  string
+}
+
+// GenerateKeys returns a new key pair, each key base64url encoded the way
+// ParseKeys expects
+func GenerateKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// ParseKeys reads a base64url encoded P-256 key pair: the uncompressed public
+// point and the private scalar
+func ParseKeys(publicKey, privateKey string) (*Keys, error) {
+	raw, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+	private, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %w", err)
+	}
+	public, err := private.PublicKey.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	if encoded := base64.RawURLEncoding.EncodeToString(public); encoded != strings.TrimRight(publicKey, "=") {
+		return nil, errors.New("public key does not match the private key")
+	}
+	return &Keys{private: private, public: base64.RawURLEncoding.EncodeToString(public)}, nil
+}
+
+// PublicKey returns the public key browsers subscribe with
+func (k *Keys) PublicKey() string {
+	return k.public
+}
+
+// authorization returns the Authorization header value for a message to
+// endpoint. subject is a mailto: or https: contact for the push service.
+func (k *Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenLifetime).Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the raw 64-byte r || s, not ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.public), nil
+}
+
+// decodeBase64 accepts base64url with or without padding, as browsers and
+// key generators differ
+func decodeBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
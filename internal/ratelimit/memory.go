@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped from a memory store
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// refill adds the tokens accrued since the bucket was last updated
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.updated = now
+	}
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns a Store that keeps buckets in process memory
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: make(map[string]*bucket)}
+}
+
+func (m *memoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, limit: limit}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.Rate
+		return Result{RetryAfter: time.Duration(wait * float64(time.Second))}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// sweep drops buckets that have refilled completely, which behave the same as
+// buckets that do not exist yet. Without it every client ever seen would stay
+// in memory.
+func (m *memoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
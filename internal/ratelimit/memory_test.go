@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	for i := range 2 {
+		if result, _ := store.Take(ctx, "user-1", limit, now); !result.Allowed {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+	result, _ := store.Take(ctx, "user-1", limit, now)
+	if result.Allowed || result.RetryAfter != time.Second {
+		t.Fatalf("result = %+v, want limited for a second", result)
+	}
+	if result, _ = store.Take(ctx, "user-2", limit, now); !result.Allowed {
+		t.Error("another key shares the bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if result, _ = store.Take(ctx, "user-1", limit, now); result.Allowed || result.RetryAfter != 500*time.Millisecond {
+		t.Errorf("half refilled: result = %+v, want limited for 500ms", result)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if result, _ = store.Take(ctx, "user-1", limit, now); !result.Allowed {
+		t.Error("refilled token was not available")
+	}
+}
+
+func TestMemoryStoreSweepsIdleBuckets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	limit := Limit{Rate: 1, Burst: 5}
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	_, _ = store.Take(ctx, "idle", limit, now)
+	now = now.Add(sweepInterval)
+	_, _ = store.Take(ctx, "active", limit, now)
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("refilled bucket was kept")
+	}
+	if _, ok := store.buckets["active"]; !ok {
+		t.Error("bucket in use was dropped")
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"5/s", 5},
+		{"300/m", 5},
+		{"7200/h", 2},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRate(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "5", "0/s", "-1/m", "ten/s", "5/d"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) succeeded, want error", in)
+		}
+	}
+}
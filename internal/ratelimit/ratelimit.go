@@ -0,0 +1,82 @@
+// Package ratelimit limits how often a client may make requests, using token
+// buckets kept in a Store.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Limit is a token bucket: up to Burst requests at once, refilled at Rate
+// requests per second. The zero Limit allows everything.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// ParseRate parses a rate such as "300/m" into requests per second. The unit
+// is s, m or h.
+func ParseRate(s string) (float64, error) {
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("rate %q is not of the form N/s, N/m or N/h", s)
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("rate %q must have a positive count", s)
+	}
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	default:
+		return 0, fmt.Errorf("rate %q must be per s, m or h", s)
+	}
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a token is available, when not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets by key. The in-memory store limits each server
+// instance separately; a store shared between instances, such as one backed
+// by Redis, makes a limit apply across the deployment.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Limiter applies one Limit to the buckets it keys in a Store
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+// NewLimiter returns a Limiter whose buckets are named after name, so
+// limiters sharing a store do not share buckets
+func NewLimiter(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{store: store, name: name, limit: limit}
+}
+
+// Allow takes a token from key's bucket
+func (l *Limiter) Allow(ctx context.Context, key string, now time.Time) (Result, error) {
+	return l.store.Take(ctx, l.name+":"+key, l.limit, now)
+}
@@ -0,0 +1,116 @@
+// Package search parses session search queries and highlights what they
+// matched.
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Terms splits query into lowercase words and "quoted phrases". A session
+// matches a query when it contains every term.
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(term string) {
+		term = strings.ToLower(strings.Join(strings.Fields(term), " "))
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for i, part := range strings.Split(query, `"`) {
+		// Odd parts sit between quotes; an unclosed quote runs to the end
+		if i%2 == 1 {
+			add(part)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word)
+		}
+	}
+	return terms
+}
+
+// TextSearch renders terms as a MongoDB $text search string. Quoting every
+// term makes the server require all of them instead of any. Backslashes are
+// dropped so a term cannot escape its closing quote.
+func TextSearch(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `\`, "") + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Matches reports whether every term occurs in at least one of texts,
+// ignoring case
+func Matches(terms []string, texts ...string) bool {
+	lowered := make([]string, len(texts))
+	for i, text := range texts {
+		lowered[i] = strings.ToLower(text)
+	}
+	for _, term := range terms {
+		found := false
+		for _, text := range lowered {
+			if strings.Contains(text, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Segment is a run of text that either matched a search term or did not
+type Segment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// Highlight splits text into segments, marking where terms occur. Overlapping
+// matches are merged.
+func Highlight(text string, terms []string) []Segment {
+	if text == "" {
+		return nil
+	}
+
+	// Lowercasing can change the byte length of some characters, so matches
+	// are only trusted when it does not
+	lowered := strings.ToLower(text)
+	if len(lowered) != len(text) || !utf8.ValidString(text) {
+		return []Segment{{Text: text}}
+	}
+
+	matched := make([]bool, len(text))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(lowered[offset:], term)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			for j := start; j < start+len(term); j++ {
+				matched[j] = true
+			}
+			offset = start + len(term)
+		}
+	}
+
+	var segments []Segment
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || matched[i] != matched[start] {
+			segments = append(segments, Segment{Text: text[start:i], Match: matched[start]})
+			start = i
+		}
+	}
+	return segments
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"billing migration", []string{"billing", "migration"}},
+		{`  Billing  "data  Migration" billing`, []string{"billing", "data migration"}},
+		{`"unclosed phrase`, []string{"unclosed phrase"}},
+		{`""   `, nil},
+	}
+	for _, tt := range tests {
+		if got := Terms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	if got := TextSearch([]string{"billing", "data migration", `c:\`}); got != `"billing" "data migration" "c:"` {
+		t.Errorf("TextSearch = %s", got)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	terms := Terms("billing migr")
+	if !Matches(terms, "work", "Billing Migration, batch 2") {
+		t.Error("note containing every term did not match")
+	}
+	if !Matches(terms, "billing", "migration") {
+		t.Error("terms spread over tag and note did not match")
+	}
+	if Matches(terms, "billing", "invoices") {
+		t.Error("matched with a term missing")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Billing migration: billing", Terms("billing migration"))
+	want := []Segment{
+		{Text: "Billing", Match: true},
+		{Text: " "},
+		{Text: "migration", Match: true},
+		{Text: ": "},
+		{Text: "billing", Match: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Highlight = %+v, want %+v", got, want)
+	}
+
+	// Overlapping terms form one match
+	got = Highlight("database", []string{"data", "tab"})
+	want = []Segment{{Text: "datab", Match: true}, {Text: "ase"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("overlapping Highlight = %+v, want %+v", got, want)
+	}
+
+	if got = Highlight("", []string{"x"}); got != nil {
+		t.Errorf("Highlight of empty text = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/billing"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+// Billing report formats other than the HTML fragment and JSON
+const (
+	reportFormatCSV   = "csv"
+	reportFormatPrint = "print"
+)
+
+func (s *Server) reportsPageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	csrfToken := s.auth.CSRFToken(currentSession(c))
+
+	start, end := thisMonth(s.clock.Now())
+	component := templates.ReportsPage(csrfToken, start.Format(datetimeLocalLayout), end.Format(datetimeLocalLayout))
+	if err := component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering reports page", "err", err)
+	}
+}
+
+// billingReportHandler godoc
+// @Summary Billable hours report
+// @Description Bills the sessions on billable tags at their hourly rate, per tag and per client (a tag's top level). Nested tags use the rate of their nearest billable ancestor unless they are billable themselves.
+// @Description Without start and end the report covers the current month.
+// @Tags reports
+// @Produce html,json,text/csv
+// @Param start query string false "Start datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param end query string false "End datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param tag query string false "Only this tag and the tags nested under it, e.g. a client"
+// @Param round query int false "Round each session to this many minutes; 0 bills exact durations"
+// @Param rounding query string false "Rounding direction: up (default), nearest or down"
+// @Param format query string false "csv for a spreadsheet, print for a printable invoice summary"
+// @Success 200 {object} billing.Report "HTML component with the report, a printable page, CSV, or JSON"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/reports/billing [get]
+func (s *Server) billingReportHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	format := c.Query("format")
+	if format != "" && format != reportFormatCSV && format != reportFormatPrint {
+		s.respondError(c, fmt.Errorf("%w: format must be csv or print", models.ErrValidation))
+		return
+	}
+
+	now := s.clock.Now()
+	startDate, endDate := thisMonth(now)
+	if c.Query("start") != "" || c.Query("end") != "" {
+		var err error
+		if startDate, endDate, err = parseStatsQueryParams(c, now); err != nil {
+			s.respondError(c, err)
+			return
+		}
+	}
+	rounding, err := billing.ParseRounding(c.Query("round"), c.Query("rounding"))
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	tag := c.Query("tag")
+	if tag != "" {
+		var ok bool
+		if tag, ok = models.CleanTag(tag); !ok {
+			s.respondError(c, fmt.Errorf("%w: tag must not have empty levels", models.ErrValidation))
+			return
+		}
+	}
+
+	sessions, err := s.db.GetTagSessions(ctx, user.ID, tag, startDate, endDate)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	settings, err := s.tagSettings(c)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	report := billing.Generate(sessions, settings, rounding, startDate, endDate)
+
+	switch {
+	case format == reportFormatCSV:
+		filename := fmt.Sprintf("billing-%s-%s.csv", startDate.Format(time.DateOnly), endDate.Format(time.DateOnly))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if err = report.WriteCSV(c.Writer); err != nil {
+			slog.ErrorContext(ctx, "Error writing billing CSV", "err", err)
+		}
+		return
+	case format == reportFormatPrint:
+		component := templates.BillingInvoice(report, user.Name, tag)
+		if err = component.Render(ctx, c.Writer); err != nil {
+			slog.ErrorContext(ctx, "Error rendering billing invoice", "err", err)
+		}
+		return
+	case wantsJSON(c):
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	// The download links repeat this request in the other formats
+	query := c.Request.URL.Query()
+	query.Del("format")
+	component := templates.BillingReport(report, query.Encode())
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(ctx, "Error rendering billing report", "err", err)
+	}
+}
+
+// thisMonth returns the start of now's month and the end of now's day
+func thisMonth(now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	end := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 0, now.Location())
+	return start, end
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/auth"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+func (s *Server) devicePageHandler(c *gin.Context) {
+	s.renderDevicePage(c, http.StatusOK, c.Query("code"), false, "")
+}
+
+func (s *Server) approveDeviceHandler(c *gin.Context) {
+	userCode := c.PostForm("user_code")
+
+	err := s.auth.ApproveDevice(c.Request, userCode)
+	if errors.Is(err, auth.ErrInvalidDeviceCode) {
+		s.renderDevicePage(c, http.StatusNotFound, userCode, false, "That code is invalid or has expired. Run ptimer login again.")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error approving device login", "err", err)
+		s.renderDevicePage(c, http.StatusInternalServerError, userCode, false, "Something went wrong, please try again")
+		return
+	}
+
+	s.renderDevicePage(c, http.StatusOK, "", true, "")
+}
+
+func (s *Server) renderDevicePage(c *gin.Context, status int, userCode string, approved bool, message string) {
+	csrfToken := s.auth.CSRFToken(currentSession(c))
+
+	c.Status(status)
+	component := templates.DevicePage(userCode, csrfToken, approved, message)
+	if err := component.Render(c.Request.Context(), c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering device page", "err", err)
+	}
+}
+
+// deviceCodeHandler godoc
+// @Summary Start a command-line login
+// @Description Issues a device code for the CLI and a user code to approve it with at /device
+// @Tags auth
+// @Produce json
+// @Success 200 {object} DeviceCodeResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/device/code [post]
+func (s *Server) deviceCodeHandler(c *gin.Context) {
+	grant, err := s.auth.StartDeviceAuthorization(c.Request.Context())
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	// The request's Host and X-Forwarded-Proto are up to the client, so the
+	// link users are sent to comes from the configuration
+	verificationURI := s.baseURL + "/device"
+	c.JSON(http.StatusOK, DeviceCodeResponse{
+		DeviceCode:              grant.DeviceCode,
+		UserCode:                grant.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?code=" + url.QueryEscape(grant.UserCode),
+		ExpiresIn:               int(grant.ExpiresAt.Sub(s.clock.Now()).Seconds()),
+		Interval:                int(auth.DevicePollInterval.Seconds()),
+	})
+}
+
+// deviceTokenHandler godoc
+// @Summary Exchange a device code for an API token
+// @Description Polled by the CLI until the login is approved. Returns authorization_pending until then.
+// @Tags auth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param device_code formData string true "Device code from /api/v1/device/code"
+// @Success 200 {object} DeviceTokenResponse
+// @Failure 400 {object} ErrorResponse "authorization_pending"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/device/token [post]
+func (s *Server) deviceTokenHandler(c *gin.Context) {
+	token, err := s.auth.ExchangeDeviceCode(c.Request, c.PostForm("device_code"))
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DeviceTokenResponse{AccessToken: token, TokenType: "Bearer"})
+}
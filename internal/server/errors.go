@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/auth"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/ratelimit"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+// classifyError maps an error to its HTTP status, machine-readable code and
+// user-facing message. Unknown errors are internal and get a generic message.
+func classifyError(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, models.ErrValidation):
+		return http.StatusBadRequest, "bad_request", err.Error()
+	case errors.Is(err, auth.ErrNoSession), errors.Is(err, auth.ErrSessionExpired):
+		return http.StatusUnauthorized, "unauthorized", "User not authenticated"
+	case errors.Is(err, auth.ErrInvalidCSRFToken):
+		return http.StatusForbidden, "forbidden", "Missing or invalid CSRF token"
+	case errors.Is(err, auth.ErrAuthorizationPending):
+		return http.StatusBadRequest, "authorization_pending", "Waiting for the login to be approved"
+	case errors.Is(err, models.ErrNotFound), errors.Is(err, auth.ErrSessionNotFound), errors.Is(err, auth.ErrInvalidDeviceCode):
+		return http.StatusNotFound, "not_found", err.Error()
+	case errors.Is(err, models.ErrConflict):
+		return http.StatusConflict, "conflict", err.Error()
+	case errors.Is(err, ratelimit.ErrLimited):
+		return http.StatusTooManyRequests, "rate_limited", "Too many requests, please slow down"
+	default:
+		return http.StatusInternalServerError, "internal_error", "Something went wrong, please try again"
+	}
+}
+
+// respondError aborts the request with the status matching err. HTMX requests
+// get an HTML fragment swapped into the page's error banner; everything else
+// gets an ErrorResponse JSON body. err is recorded on the context for the
+// request log.
+func (s *Server) respondError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	status, code, message := classifyError(err)
+
+	if c.GetHeader("HX-Request") != "true" {
+		c.AbortWithStatusJSON(status, ErrorResponse{Error: code, Message: message})
+		return
+	}
+
+	c.Header("HX-Retarget", "#error-banner")
+	c.Header("HX-Reswap", "innerHTML")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if renderErr := templates.ErrorMessage(message).Render(c.Request.Context(), c.Writer); renderErr != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering error message", "err", renderErr)
+	}
+	c.Abort()
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
+
+	"github.com/neilsmahajan/productivity-timer/internal/auth"
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/metrics"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+	"github.com/neilsmahajan/productivity-timer/internal/webhook"
+)
+
+const (
+	testCSRFToken  = "test-csrf-token"
+	testDeviceCode = "test-device-code"
+	testUserCode   = "BCDF-GHJK"
+	testAPIToken   = "test-api-token"
+)
+
+// fakeAuth is an auth.Service whose logged-in user is set directly by tests.
+// It keeps a list of the user's sessions so the device routes can be driven.
+type fakeAuth struct {
+	mu             sync.Mutex
+	user           *models.User
+	sessions       []*models.Session
+	currentID      string
+	deviceStarted  bool
+	deviceApproved bool
+	// lookups counts the calls to CurrentSession
+	lookups int
+}
+
+func (f *fakeAuth) setUser(user *models.User, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.user = user
+	f.currentID = "current-session"
+	f.sessions = []*models.Session{
+		models.NewSession(f.currentID, user, "test-agent", "127.0.0.1", now, time.Hour),
+		models.NewSession("other-session", user, "other-agent", "10.0.0.1", now, time.Hour),
+	}
+}
+
+func (f *fakeAuth) CurrentSession(_ *http.Request) (*models.Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lookups++
+	if f.user == nil {
+		return nil, auth.ErrNoSession
+	}
+	return &models.Session{ID: f.currentID, UserID: f.user.ID, User: *f.user}, nil
+}
+
+func (f *fakeAuth) StoreUserInSession(_ http.ResponseWriter, _ *http.Request, user *models.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.user = user
+	return nil
+}
+
+func (f *fakeAuth) ClearUserSession(_ http.ResponseWriter, _ *http.Request) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.user = nil
+	f.sessions = nil
+	return nil
+}
+
+func (f *fakeAuth) ListUserSessions(r *http.Request) ([]*models.Session, string, error) {
+	if _, err := f.CurrentSession(r); err != nil {
+		return nil, "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*models.Session(nil), f.sessions...), f.currentID, nil
+}
+
+func (f *fakeAuth) RevokeSession(r *http.Request, id string) error {
+	if _, err := f.CurrentSession(r); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, session := range f.sessions {
+		if session.ID == id {
+			f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+			return nil
+		}
+	}
+	return auth.ErrSessionNotFound
+}
+
+func (f *fakeAuth) RevokeOtherSessions(r *http.Request) error {
+	if _, err := f.CurrentSession(r); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kept []*models.Session
+	for _, session := range f.sessions {
+		if session.ID == f.currentID {
+			kept = append(kept, session)
+		}
+	}
+	f.sessions = kept
+	return nil
+}
+
+func (f *fakeAuth) CSRFToken(_ *models.Session) string {
+	return testCSRFToken
+}
+
+func (f *fakeAuth) VerifyCSRFToken(_ *http.Request, _ *models.Session, token string) error {
+	if token != testCSRFToken {
+		return auth.ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+func (f *fakeAuth) StartDeviceAuthorization(_ context.Context) (*auth.DeviceGrant, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deviceStarted = true
+	return &auth.DeviceGrant{DeviceCode: testDeviceCode, UserCode: testUserCode, ExpiresAt: time.Now().Add(10 * time.Minute)}, nil
+}
+
+func (f *fakeAuth) ApproveDevice(r *http.Request, userCode string) error {
+	if _, err := f.CurrentSession(r); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.deviceStarted || userCode != testUserCode {
+		return auth.ErrInvalidDeviceCode
+	}
+	f.deviceApproved = true
+	return nil
+}
+
+func (f *fakeAuth) ExchangeDeviceCode(_ *http.Request, deviceCode string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !f.deviceStarted || deviceCode != testDeviceCode:
+		return "", auth.ErrInvalidDeviceCode
+	case !f.deviceApproved:
+		return "", auth.ErrAuthorizationPending
+	}
+	f.deviceStarted, f.deviceApproved = false, false
+	return testAPIToken, nil
+}
+
+// testHarness drives the real router against an in-memory database, a fake
+// clock and fakeAuth
+type testHarness struct {
+	t      *testing.T
+	router http.Handler
+	db     database.Service
+	auth   *fakeAuth
+	clock  *clock.Fake
+}
+
+// newTestHarness builds a server backed by an in-memory database. configure
+// can adjust the server before its routes are registered.
+func newTestHarness(t *testing.T, configure ...func(*Server)) *testHarness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// The OAuth routes keep their state in gothic's cookie store, which
+	// NewAuth would otherwise configure from SESSION_SECRET
+	gothic.Store = sessions.NewCookieStore([]byte("test-session-secret"))
+
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.Local))
+	registry := metrics.NewRegistry()
+	db := metrics.InstrumentDatabase(database.NewMemory(clk), registry)
+	fake := &fakeAuth{}
+	// Test receivers listen on loopback
+	webhooks := webhook.New(db, webhook.NewClient(webhookTimeout, true), clk)
+	s := &Server{
+		baseURL:       "http://example.com",
+		db:            db,
+		auth:          fake,
+		timers:        metrics.InstrumentTimers(timer.New(db, webhooks), registry),
+		clock:         clk,
+		metrics:       registry,
+		authProviders: map[string]bool{"google": true},
+		webhooks:      webhooks,
+	}
+	for _, fn := range configure {
+		fn(s)
+	}
+	// The memory database has no schema to bring up to date
+	s.schema.Store(&schemaState{})
+
+	return &testHarness{
+		t:      t,
+		router: s.RegisterRoutes(),
+		db:     db,
+		auth:   fake,
+		clock:  clk,
+	}
+}
+
+// login creates a user in the database and makes it the session user
+func (h *testHarness) login() *models.User {
+	h.t.Helper()
+	user, err := h.db.FindOrCreateUser(context.Background(), &models.User{
+		Email:      "ada@example.com",
+		Name:       "Ada Lovelace",
+		Provider:   "google",
+		ProviderID: "google-123",
+	})
+	if err != nil {
+		h.t.Fatalf("creating user: %v", err)
+	}
+	h.auth.setUser(user, h.clock.Now())
+	return user
+}
+
+type requestOption func(*http.Request)
+
+// withoutCSRF drops the CSRF header the harness sends by default
+func withoutCSRF(r *http.Request) {
+	r.Header.Del(csrfHeader)
+}
+
+// asJSON makes the request look like a plain API client instead of HTMX
+func asJSON(r *http.Request) {
+	r.Header.Del("HX-Request")
+	r.Header.Set("Accept", "application/json")
+}
+
+// withCookies replays the cookies set by an earlier response
+func withCookies(prev *httptest.ResponseRecorder) requestOption {
+	return func(r *http.Request) {
+		for _, cookie := range prev.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+	}
+}
+
+// do sends an HTMX-style request carrying the CSRF token
+func (h *testHarness) do(method, path string, form url.Values, opts ...requestOption) *httptest.ResponseRecorder {
+	h.t.Helper()
+
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, path, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set(csrfHeader, testCSRFToken)
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func tagForm(tag string) url.Values {
+	return url.Values{"tag": {tag}}
+}
+
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func assertContains(t *testing.T, rec *httptest.ResponseRecorder, substrings ...string) {
+	t.Helper()
+	body := rec.Body.String()
+	for _, substring := range substrings {
+		if !strings.Contains(body, substring) {
+			t.Errorf("body does not contain %q; body: %s", substring, body)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/version"
+)
+
+const (
+	readinessTimeout = 2 * time.Second
+
+	readinessStarting    = "starting"
+	readinessReady       = "ready"
+	readinessDegraded    = "degraded"
+	readinessUnavailable = "unavailable"
+)
+
+// livenessHandler godoc
+// @Summary Liveness check
+// @Description Reports that the process is up, without checking its dependencies
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Router /health/live [get]
+func (s *Server) livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "up"})
+}
+
+// readinessHandler godoc
+// @Summary Readiness check
+// @Description Reports whether the server is ready and the database latency. With the metrics token it also reports the connection pool, indexes missing at startup, configured login providers and the build version.
+// @Description Responds 503 while the database is unreachable or its schema is being brought up to date; missing indexes or login providers only degrade the status.
+// @Tags health
+// @Produce json
+// @Param Authorization header string false "Bearer metrics token, for details"
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /health/ready [get]
+func (s *Server) readinessHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	resp := ReadinessResponse{Status: readinessReady}
+	var details *ReadinessDetails
+	if hasBearerToken(c, s.metricsToken) {
+		details = &ReadinessDetails{
+			Version: version.Get(),
+			Indexes: IndexHealth{Status: "unknown"},
+			Auth:    AuthHealth{Providers: s.authProviders},
+		}
+		resp.Details = details
+	}
+
+	health, err := s.db.Health(ctx)
+	if err != nil {
+		_ = c.Error(err)
+		resp.Status = readinessUnavailable
+		resp.Database.Status = "down"
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+	resp.Database = DatabaseHealth{
+		Status:    "up",
+		LatencyMs: float64(health.Latency.Microseconds()) / 1000,
+	}
+	if details != nil {
+		details.Pool = PoolHealth{
+			OpenConnections:  health.OpenConnections,
+			InUseConnections: health.InUseConnections,
+		}
+	}
+
+	schema := s.schema.Load()
+	if schema == nil {
+		resp.Status = readinessStarting
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	indexes := IndexHealth{Status: "ok"}
+	switch {
+	case schema.indexErr != nil:
+		indexes.Status = "unknown"
+		resp.Status = readinessDegraded
+	case len(schema.missingIndexes) > 0:
+		indexes = IndexHealth{Status: "missing", Missing: schema.missingIndexes}
+		resp.Status = readinessDegraded
+	}
+	if details != nil {
+		details.Indexes = indexes
+	}
+
+	if !anyConfigured(s.authProviders) {
+		resp.Status = readinessDegraded
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func anyConfigured(providers map[string]bool) bool {
+	for _, configured := range providers {
+		if configured {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+)
+
+var errUnreachable = errors.New("server selection timeout")
+
+// unreachableDB is a database.Service whose server never answers a ping
+type unreachableDB struct {
+	database.Service
+}
+
+func (unreachableDB) Health(_ context.Context) (*database.Health, error) {
+	return nil, errUnreachable
+}
+
+func TestReadinessWithoutDatabase(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.Local))
+	s := &Server{
+		db:            unreachableDB{database.NewMemory(clk)},
+		auth:          &fakeAuth{},
+		clock:         clk,
+		authProviders: map[string]bool{"google": false},
+	}
+	router := s.RegisterRoutes()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assertStatus(t, rec, http.StatusOK)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	assertStatus(t, rec, http.StatusServiceUnavailable)
+
+	var ready ReadinessResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+		t.Fatalf("decoding readiness: %v", err)
+	}
+	if ready.Status != readinessUnavailable || ready.Database.Status != "down" {
+		t.Errorf("readiness = %+v, want the database reported down", ready)
+	}
+}
+
+// indexlessDB is a database.Service that lacks an index and counts how
+// often it is asked for missing indexes
+type indexlessDB struct {
+	database.Service
+	asked int
+}
+
+func (d *indexlessDB) MissingIndexes(_ context.Context) ([]string, error) {
+	d.asked++
+	return []string{"timers.user_id_status_start_time"}, nil
+}
+
+func TestReadinessAfterPrepare(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.Local))
+	db := &indexlessDB{Service: database.NewMemory(clk)}
+	s := &Server{
+		db:            db,
+		auth:          &fakeAuth{},
+		clock:         clk,
+		metricsToken:  "metrics-token",
+		authProviders: map[string]bool{"google": true},
+	}
+	router := s.RegisterRoutes()
+	ready := func() (int, ReadinessResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+		req.Header.Set("Authorization", "Bearer metrics-token")
+		router.ServeHTTP(rec, req)
+		var resp ReadinessResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding readiness: %v", err)
+		}
+		return rec.Code, resp
+	}
+
+	// The database answers but its schema is not up to date yet
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.Status != readinessStarting {
+		t.Errorf("before prepare: %d %+v, want 503 starting", code, resp)
+	}
+
+	if err := s.prepare(context.Background()); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	for range 3 {
+		code, resp := ready()
+		if code != http.StatusOK || resp.Status != readinessDegraded {
+			t.Errorf("after prepare: %d %+v, want 200 degraded", code, resp)
+		}
+		if resp.Details == nil || resp.Details.Indexes.Status != "missing" || len(resp.Details.Indexes.Missing) != 1 {
+			t.Errorf("details = %+v, want the missing index", resp.Details)
+		}
+	}
+	if db.asked != 1 {
+		t.Errorf("missing indexes listed %d times, want once at startup", db.asked)
+	}
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.Local))
+	if err := waitForDatabase(context.Background(), database.NewMemory(clk)); err != nil {
+		t.Fatalf("waitForDatabase: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := waitForDatabase(ctx, unreachableDB{database.NewMemory(clk)})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled once the wait is abandoned", err)
+	}
+}
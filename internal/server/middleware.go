@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/logging"
+	"github.com/neilsmahajan/productivity-timer/internal/metrics"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/ratelimit"
+)
+
+const (
+	csrfHeader      = "X-CSRF-Token"
+	csrfFormField   = "csrf_token"
+	requestIDHeader = "X-Request-ID"
+
+	userContextKey    = "user"
+	sessionContextKey = "session"
+
+	// maxRequestIDLength bounds request IDs accepted from a proxy
+	maxRequestIDLength = 64
+)
+
+// requestLogger gives every request an ID, taken from the X-Request-ID
+// header when a trusted proxy sent it, and attaches it and the route to the
+// request's log records. Each request is logged once it completes, along
+// with the last error recorded by respondError.
+func requestLogger(trustedProxies []string) gin.HandlerFunc {
+	proxies := parseTrustedProxies(trustedProxies)
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) || !fromTrustedProxy(c, proxies) {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+		ctx := logging.With(c.Request.Context(),
+			slog.String("request_id", requestID),
+			slog.String("route", c.FullPath()),
+		)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration", time.Since(start),
+			"bytes", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+		}
+		if last := c.Errors.Last(); last != nil {
+			attrs = append(attrs, "err", last.Err)
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		// c.Request now carries the user ID added by requireUser
+		slog.Log(c.Request.Context(), level, "Request", attrs...)
+	}
+}
+
+// parseTrustedProxies turns the IP addresses and CIDR ranges of
+// TRUSTED_PROXIES into prefixes; config.Load already rejected invalid ones
+func parseTrustedProxies(trustedProxies []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, proxy := range trustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// fromTrustedProxy reports whether the request's peer is one of proxies
+func fromTrustedProxy(c *gin.Context, proxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireBearerToken only lets requests through that send token in an
+// "Authorization: Bearer" header
+func requireBearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasBearerToken(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "Missing or invalid bearer token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasBearerToken reports whether the request sends token in an
+// "Authorization: Bearer" header; no request has an empty token
+func hasBearerToken(c *gin.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) == 1
+}
+
+// requestMetrics records the latency and status of every request by route.
+// Unmatched paths share one label so scanners cannot inflate the series count.
+func requestMetrics(registry *metrics.Registry) gin.HandlerFunc {
+	duration := registry.NewHistogramVec("ptimer_http_request_duration_seconds",
+		"Latency of HTTP requests by route and status.", metrics.DefaultBuckets, "method", "route", "status")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		duration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// recoverPanics turns a panicking handler into a 500 and logs the panic with
+// its stack trace
+func recoverPanics() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Panic handling request", "panic", recovered, "stack", string(debug.Stack()))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r == '-' || r == '_' || r == '.' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// csrfExemptPaths are called by the command-line client before it has a
+// session, so there is no token to check yet
+var csrfExemptPaths = map[string]bool{
+	"/api/v1/device/code":  true,
+	"/api/v1/device/token": true,
+}
+
+// csrfMiddleware rejects state-changing requests that do not carry the CSRF
+// token of the current session, either in the X-CSRF-Token header (sent by
+// HTMX via hx-headers) or in a csrf_token form field.
+func (s *Server) csrfMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		if csrfExemptPaths[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(csrfHeader)
+		if token == "" {
+			token = c.PostForm(csrfFormField)
+		}
+
+		session, err := s.loadSession(c)
+		if err != nil {
+			s.respondError(c, err)
+			return
+		}
+		if err = s.auth.VerifyCSRFToken(c.Request, session, token); err != nil {
+			s.respondError(c, err)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requireUser resolves the session user into the request context, aborting
+// with 401 when there is none
+func (s *Server) requireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := s.loadSession(c)
+		if err != nil {
+			s.respondError(c, err)
+			return
+		}
+
+		setCurrentUser(c, &session.User)
+		c.Next()
+	}
+}
+
+// requirePageUser is requireUser for full-page routes: anonymous visitors are
+// redirected to the login page instead of getting an error
+func (s *Server) requirePageUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := s.loadSession(c)
+		if err != nil {
+			c.Redirect(http.StatusTemporaryRedirect, "/")
+			c.Abort()
+			return
+		}
+
+		setCurrentUser(c, &session.User)
+		c.Next()
+	}
+}
+
+// rateLimit takes a token from limiter for the current user or, before login,
+// the client's IP address. Requests over the limit get 429 with Retry-After.
+// A nil limiter allows everything; so does a failing store, so an outage of
+// a shared store does not take the API down with it.
+func (s *Server) rateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if user, ok := c.Get(userContextKey); ok {
+			key = "user:" + user.(*models.User).ID
+		}
+		result, err := limiter.Allow(c.Request.Context(), key, s.clock.Now())
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error checking rate limit", "err", err)
+			c.Next()
+			return
+		}
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+			s.respondError(c, ratelimit.ErrLimited)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadedSession is the outcome of looking up a request's login session
+type loadedSession struct {
+	session *models.Session
+	err     error
+}
+
+// loadSession returns the request's login session. It is looked up on first
+// use and kept on the gin context, so the CSRF check, requireUser and the
+// handler of one request share a single lookup.
+func (s *Server) loadSession(c *gin.Context) (*models.Session, error) {
+	if loaded, ok := c.Get(sessionContextKey); ok {
+		return loaded.(loadedSession).session, loaded.(loadedSession).err
+	}
+	session, err := s.auth.CurrentSession(c.Request)
+	c.Set(sessionContextKey, loadedSession{session, err})
+	return session, err
+}
+
+// currentSession returns the session loaded by requireUser or requirePageUser
+func currentSession(c *gin.Context) *models.Session {
+	return c.MustGet(sessionContextKey).(loadedSession).session
+}
+
+// setCurrentUser stores user for currentUser and tags the request's log
+// records with their ID
+func setCurrentUser(c *gin.Context, user *models.User) {
+	c.Set(userContextKey, user)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String("user_id", user.ID)))
+}
+
+// currentUser returns the user stored by requireUser or requirePageUser
+func currentUser(c *gin.Context) *models.User {
+	user, _ := c.MustGet(userContextKey).(*models.User)
+	return user
+}
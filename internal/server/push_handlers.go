@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+	"github.com/neilsmahajan/productivity-timer/web/static"
+)
+
+var errPushDisabled = fmt.Errorf("%w: push notifications are not set up on this server", models.ErrNotFound)
+
+// serviceWorkerHandler serves the service worker that shows push
+// notifications. It sits at the root so its scope covers every page.
+func (s *Server) serviceWorkerHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/javascript; charset=utf-8", static.ServiceWorker)
+}
+
+// pushKeyHandler godoc
+// @Summary Push notification key
+// @Description Returns the VAPID public key to pass as applicationServerKey when subscribing a browser to push notifications
+// @Tags push
+// @Produce json
+// @Success 200 {object} PushKeyResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Push notifications are not set up"
+// @Router /api/v1/push/key [get]
+func (s *Server) pushKeyHandler(c *gin.Context) {
+	if s.push == nil {
+		s.respondError(c, errPushDisabled)
+		return
+	}
+	c.JSON(http.StatusOK, PushKeyResponse{PublicKey: s.push.PublicKey()})
+}
+
+// subscribePushHandler godoc
+// @Summary Subscribe a browser to push notifications
+// @Description Stores a browser's push subscription, as returned by PushManager.subscribe, so the user is notified there when a countdown finishes or a timer runs for a long time without a break. A user can subscribe up to 10 browsers.
+// @Tags push
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param endpoint formData string true "Subscription endpoint, an https URL of the browser's push service"
+// @Param p256dh formData string true "The subscription's p256dh key, base64url encoded"
+// @Param auth formData string true "The subscription's auth secret, base64url encoded"
+// @Success 201 {object} models.PushSubscription
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Push notifications are not set up"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/push/subscriptions [post]
+func (s *Server) subscribePushHandler(c *gin.Context) {
+	if s.push == nil {
+		s.respondError(c, errPushDisabled)
+		return
+	}
+
+	subscription := &models.PushSubscription{
+		ID:        primitive.NewObjectID(),
+		UserID:    currentUser(c).ID,
+		Endpoint:  strings.TrimSpace(c.PostForm("endpoint")),
+		P256dh:    strings.TrimSpace(c.PostForm("p256dh")),
+		Auth:      strings.TrimSpace(c.PostForm("auth")),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.push.Subscribe(c.Request.Context(), subscription); err != nil {
+		s.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// unsubscribePushHandler godoc
+// @Summary Unsubscribe a browser from push notifications
+// @Tags push
+// @Param endpoint query string true "Endpoint of the subscription to remove"
+// @Success 204 "Subscription removed"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/push/subscriptions [delete]
+func (s *Server) unsubscribePushHandler(c *gin.Context) {
+	if err := s.db.DeletePushSubscription(c.Request.Context(), currentUser(c).ID, c.Query("endpoint")); err != nil {
+		s.respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// testPushHandler godoc
+// @Summary Send a test notification
+// @Description Notifies every browser the user subscribed on
+// @Tags push
+// @Produce json
+// @Success 200 {object} PushTestResponse "JSON; HTML requests get an empty 204"
+// @Success 204 "Notification sent (HTML)"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Push notifications are not set up"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/push/test [post]
+func (s *Server) testPushHandler(c *gin.Context) {
+	if s.push == nil {
+		s.respondError(c, errPushDisabled)
+		return
+	}
+
+	delivered, err := s.push.Notify(c.Request.Context(), currentUser(c).ID, push.Notification{
+		Title: "Productivity Timer",
+		Body:  "Notifications are on for this device.",
+		Tag:   "test",
+		URL:   "/",
+	})
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, PushTestResponse{Delivered: delivered})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// notify pushes n to the user's browsers, logging failures; timer changes
+// are not undone because a notification did not arrive
+func (s *Server) notify(ctx context.Context, userID string, n push.Notification) {
+	if s.push == nil {
+		return
+	}
+	if _, err := s.push.Notify(ctx, userID, n); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Error sending push notification", "user_id", userID, "err", err)
+	}
+}
+
+// countdownNotification tells the user a countdown reached zero
+func countdownNotification(state *timer.State) push.Notification {
+	return push.Notification{
+		Title: "⏰ Countdown finished",
+		Body:  fmt.Sprintf("%s: %s is up.", state.Session.Tag, models.FormatDuration(state.Session.Countdown)),
+		Tag:   "countdown:" + state.Session.Tag,
+		URL:   "/",
+	}
+}
+
+// longRunningNotification asks the user whether they forgot a timer
+func longRunningNotification(state *timer.State) push.Notification {
+	return push.Notification{
+		Title: fmt.Sprintf("Still working on %s?", state.Session.Tag),
+		Body:  fmt.Sprintf("Your timer has been running for %s without a break. Stop it if you forgot it.", models.FormatDuration(state.Elapsed-state.Session.Duration)),
+		Tag:   "long-running:" + state.Session.Tag,
+		URL:   "/",
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+	"github.com/neilsmahajan/productivity-timer/internal/push/pushtest"
+)
+
+// withPush sets up push notifications sent to pushService
+func withPush(t *testing.T, pushService *pushtest.Server, server **Server) func(*Server) {
+	return func(s *Server) {
+		publicKey, privateKey, err := push.GenerateKeys()
+		if err != nil {
+			t.Fatalf("GenerateKeys: %v", err)
+		}
+		keys, err := push.ParseKeys(publicKey, privateKey)
+		if err != nil {
+			t.Fatalf("ParseKeys: %v", err)
+		}
+		s.push = push.New(s.db, keys, "mailto:admin@example.com", pushService.Client(), s.clock)
+		s.longTimerAlert = 2 * time.Hour
+		*server = s
+	}
+}
+
+func TestPushNotifications(t *testing.T) {
+	ctx := context.Background()
+	pushService := pushtest.NewServer(t)
+	var s *Server
+	h := newTestHarness(t, withPush(t, pushService, &s))
+	user := h.login()
+
+	rec := h.do(http.MethodGet, "/", nil)
+	assertContains(t, rec, "Notify me on this device", s.push.PublicKey())
+	rec = h.do(http.MethodGet, "/sw.js", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "showNotification")
+
+	rec = h.do(http.MethodGet, "/api/v1/push/key", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var key PushKeyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &key); err != nil || key.PublicKey != s.push.PublicKey() {
+		t.Fatalf("key = %+v, %v; want the server's public key", key, err)
+	}
+
+	subscription := pushService.Subscribe(t, user.ID)
+	form := url.Values{"endpoint": {subscription.Endpoint}, "p256dh": {subscription.P256dh}, "auth": {subscription.Auth}}
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/push/subscriptions", form, withoutCSRF, asJSON), http.StatusForbidden)
+	insecure := url.Values{"endpoint": {"http://push.example.com/1"}, "p256dh": {subscription.P256dh}, "auth": {subscription.Auth}}
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/push/subscriptions", insecure, asJSON), http.StatusBadRequest)
+	rec = h.do(http.MethodPost, "/api/v1/push/subscriptions", form, asJSON)
+	assertStatus(t, rec, http.StatusCreated)
+	if strings.Contains(rec.Body.String(), subscription.Auth) {
+		t.Errorf("subscription response leaks the auth secret: %s", rec.Body.String())
+	}
+
+	rec = h.do(http.MethodPost, "/api/v1/push/test", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"delivered":1`)
+
+	// The sweep stops a finished countdown and says so, even with no page open
+	countdown := tagForm("coding")
+	countdown.Set("countdown", "25")
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", countdown), http.StatusOK)
+	h.clock.Advance(30 * time.Minute)
+	// Shutting down right after the sweep still sends what it found
+	sweepCtx, stopSweeping := context.WithCancel(ctx)
+	s.sweepTimers(sweepCtx)
+	stopSweeping()
+	if !waitTimeout(notifyDrainTimeout, &s.notifying) {
+		t.Fatal("countdown notification still being sent")
+	}
+
+	// A timer running without a break for longer than the alert is flagged
+	// once
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("reading")), http.StatusOK)
+	h.clock.Advance(2 * time.Hour)
+	s.sweepTimers(ctx)
+	s.notifying.Wait()
+	h.clock.Advance(time.Minute)
+	s.sweepTimers(ctx)
+	s.notifying.Wait()
+
+	messages := pushService.Messages()
+	var titles []string
+	for _, message := range messages {
+		titles = append(titles, message.Notification.Title)
+	}
+	want := []string{"Productivity Timer", "⏰ Countdown finished", "Still working on reading?"}
+	if strings.Join(titles, "|") != strings.Join(want, "|") {
+		t.Fatalf("notifications = %q, want %q", titles, want)
+	}
+	if body := messages[1].Notification.Body; body != "coding: 00:25:00 is up." {
+		t.Errorf("countdown notification body = %q", body)
+	}
+
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/push/subscriptions?"+url.Values{"endpoint": {subscription.Endpoint}}.Encode(), nil, asJSON), http.StatusNoContent)
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/push/subscriptions?"+url.Values{"endpoint": {subscription.Endpoint}}.Encode(), nil, asJSON), http.StatusNotFound)
+	rec = h.do(http.MethodPost, "/api/v1/push/test", nil, asJSON)
+	assertContains(t, rec, `"delivered":0`)
+}
+
+func TestPushNotificationsOff(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodGet, "/", nil)
+	if strings.Contains(rec.Body.String(), "Notify me on this device") {
+		t.Error("index page offers notifications the server cannot send")
+	}
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/push/key", nil, asJSON), http.StatusNotFound)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/push/test", nil, asJSON), http.StatusNotFound)
+}
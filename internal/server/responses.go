@@ -1,6 +1,19 @@
 package server
 
-import "github.com/neilsmahajan/productivity-timer/internal/models"
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/search"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+	"github.com/neilsmahajan/productivity-timer/internal/version"
+)
+
+// wantsJSON reports whether the client asked for JSON instead of the HTML
+// fragments the web UI swaps in
+func wantsJSON(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON
+}
 
 // ErrorResponse represents an API error response
 // @Description Error response returned when an API request fails
@@ -9,13 +22,54 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty" example:"User not authenticated"`
 }
 
-// HealthResponse represents the health check response
-// @Description Health check response with database status
+// HealthResponse represents the liveness check response
+// @Description Liveness check response; the process is up and serving
 type HealthResponse struct {
 	Status  string `json:"status" example:"up"`
 	Message string `json:"message,omitempty" example:"It's healthy"`
 }
 
+// ReadinessResponse represents the readiness check response
+// @Description Readiness of the server's dependencies; details need the metrics token
+type ReadinessResponse struct {
+	Status   string            `json:"status" example:"ready"` // starting, ready, degraded or unavailable
+	Database DatabaseHealth    `json:"database"`
+	Details  *ReadinessDetails `json:"details,omitempty"`
+}
+
+// DatabaseHealth reports the database round trip
+type DatabaseHealth struct {
+	Status    string  `json:"status" example:"up"` // up or down
+	LatencyMs float64 `json:"latencyMs,omitempty" example:"1.25"`
+}
+
+// ReadinessDetails reports the running build, the database connection pool,
+// missing indexes and login providers
+type ReadinessDetails struct {
+	Version version.Info `json:"version"`
+	Pool    PoolHealth   `json:"pool"`
+	Indexes IndexHealth  `json:"indexes"`
+	Auth    AuthHealth   `json:"auth"`
+}
+
+// PoolHealth reports the database connection pool
+type PoolHealth struct {
+	OpenConnections  int64 `json:"openConnections" example:"3"`
+	InUseConnections int64 `json:"inUseConnections" example:"1"`
+}
+
+// IndexHealth reports indexes the server expects but the database lacked
+// at startup
+type IndexHealth struct {
+	Status  string   `json:"status" example:"ok"` // ok, missing or unknown
+	Missing []string `json:"missing,omitempty" example:"timers.user_id_status_start_time"`
+}
+
+// AuthHealth reports which login providers have credentials configured
+type AuthHealth struct {
+	Providers map[string]bool `json:"providers"`
+}
+
 // TimerResponse represents a timer session response
 // @Description Timer session state returned after timer operations
 type TimerResponse struct {
@@ -24,11 +78,51 @@ type TimerResponse struct {
 	Status   string               `json:"status" example:"running"`
 }
 
+func newTimerResponse(state *timer.State) TimerResponse {
+	return TimerResponse{
+		Session:  state.Session,
+		Duration: state.Elapsed,
+		Status:   string(state.Session.Status),
+	}
+}
+
+// ActiveTimersResponse lists the running and stopped timers
+// @Description Every running or stopped timer session, in the order they were started
+type ActiveTimersResponse struct {
+	Timers []TimerResponse `json:"timers"`
+}
+
+// ConcurrentTimersResponse reports whether several timers can run at once
+// @Description Whether starting a timer leaves the timers of other tags running
+type ConcurrentTimersResponse struct {
+	ConcurrentTimers bool `json:"concurrentTimers" example:"true"`
+}
+
+// PushKeyResponse holds the key browsers subscribe to push notifications with
+// @Description The server's VAPID public key, base64url encoded
+type PushKeyResponse struct {
+	PublicKey string `json:"publicKey" example:"BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM"`
+}
+
+// PushTestResponse reports how many devices a test notification reached
+// @Description Devices that accepted the test notification
+type PushTestResponse struct {
+	Delivered int `json:"delivered" example:"2"`
+}
+
+// WebhookCreatedResponse is a new webhook with the secret its deliveries are
+// signed with
+// @Description The secret is only returned when the webhook is created
+type WebhookCreatedResponse struct {
+	*models.Webhook
+	Secret string `json:"secret" example:"whsec_3b1f0c5e9a7d4c2b8e6f1a0d9c8b7a6e5f4d3c2b1a0f9e8d7c6b5a4f3e2d1c0b"`
+}
+
 // StatsQueryParams represents the query parameters for stats endpoints
 // @Description Query parameters for filtering stats by date range
 type StatsQueryParams struct {
-	Start string `form:"start" example:"2026-01-01T00:00"`
-	End   string `form:"end" example:"2026-01-31T23:59"`
+	Start string `form:"start" example:"2026-01-01T00:00"` // Or RFC 3339
+	End   string `form:"end" example:"2026-01-31T23:59"`   // Or RFC 3339
 }
 
 // TagSessionsResponse represents the response for tag sessions endpoint
@@ -43,3 +137,37 @@ type TagSessionsResponse struct {
 type TagListResponse struct {
 	Tags []string `json:"tags" example:"coding,reading,exercise"`
 }
+
+// SearchResponse represents the sessions matching a search
+// @Description Sessions whose tag or note contains every search term, most recent first, with their total time
+type SearchResponse struct {
+	Query         string         `json:"query" example:"billing migration"`
+	TotalDuration int64          `json:"totalDuration" example:"5400"` // Seconds across all results
+	Count         int            `json:"count" example:"2"`
+	Results       []SearchResult `json:"results"`
+}
+
+// SearchResult is a matching session with the matched text marked
+type SearchResult struct {
+	Session        models.TimerSession `json:"session"`
+	TagHighlights  []search.Segment    `json:"tagHighlights"`
+	NoteHighlights []search.Segment    `json:"noteHighlights,omitempty"`
+}
+
+// DeviceCodeResponse represents a started command-line login
+// @Description Device code and the user code to approve it with in the browser
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code" example:"BCDF-GHJK"`
+	VerificationURI         string `json:"verification_uri" example:"http://localhost:8080/device"`
+	VerificationURIComplete string `json:"verification_uri_complete" example:"http://localhost:8080/device?code=BCDF-GHJK"`
+	ExpiresIn               int    `json:"expires_in" example:"600"`
+	Interval                int    `json:"interval" example:"5"`
+}
+
+// DeviceTokenResponse represents an API token issued to the command-line client
+// @Description Bearer token for an approved command-line login
+type DeviceTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type" example:"Bearer"`
+}
@@ -1,7 +1,8 @@
 package server
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-contrib/cors"
@@ -10,48 +11,96 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	_ "github.com/neilsmahajan/productivity-timer/docs"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
 	"github.com/neilsmahajan/productivity-timer/web/templates"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	r := gin.Default()
+	r := gin.New()
+	// Route on the escaped path so nested tags such as "client-a%2Fbackend"
+	// stay a single :tag parameter
+	r.UseRawPath = true
+	r.UnescapePathValues = true
+	// Gin trusts every peer's X-Forwarded-For by default; with no proxies
+	// configured the client IP is always the connection's address
+	if err := r.SetTrustedProxies(s.trustedProxies); err != nil {
+		slog.Error("Error setting trusted proxies", "err", err)
+	}
+	r.Use(requestLogger(s.trustedProxies), recoverPanics())
+	if s.metrics != nil {
+		r.Use(requestMetrics(s.metrics))
+		if s.metricsToken != "" {
+			r.GET("/metrics", requireBearerToken(s.metricsToken), gin.WrapH(s.metrics))
+		}
+	}
 
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*", "http://localhost:3000"}, // Add your frontend URL
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
-		AllowCredentials: true, // Enable cookies/auth
-	}))
+	// Cross-origin requests are only allowed from the configured origins;
+	// with none configured the app is same-origin only
+	if len(s.allowedOrigins) > 0 {
+		r.Use(cors.New(cors.Config{
+			AllowOrigins:     s.allowedOrigins,
+			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+			AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", csrfHeader, "HX-Request", "HX-Target", "HX-Trigger", "HX-Current-URL"},
+			AllowCredentials: true, // Enable cookies/auth
+		}))
+	}
+
+	r.Use(s.csrfMiddleware())
 
 	// Static files
 	r.StaticFile("/favicon.ico", "./favicon.ico")
+	r.GET("/sw.js", s.serviceWorkerHandler)
 
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// Page routes (HTML responses)
 	r.GET("/", s.indexHandler)
-	r.GET("/stats", s.statsPageHandler)
-
-	// Health check
-	r.GET("/health", s.healthHandler)
+	r.GET("/stats", s.requirePageUser(), s.statsPageHandler)
+	r.GET("/sessions", s.requirePageUser(), s.sessionsPageHandler)
+	r.GET("/webhooks", s.requirePageUser(), s.webhooksPageHandler)
+	r.GET("/tags", s.requirePageUser(), s.tagsPageHandler)
+	r.GET("/reports", s.requirePageUser(), s.reportsPageHandler)
+	r.GET("/device", s.requirePageUser(), s.devicePageHandler)
+	// Approving is limited like a login so user codes cannot be guessed
+	r.POST("/device", s.requirePageUser(), s.rateLimit(s.authLimiter), s.approveDeviceHandler)
+
+	// Health checks; /health predates the split and reports readiness
+	r.GET("/health/live", s.livenessHandler)
+	r.GET("/health/ready", s.readinessHandler)
+	r.GET("/health", s.readinessHandler)
 
 	// Auth routes (at root level for OAuth compatibility)
 	// These are browser redirect flows, not REST API endpoints
-	r.GET("/auth/:provider", s.authHandler)
-	r.GET("/auth/:provider/callback", s.callbackHandler)
+	r.GET("/auth/:provider", s.rateLimit(s.authLimiter), s.authHandler)
+	r.GET("/auth/:provider/callback", s.rateLimit(s.authLimiter), s.callbackHandler)
 	r.GET("/logout/:provider", s.logoutHandler)
 
+	// Command-line login (device authorization flow); the client has no
+	// session yet, so these sit outside the authenticated API group
+	r.POST("/api/v1/device/code", s.rateLimit(s.authLimiter), s.deviceCodeHandler)
+	r.POST("/api/v1/device/token", s.rateLimit(s.authLimiter), s.deviceTokenHandler)
+
 	// API v1 routes
-	v1 := r.Group("/api/v1")
+	v1 := r.Group("/api/v1", s.requireUser(), s.rateLimit(s.apiLimiter))
 	{
 		// Timer routes
 		timer := v1.Group("/timer")
 		{
+			timer.GET("", s.currentTimerHandler)
+			timer.GET("/active", s.activeTimersHandler)
+			timer.PUT("/concurrent", s.concurrentTimersHandler)
 			timer.POST("/start", s.startTimerHandler)
 			timer.POST("/stop", s.stopTimerHandler)
+			timer.POST("/note", s.timerNoteHandler)
 			timer.POST("/reset", s.resetTimerHandler)
 		}
+		v1.GET("/tags", s.tagsHandler)
+		v1.GET("/tags/:tag", s.tagHandler)
+		v1.PUT("/tags/:tag", s.updateTagHandler)
+		v1.GET("/search", s.searchHandler)
+		v1.GET("/reports/billing", s.billingReportHandler)
 
 		// Stats routes
 		stats := v1.Group("/stats")
@@ -60,6 +109,31 @@ func (s *Server) RegisterRoutes() http.Handler {
 			stats.GET("/tag/:tag/sessions", s.tagSessionsHandler)
 			stats.DELETE("/tag/:tag", s.deleteTagHandler)
 		}
+
+		// Push notification routes
+		push := v1.Group("/push")
+		{
+			push.GET("/key", s.pushKeyHandler)
+			push.POST("/subscriptions", s.subscribePushHandler)
+			push.DELETE("/subscriptions", s.unsubscribePushHandler)
+			push.POST("/test", s.testPushHandler)
+		}
+
+		// Webhook routes
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.GET("", s.listWebhooksHandler)
+			webhooks.POST("", s.createWebhookHandler)
+			webhooks.DELETE("/:id", s.deleteWebhookHandler)
+			webhooks.GET("/:id/deliveries", s.webhookDeliveriesHandler)
+		}
+
+		// Login session routes
+		sessions := v1.Group("/sessions")
+		{
+			sessions.POST("/revoke-others", s.revokeOtherSessionsHandler)
+			sessions.DELETE("/:id", s.revokeSessionHandler)
+		}
 	}
 
 	return r
@@ -69,21 +143,23 @@ func (s *Server) indexHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	// Try to get user from session
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err != nil || gothUser == nil {
+	session, err := s.loadSession(c)
+	if err != nil {
 		// No user logged in, show login page
 		component := templates.LoginPage()
 		if err = component.Render(ctx, c.Writer); err != nil {
-			log.Printf("Error rendering login page: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Error rendering login page", "err", err)
 			c.String(http.StatusInternalServerError, "Error rendering page")
 		}
 		return
 	}
 
 	// User is logged in, get from database
-	user, err := s.db.GetUserByID(ctx, gothUser.UserID)
+	setCurrentUser(c, &session.User)
+	ctx = c.Request.Context()
+	user, err := s.db.GetUserByID(ctx, session.UserID)
 	if err != nil {
-		log.Printf("Error getting user from database: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error getting user from database", "err", err)
 		c.String(http.StatusInternalServerError, "Error getting user")
 		return
 	}
@@ -91,47 +167,51 @@ func (s *Server) indexHandler(c *gin.Context) {
 	if user == nil {
 		// User in session but not in database (e.g., switched to new database)
 		// Clear the stale session and show login page
-		log.Printf("User in session but not in database, clearing stale session")
+		slog.WarnContext(c.Request.Context(), "User in session but not in database, clearing stale session")
 		if clearErr := s.auth.ClearUserSession(c.Writer, c.Request); clearErr != nil {
-			log.Printf("Error clearing stale session: %v", clearErr)
+			slog.ErrorContext(c.Request.Context(), "Error clearing stale session", "err", clearErr)
 		}
 		component := templates.LoginPage()
 		if err = component.Render(ctx, c.Writer); err != nil {
-			log.Printf("Error rendering login page: %v", err)
+			slog.ErrorContext(c.Request.Context(), "Error rendering login page", "err", err)
 			c.String(http.StatusInternalServerError, "Error rendering page")
 		}
 		return
 	}
 
-	userTagStats, err := s.db.FindAllUserTagStats(ctx, gothUser.UserID)
+	tags, err := s.timers.Tags(ctx, user.ID)
 	if err != nil {
-		log.Printf("Error getting user tag stats: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error getting user tags", "err", err)
 	}
-	tags := make([]string, 0, len(userTagStats))
-	for _, tagStats := range userTagStats {
-		tags = append(tags, tagStats.Tag)
+
+	// Restore the timers left running or stopped, e.g. after a page reload
+	var activeSession *models.TimerSession
+	var elapsed int64
+	var timers []*timer.State
+	if user.ConcurrentTimers {
+		if timers, err = s.timers.Active(ctx, user.ID, s.clock.Now()); err != nil {
+			slog.ErrorContext(c.Request.Context(), "Error getting active timers", "err", err)
+		}
+	} else {
+		state, err := s.timers.Current(ctx, user.ID, s.clock.Now())
+		if err == nil {
+			activeSession, elapsed = state.Session, state.Elapsed
+		} else if !errors.Is(err, timer.ErrNoActiveTimer) {
+			slog.ErrorContext(c.Request.Context(), "Error getting current timer", "err", err)
+		}
 	}
 
-	component := templates.IndexPage(gothUser, nil, tags)
-	if err = component.Render(ctx, c.Writer); err != nil {
-		log.Printf("Error rendering index page: %v", err)
-		c.String(http.StatusInternalServerError, "Error rendering page")
+	csrfToken := s.auth.CSRFToken(session)
+
+	// Browsers subscribe to push notifications with the server's key
+	var pushKey string
+	if s.push != nil {
+		pushKey = s.push.PublicKey()
 	}
-}
 
-// healthHandler godoc
-// @Summary Health check endpoint
-// @Description Returns the health status of the API and database
-// @Tags health
-// @Produce json
-// @Success 200 {object} HealthResponse
-// @Failure 503 {object} HealthResponse
-// @Router /health [get]
-func (s *Server) healthHandler(c *gin.Context) {
-	health := s.db.Health()
-	if health["status"] == "unhealthy" {
-		c.JSON(http.StatusServiceUnavailable, health)
-		return
+	component := templates.IndexPage(user, activeSession, elapsed, timers, tags, csrfToken, pushKey)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering index page", "err", err)
+		c.String(http.StatusInternalServerError, "Error rendering page")
 	}
-	c.JSON(http.StatusOK, health)
 }
@@ -0,0 +1,1028 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+
+	"github.com/neilsmahajan/productivity-timer/internal/billing"
+	"github.com/neilsmahajan/productivity-timer/internal/logging"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/ratelimit"
+	"github.com/neilsmahajan/productivity-timer/internal/search"
+)
+
+func TestIndexPage(t *testing.T) {
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "Log in with Google")
+
+	h.login()
+	rec = h.do(http.MethodGet, "/", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "ada@example.com", "00:00:00", "Start Timer", testCSRFToken)
+}
+
+func TestIndexPageRestoresActiveTimer(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding")), http.StatusOK)
+	h.clock.Advance(90 * time.Second)
+
+	rec := h.do(http.MethodGet, "/", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "elapsed: 90", "Working on: <strong>coding</strong>")
+}
+
+func TestHealth(t *testing.T) {
+	const token = "metrics-token"
+	h := newTestHarness(t, func(s *Server) { s.metricsToken = token })
+
+	rec := h.do(http.MethodGet, "/health/live", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"status":"up"`)
+
+	for _, path := range []string{"/health/ready", "/health"} {
+		rec = h.do(http.MethodGet, path, nil)
+		assertStatus(t, rec, http.StatusOK)
+
+		var ready ReadinessResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+		if ready.Status != readinessReady || ready.Database.Status != "up" {
+			t.Errorf("%s = %+v, want ready", path, ready)
+		}
+		if ready.Details != nil {
+			t.Errorf("%s details = %+v, want none without the metrics token", path, ready.Details)
+		}
+
+		rec = h.do(http.MethodGet, path, nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) })
+		assertStatus(t, rec, http.StatusOK)
+		ready = ReadinessResponse{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &ready); err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+		if ready.Details == nil {
+			t.Fatalf("%s = %+v, want details with the metrics token", path, ready)
+		}
+		if ready.Details.Indexes.Status != "ok" || !ready.Details.Auth.Providers["google"] || ready.Details.Version.Version == "" {
+			t.Errorf("%s details = %+v", path, ready.Details)
+		}
+	}
+}
+
+func TestSessionLoadedOncePerRequest(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	for _, req := range []struct {
+		method, path string
+		form         url.Values
+	}{
+		{http.MethodPost, "/api/v1/timer/start", tagForm("coding")},
+		{http.MethodGet, "/stats", nil},
+		{http.MethodGet, "/", nil},
+	} {
+		h.auth.lookups = 0
+		assertStatus(t, h.do(req.method, req.path, req.form), http.StatusOK)
+		if h.auth.lookups != 1 {
+			t.Errorf("%s %s looked the session up %d times, want once", req.method, req.path, h.auth.lookups)
+		}
+	}
+}
+
+func TestAPIRequiresUserAndCSRF(t *testing.T) {
+	tests := []struct {
+		name     string
+		loggedIn bool
+		method   string
+		path     string
+		opts     []requestOption
+		want     int
+		wantCode string
+	}{
+		{"read without user", false, http.MethodGet, "/api/v1/stats/summary", nil, http.StatusUnauthorized, "unauthorized"},
+		{"write without user", false, http.MethodPost, "/api/v1/timer/start", nil, http.StatusUnauthorized, "unauthorized"},
+		{"write without CSRF token", true, http.MethodPost, "/api/v1/timer/start", []requestOption{withoutCSRF}, http.StatusForbidden, "forbidden"},
+		{"delete without CSRF token", true, http.MethodDelete, "/api/v1/stats/tag/coding", []requestOption{withoutCSRF}, http.StatusForbidden, "forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHarness(t)
+			if tt.loggedIn {
+				h.login()
+			}
+
+			rec := h.do(tt.method, tt.path, tagForm("coding"), append(tt.opts, asJSON)...)
+			assertStatus(t, rec, tt.want)
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding error response: %v", err)
+			}
+			if resp.Error != tt.wantCode {
+				t.Errorf("error code = %q, want %q", resp.Error, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestTimerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	user := h.login()
+
+	rec := h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding"))
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "Timer is running...", "elapsed: 0", "Working on: <strong>coding</strong>")
+
+	running, err := h.db.FindTimerSession(ctx, user.ID, "coding", models.StatusRunning)
+	if err != nil {
+		t.Fatalf("running session not stored: %v", err)
+	}
+
+	h.clock.Advance(25 * time.Minute)
+	rec = h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("coding"))
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "00:25:00", "Continue", "Save &amp; Reset")
+
+	stopped, err := h.db.FindTimerSession(ctx, user.ID, "coding", models.StatusStopped)
+	if err != nil {
+		t.Fatalf("stopped session not stored: %v", err)
+	}
+	if stopped.ID != running.ID || stopped.Duration != 1500 {
+		t.Errorf("stopped session = (%s, %ds), want (%s, 1500s)", stopped.ID.Hex(), stopped.Duration, running.ID.Hex())
+	}
+
+	h.clock.Advance(time.Minute)
+	rec = h.do(http.MethodPost, "/api/v1/timer/reset", tagForm("coding"))
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "00:00:00", "Start Timer", "items: [&#34;coding&#34;]")
+
+	if _, err = h.db.FindActiveTimerSession(ctx, user.ID); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("active session after reset: err = %v, want ErrNotFound", err)
+	}
+	tagStats, err := h.db.FindUserTagStats(ctx, user.ID, "coding")
+	if err != nil {
+		t.Fatalf("tag stats not stored: %v", err)
+	}
+	if tagStats.TotalDuration != 1500 || tagStats.SessionCount != 1 {
+		t.Errorf("tag stats = (%ds, %d sessions), want (1500s, 1 session)", tagStats.TotalDuration, tagStats.SessionCount)
+	}
+}
+
+func TestTimerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		form     string
+		want     int
+		wantCode string
+	}{
+		{"start without tag", "/api/v1/timer/start", "", http.StatusBadRequest, "bad_request"},
+		{"stop without running timer", "/api/v1/timer/stop", "coding", http.StatusNotFound, "not_found"},
+		{"reset without stopped timer", "/api/v1/timer/reset", "coding", http.StatusNotFound, "not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" as JSON", func(t *testing.T) {
+			h := newTestHarness(t)
+			h.login()
+
+			rec := h.do(http.MethodPost, tt.path, tagForm(tt.form), asJSON)
+			assertStatus(t, rec, tt.want)
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding error response: %v", err)
+			}
+			if resp.Error != tt.wantCode {
+				t.Errorf("error code = %q, want %q", resp.Error, tt.wantCode)
+			}
+		})
+
+		t.Run(tt.name+" as HTMX", func(t *testing.T) {
+			h := newTestHarness(t)
+			h.login()
+
+			rec := h.do(http.MethodPost, tt.path, tagForm(tt.form))
+			assertStatus(t, rec, tt.want)
+			if got := rec.Header().Get("HX-Retarget"); got != "#error-banner" {
+				t.Errorf("HX-Retarget = %q, want #error-banner", got)
+			}
+			if got := rec.Header().Get("HX-Reswap"); got != "innerHTML" {
+				t.Errorf("HX-Reswap = %q, want innerHTML", got)
+			}
+		})
+	}
+}
+
+func TestStatsPage(t *testing.T) {
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/stats", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+	if got := rec.Header().Get("Location"); got != "/" {
+		t.Errorf("Location = %q, want /", got)
+	}
+
+	h.login()
+	rec = h.do(http.MethodGet, "/stats", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `hx-get="/api/v1/stats/summary"`, testCSRFToken)
+}
+
+// completeSession runs one start/stop/reset cycle of the given length
+func (h *testHarness) completeSession(tag string, length time.Duration) {
+	h.t.Helper()
+	assertStatus(h.t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm(tag)), http.StatusOK)
+	h.clock.Advance(length)
+	assertStatus(h.t, h.do(http.MethodPost, "/api/v1/timer/stop", tagForm(tag)), http.StatusOK)
+	assertStatus(h.t, h.do(http.MethodPost, "/api/v1/timer/reset", tagForm(tag)), http.StatusOK)
+}
+
+func TestStatsFragments(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodGet, "/api/v1/stats/summary", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "No stats found for this time period.")
+
+	h.completeSession("coding", time.Hour)
+	h.completeSession("reading", 30*time.Minute)
+	h.completeSession("coding", 30*time.Minute)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec,
+		"02:00:00",                  // total
+		"00:40:00",                  // average session
+		"<strong>coding</strong>",   // breakdown row
+		"01:30:00",                  // coding total
+		"75.0%",                     // coding share
+		"<strong>reading</strong>",  // breakdown row
+		"/api/v1/stats/tag/reading", // delete button
+		"/api/v1/stats/tag/coding/sessions",
+	)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/coding/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "2 session(s) for tag", "01:00:00", "00:30:00")
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/unknown/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "No sessions found for this time period.")
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary?start=bogus&end=2026-03-04T10:00", nil, asJSON)
+	assertStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestSessionNotes(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodPost, "/api/v1/timer/start", url.Values{"tag": {"coding"}, "note": {"billing migration"}})
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `value="billing migration"`, `hx-post="/api/v1/timer/note"`)
+
+	h.clock.Advance(time.Hour)
+	rec = h.do(http.MethodPost, "/api/v1/timer/note", url.Values{"tag": {"coding"}, "note": {"Billing migration, part 2"}})
+	assertStatus(t, rec, http.StatusNoContent)
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("coding"), asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var stopped TimerResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &stopped); err != nil {
+		t.Fatalf("decoding timer: %v", err)
+	}
+	if stopped.Duration != 3600 || stopped.Session.Note != "Billing migration, part 2" {
+		t.Errorf("stopped = (%d, %q), want the annotated hour", stopped.Duration, stopped.Session.Note)
+	}
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/reset", tagForm("coding")), http.StatusOK)
+	h.completeSession("coding", 30*time.Minute)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/coding/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "2 session(s)", "Billing migration, part 2")
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/coding/sessions?q=BILLING", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `1 session(s) for tag &#34;coding&#34; with a note matching &#34;BILLING&#34;`)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/coding/sessions?q=invoices", nil)
+	assertContains(t, rec, "No sessions with a note matching")
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/note", url.Values{"tag": {"coding"}, "note": {"late"}}, asJSON)
+	assertStatus(t, rec, http.StatusNotFound)
+	rec = h.do(http.MethodPost, "/api/v1/timer/start",
+		url.Values{"tag": {"coding"}, "note": {strings.Repeat("x", models.MaxNoteLength+1)}}, asJSON)
+	assertStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestSearch(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	note := func(tag, note string, length time.Duration) {
+		t.Helper()
+		assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", url.Values{"tag": {tag}, "note": {note}}), http.StatusOK)
+		h.clock.Advance(length)
+		assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/stop", tagForm(tag)), http.StatusOK)
+		assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/reset", tagForm(tag)), http.StatusOK)
+	}
+	note("coding", "Billing migration: schema", time.Hour)
+	note("billing", "Migration dry run", 30*time.Minute)
+	note("coding", "Billing dashboard", 15*time.Minute)
+
+	rec := h.do(http.MethodGet, "/api/v1/search?q=billing+migration", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var resp SearchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding search: %v", err)
+	}
+	if resp.Count != 2 || resp.TotalDuration != 5400 {
+		t.Fatalf("search = (%d results, %ds), want (2, 5400)", resp.Count, resp.TotalDuration)
+	}
+	// Most recent first; the tag itself can match
+	first := resp.Results[0]
+	if first.Session.Tag != "billing" || len(first.TagHighlights) != 1 || !first.TagHighlights[0].Match {
+		t.Errorf("first result = %+v, want the billing tag highlighted", first)
+	}
+	wantNote := []search.Segment{{Text: "Billing", Match: true}, {Text: " "}, {Text: "migration", Match: true}, {Text: ": schema"}}
+	if got := resp.Results[1].NoteHighlights; !reflect.DeepEqual(got, wantNote) {
+		t.Errorf("note highlights = %+v, want %+v", got, wantNote)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/search?q=dashboard", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "1 session(s) matching", "00:15:00 in total", "<mark>dashboard</mark>")
+
+	rec = h.do(http.MethodGet, "/api/v1/search?q=invoices", nil)
+	assertContains(t, rec, "No sessions match")
+
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/search?q=+", nil, asJSON), http.StatusBadRequest)
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/search?q="+strings.Repeat("x", maxSearchQueryLength+1), nil, asJSON), http.StatusBadRequest)
+}
+
+func TestDeleteTag(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	user := h.login()
+
+	h.completeSession("coding", time.Hour)
+	h.completeSession("reading", time.Hour)
+
+	rec := h.do(http.MethodDelete, "/api/v1/stats/tag/coding", nil)
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty", rec.Body.String())
+	}
+
+	if _, err := h.db.FindUserTagStats(ctx, user.ID, "coding"); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("coding tag stats after delete: err = %v, want ErrNotFound", err)
+	}
+	summary, err := h.db.GetStatsSummary(ctx, user.ID, "", h.clock.Now().Add(-24*time.Hour), h.clock.Now(), false)
+	if err != nil {
+		t.Fatalf("GetStatsSummary: %v", err)
+	}
+	if len(summary.TagBreakdown) != 1 || summary.TagBreakdown[0].Tag != "reading" {
+		t.Errorf("tag breakdown after delete = %+v, want only reading", summary.TagBreakdown)
+	}
+}
+
+func TestHierarchicalTags(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHarness(t)
+	user := h.login()
+
+	h.completeSession("client-a / backend", time.Hour)
+	h.completeSession("client-a/meetings", 30*time.Minute)
+	h.completeSession("client-b", 30*time.Minute)
+
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("client-a//backend"), asJSON), http.StatusBadRequest)
+
+	rec := h.do(http.MethodGet, "/api/v1/stats/summary", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var summary models.StatsSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if len(summary.TagTree) != 2 {
+		t.Fatalf("tag tree = %+v, want client-a and client-b", summary.TagTree)
+	}
+	clientA := summary.TagTree[0]
+	if clientA.Tag != "client-a" || clientA.TotalDuration != 5400 || clientA.SessionCount != 2 || clientA.PercentageOfTotal != 75 {
+		t.Errorf("client-a = %+v, want 1h30m over 2 sessions, 75%%", clientA)
+	}
+	if len(clientA.Children) != 2 || clientA.Children[0].Tag != "client-a/backend" || clientA.Children[0].Name != "backend" {
+		t.Errorf("client-a children = %+v, want backend then meetings", clientA.Children)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary?tag=client-a", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	summary = models.StatsSummary{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if summary.TotalDuration != 5400 || len(summary.TagTree) != 1 || summary.TagTree[0].PercentageOfTotal != 100 {
+		t.Errorf("client-a summary = %+v, want only the client-a subtree", summary)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil)
+	assertContains(t, rec,
+		"<strong>client-a</strong>",
+		"<strong>backend</strong>",
+		"/api/v1/stats/tag/client-a%2Fbackend/sessions",
+		`x-show="[&#34;client-a&#34;].every(t =&gt; open[t])"`,
+	)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/client-a/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "2 session(s) for tag", "client-a/backend", "client-a/meetings")
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/client-a%2Fbackend/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "1 session(s) for tag", "01:00:00")
+
+	remainingTags := func() []string {
+		t.Helper()
+		tagStats, err := h.db.FindAllUserTagStats(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("FindAllUserTagStats: %v", err)
+		}
+		var tags []string
+		for _, userTagStats := range tagStats {
+			tags = append(tags, userTagStats.Tag)
+		}
+		slices.Sort(tags)
+		return tags
+	}
+
+	// Deleting a tag keeps the tags nested under it
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/stats/tag/client-a", nil), http.StatusOK)
+	if tags := remainingTags(); !slices.Equal(tags, []string{"client-a/backend", "client-a/meetings", "client-b"}) {
+		t.Errorf("tags after deleting client-a = %v, want its nested tags kept", tags)
+	}
+
+	rec = h.do(http.MethodDelete, "/api/v1/stats/tag/client-a%2Fbackend", nil)
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("HX-Trigger"); got != "statsChanged" {
+		t.Errorf("HX-Trigger = %q, want statsChanged", got)
+	}
+	if tags := remainingTags(); !slices.Equal(tags, []string{"client-a/meetings", "client-b"}) {
+		t.Errorf("tags after deleting client-a/backend = %v, want only that tag gone", tags)
+	}
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/client-a/sessions", nil)
+	assertContains(t, rec, "1 session(s) for tag", "client-a/meetings")
+}
+
+func TestTagSettings(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	h.completeSession("client-a/backend", time.Hour)
+	h.completeSession("reading", 30*time.Minute)
+
+	rec := h.do(http.MethodGet, "/tags", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "client-a/backend", `hx-put="/api/v1/tags/client-a%2Fbackend"`, testCSRFToken)
+
+	settings := url.Values{
+		"color":       {"#FF9800"},
+		"icon":        {"💻"},
+		"description": {"Client A API work"},
+		"billable":    {"on"},
+		"hourlyRate":  {"85.50"},
+	}
+	rec = h.do(http.MethodPut, "/api/v1/tags/client-a%2Fbackend", settings)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "✓ Saved", `value="85.50"`, "Client A API work")
+
+	rec = h.do(http.MethodGet, "/api/v1/tags/client-a%2Fbackend", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var tag models.UserTagStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &tag); err != nil {
+		t.Fatalf("decoding tag: %v", err)
+	}
+	want := models.TagSettings{Color: "#ff9800", Icon: "💻", Description: "Client A API work", Billable: true, HourlyRateCents: 8550}
+	if tag.TagSettings != want || tag.SessionCount != 1 {
+		t.Errorf("tag = %+v, want settings %+v", tag, want)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil, asJSON)
+	var summary models.StatsSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if clientA := summary.TagTree[0]; clientA.Color != "" || clientA.Children[0].Color != "#ff9800" || clientA.Children[0].Icon != "💻" {
+		t.Errorf("client-a = %+v, want only client-a/backend colored", clientA)
+	}
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil)
+	assertContains(t, rec, "background: #ff9800;", "<span>💻</span>")
+
+	rec = h.do(http.MethodPut, "/api/v1/tags/reading", url.Values{"archived": {"on"}}, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	rec = h.do(http.MethodGet, "/api/v1/tags", nil, asJSON)
+	assertContains(t, rec, `{"tags":["client-a/backend"]}`)
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil)
+	assertContains(t, rec, "<strong>reading</strong>")
+
+	for name, form := range map[string]url.Values{
+		"color":         {"color": {"orange"}},
+		"negative rate": {"hourlyRate": {"-1"}},
+		"bad rate":      {"hourlyRate": {"lots"}},
+	} {
+		rec = h.do(http.MethodPut, "/api/v1/tags/reading", form, asJSON)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+	assertStatus(t, h.do(http.MethodPut, "/api/v1/tags/unknown", url.Values{}, asJSON), http.StatusNotFound)
+}
+
+func TestBillingReport(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	h.completeSession("client-a/backend", 50*time.Minute)
+	h.completeSession("client-a/backend", 5*time.Minute)
+	h.completeSession("reading", time.Hour)
+	assertStatus(t, h.do(http.MethodPut, "/api/v1/tags/client-a%2Fbackend", url.Values{"billable": {"on"}, "hourlyRate": {"100"}}), http.StatusOK)
+
+	rec := h.do(http.MethodGet, "/reports", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `hx-get="/api/v1/reports/billing"`, testCSRFToken)
+
+	rec = h.do(http.MethodGet, "/api/v1/reports/billing?round=15", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var report billing.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+	if len(report.Lines) != 1 || report.Lines[0].BilledSeconds != 4500 || report.AmountCents != 12500 {
+		t.Errorf("report = %+v, want client-a/backend billed 1h15m for 125.00", report)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/reports/billing?round=15", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "client-a total", "125.00", "format=csv&amp;round=15", "format=print&amp;round=15")
+
+	rec = h.do(http.MethodGet, "/api/v1/reports/billing?format=csv", nil)
+	assertStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment; filename=\"billing-") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	assertContains(t, rec, "client-a,client-a/backend,2,0.92,0.92,100.00,91.67")
+
+	rec = h.do(http.MethodGet, "/api/v1/reports/billing?format=print&tag=client-a", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "<h1>Invoice summary</h1>", "For client-a", "window.print()")
+
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/reports/billing?format=pdf", nil, asJSON), http.StatusBadRequest)
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/reports/billing?round=often", nil, asJSON), http.StatusBadRequest)
+}
+
+func TestSessionsPage(t *testing.T) {
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/sessions", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+
+	h.login()
+	rec = h.do(http.MethodGet, "/sessions", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec,
+		"2 active session(s)",
+		"This device",
+		"other-agent",
+		"/api/v1/sessions/other-session",
+		"Log out other devices",
+	)
+}
+
+func TestRevokeSessions(t *testing.T) {
+	t.Run("by id", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.login()
+
+		rec := h.do(http.MethodDelete, "/api/v1/sessions/other-session", nil)
+		assertStatus(t, rec, http.StatusOK)
+		if len(h.auth.sessions) != 1 || h.auth.sessions[0].ID != "current-session" {
+			t.Errorf("sessions after revoke = %d, want only the current one", len(h.auth.sessions))
+		}
+
+		rec = h.do(http.MethodDelete, "/api/v1/sessions/other-session", nil, asJSON)
+		assertStatus(t, rec, http.StatusNotFound)
+	})
+
+	t.Run("others", func(t *testing.T) {
+		h := newTestHarness(t)
+		h.login()
+
+		rec := h.do(http.MethodPost, "/api/v1/sessions/revoke-others", nil)
+		assertStatus(t, rec, http.StatusOK)
+		assertContains(t, rec, "1 active session(s)", "This device")
+		if len(h.auth.sessions) != 1 {
+			t.Errorf("sessions after revoke-others = %d, want 1", len(h.auth.sessions))
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodGet, "/logout/google", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+	if got := rec.Header().Get("Location"); got != "/" {
+		t.Errorf("Location = %q, want /", got)
+	}
+
+	rec = h.do(http.MethodGet, "/", nil)
+	assertContains(t, rec, "Log in with Google")
+}
+
+func TestOAuthFlow(t *testing.T) {
+	goth.UseProviders(&faux.Provider{})
+	ctx := context.Background()
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/auth/faux", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || location.Host != "example.com" {
+		t.Fatalf("Location = %q, want the provider's auth URL", rec.Header().Get("Location"))
+	}
+
+	callback := "/auth/faux/callback?state=" + url.QueryEscape(location.Query().Get("state"))
+	rec = h.do(http.MethodGet, callback, nil, withCookies(rec))
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+
+	session, err := h.auth.CurrentSession(nil)
+	if err != nil {
+		t.Fatalf("callback did not log the user in: %v", err)
+	}
+	stored, err := h.db.GetUserByID(ctx, session.UserID)
+	if err != nil || stored == nil {
+		t.Fatalf("user %s not stored: %v", session.UserID, err)
+	}
+	if stored.Provider != "faux" || stored.ProviderID != "id" {
+		t.Errorf("stored user = (%s, %s), want (faux, id)", stored.Provider, stored.ProviderID)
+	}
+
+	// Already logged in: no second round trip to the provider
+	rec = h.do(http.MethodGet, "/auth/faux", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+	if got := rec.Header().Get("Location"); got != "/" {
+		t.Errorf("Location = %q, want /", got)
+	}
+}
+
+func TestOAuthCallbackWithoutState(t *testing.T) {
+	goth.UseProviders(&faux.Provider{})
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/auth/faux/callback", nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+	if _, err := h.auth.CurrentSession(nil); err == nil {
+		t.Error("callback without a pending auth session logged the user in")
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	h := newTestHarness(t, func(s *Server) { s.baseURL = "https://timer.example.com" })
+
+	// The CLI has neither a session nor a CSRF token yet. The link it shows
+	// comes from the configuration, not from what the request claims.
+	rec := h.do(http.MethodPost, "/api/v1/device/code", url.Values{}, asJSON, withoutCSRF, func(r *http.Request) {
+		r.Host = "attacker.example"
+		r.Header.Set("X-Forwarded-Proto", "http")
+	})
+	assertStatus(t, rec, http.StatusOK)
+	var code DeviceCodeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &code); err != nil {
+		t.Fatalf("decoding device code: %v", err)
+	}
+	if code.UserCode != testUserCode || code.VerificationURI != "https://timer.example.com/device" {
+		t.Errorf("device code = %+v", code)
+	}
+
+	poll := func() *httptest.ResponseRecorder {
+		return h.do(http.MethodPost, "/api/v1/device/token", url.Values{"device_code": {code.DeviceCode}}, asJSON, withoutCSRF)
+	}
+	rec = poll()
+	assertStatus(t, rec, http.StatusBadRequest)
+	assertContains(t, rec, `"error":"authorization_pending"`)
+
+	rec = h.do(http.MethodGet, "/device?code="+testUserCode, nil)
+	assertStatus(t, rec, http.StatusTemporaryRedirect)
+
+	h.login()
+	rec = h.do(http.MethodGet, "/device?code="+testUserCode, nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `value="`+testUserCode+`"`, `name="csrf_token" value="`+testCSRFToken+`"`)
+
+	rec = h.do(http.MethodPost, "/device", url.Values{"user_code": {"WRONG"}, csrfFormField: {testCSRFToken}}, withoutCSRF)
+	assertStatus(t, rec, http.StatusNotFound)
+	assertContains(t, rec, "invalid or has expired")
+
+	rec = h.do(http.MethodPost, "/device", url.Values{"user_code": {testUserCode}, csrfFormField: {testCSRFToken}}, withoutCSRF)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "Approved!")
+
+	rec = poll()
+	assertStatus(t, rec, http.StatusOK)
+	var token DeviceTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &token); err != nil {
+		t.Fatalf("decoding token: %v", err)
+	}
+	if token.AccessToken != testAPIToken || token.TokenType != "Bearer" {
+		t.Errorf("token = %+v", token)
+	}
+
+	// Device codes are single use
+	assertStatus(t, poll(), http.StatusNotFound)
+}
+
+func TestJSONAPI(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodGet, "/api/v1/timer", nil, asJSON)
+	assertStatus(t, rec, http.StatusNotFound)
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding"), asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var timerResp TimerResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &timerResp); err != nil {
+		t.Fatalf("decoding timer: %v", err)
+	}
+	if timerResp.Status != "running" || timerResp.Session.Tag != "coding" {
+		t.Errorf("start = %+v", timerResp)
+	}
+
+	h.clock.Advance(10 * time.Minute)
+	rec = h.do(http.MethodGet, "/api/v1/timer", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"duration":600`, `"status":"running"`)
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("coding"), asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"duration":600`, `"status":"stopped"`)
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/reset", tagForm("coding"), asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"status":"completed"`)
+
+	rec = h.do(http.MethodGet, "/api/v1/tags", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `{"tags":["coding"]}`)
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var summary models.StatsSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decoding summary: %v", err)
+	}
+	if summary.TotalDuration != 600 || summary.TotalSessions != 1 || summary.MostUsedTag != "coding" {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/tag/coding/sessions", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"tag":"coding"`, `"duration":600`)
+}
+
+func TestCurrentTimerFragment(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodGet, "/api/v1/timer", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "Start Timer")
+
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding")), http.StatusOK)
+	h.clock.Advance(time.Minute)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("coding")), http.StatusOK)
+
+	rec = h.do(http.MethodGet, "/api/v1/timer", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "00:01:00", "Continue")
+}
+
+func TestConcurrentTimers(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	rec := h.do(http.MethodPut, "/api/v1/timer/concurrent", url.Values{"enabled": {"true"}})
+	assertStatus(t, rec, http.StatusNoContent)
+	if rec.Header().Get("HX-Refresh") != "true" {
+		t.Errorf("HX-Refresh = %q, want the page reloaded", rec.Header().Get("HX-Refresh"))
+	}
+
+	rec = h.do(http.MethodPost, "/api/v1/timer/start", tagForm("on-call"))
+	assertStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("HX-Trigger") != "timersChanged" {
+		t.Errorf("HX-Trigger = %q, want timersChanged", rec.Header().Get("HX-Trigger"))
+	}
+	h.clock.Advance(time.Minute)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding")), http.StatusOK)
+
+	rec = h.do(http.MethodGet, "/", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `id="timers"`, "Working on: <strong>on-call</strong>", "Working on: <strong>coding</strong>", "Start Timer")
+
+	rec = h.do(http.MethodGet, "/api/v1/timer/active", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var active ActiveTimersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &active); err != nil {
+		t.Fatalf("decoding active timers: %v", err)
+	}
+	if len(active.Timers) != 2 || active.Timers[0].Session.Tag != "on-call" || active.Timers[0].Duration != 60 || active.Timers[1].Status != "running" {
+		t.Errorf("active timers = %+v, want on-call then coding, both running", active.Timers)
+	}
+
+	rec = h.do(http.MethodGet, "/api/v1/stats/summary?view=wallclock", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `"wallClock":true`)
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/stats/summary?view=both", nil, asJSON), http.StatusBadRequest)
+
+	rec = h.do(http.MethodPut, "/api/v1/timer/concurrent", url.Values{}, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, `{"concurrentTimers":false}`)
+	rec = h.do(http.MethodGet, "/", nil)
+	assertContains(t, rec, `id="timer-container"`)
+}
+
+func TestCountdownTimer(t *testing.T) {
+	h := newTestHarness(t)
+	h.login()
+
+	form := tagForm("coding")
+	form.Set("countdown", "soon")
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", form, asJSON), http.StatusBadRequest)
+
+	form.Set("countdown", "25")
+	rec := h.do(http.MethodPost, "/api/v1/timer/start", form)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "countdown: 1500", "Counting down from 00:25:00")
+
+	h.clock.Advance(30 * time.Minute)
+	rec = h.do(http.MethodGet, "/api/v1/timer", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var current TimerResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &current); err != nil {
+		t.Fatalf("decoding timer: %v", err)
+	}
+	if current.Status != "stopped" || current.Duration != 1500 {
+		t.Errorf("timer after countdown = %s at %ds, want stopped at 1500s", current.Status, current.Duration)
+	}
+	rec = h.do(http.MethodGet, "/api/v1/timer", nil)
+	assertContains(t, rec, "Countdown finished!")
+}
+
+func TestRequestLogging(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&logs, logging.FormatJSON, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	// httptest requests come from 192.0.2.1
+	h := newTestHarness(t, func(s *Server) { s.trustedProxies = []string{"192.0.2.0/24"} })
+	user := h.login()
+
+	rec := h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("coding"), asJSON, func(r *http.Request) {
+		r.Header.Set(requestIDHeader, "proxy-id-1")
+	})
+	assertStatus(t, rec, http.StatusNotFound)
+	if got := rec.Header().Get(requestIDHeader); got != "proxy-id-1" {
+		t.Errorf("X-Request-ID = %q, want the proxy's ID echoed", got)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+		t.Fatalf("decoding log line %q: %v", logs.String(), err)
+	}
+	for key, want := range map[string]any{
+		"level":      "WARN",
+		"request_id": "proxy-id-1",
+		"route":      "/api/v1/timer/stop",
+		"user_id":    user.ID,
+		"status":     float64(http.StatusNotFound),
+	} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %v", key, record[key], want)
+		}
+	}
+	if errText, _ := record["err"].(string); !strings.Contains(errText, "not found") {
+		t.Errorf("err = %q, want the underlying error", errText)
+	}
+
+	// Unusable incoming IDs are replaced
+	rec = h.do(http.MethodGet, "/health", nil, func(r *http.Request) {
+		r.Header.Set(requestIDHeader, "bad id\n")
+	})
+	if got := rec.Header().Get(requestIDHeader); got == "" || got == "bad id\n" {
+		t.Errorf("X-Request-ID = %q, want a generated ID", got)
+	}
+
+	// Other peers cannot choose the ID
+	rec = newTestHarness(t).do(http.MethodGet, "/health", nil, func(r *http.Request) {
+		r.Header.Set(requestIDHeader, "client-id-1")
+	})
+	if got := rec.Header().Get(requestIDHeader); got == "" || got == "client-id-1" {
+		t.Errorf("X-Request-ID = %q, want a generated ID for an untrusted peer", got)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	const token = "metrics-token"
+	withToken := func(value string) requestOption {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+value) }
+	}
+
+	// Without a token /metrics is not served at all
+	assertStatus(t, newTestHarness(t).do(http.MethodGet, "/metrics", nil), http.StatusNotFound)
+
+	h := newTestHarness(t, func(s *Server) { s.metricsToken = token })
+	h.login()
+
+	assertStatus(t, h.do(http.MethodGet, "/metrics", nil), http.StatusUnauthorized)
+	assertStatus(t, h.do(http.MethodGet, "/metrics", nil, withToken("wrong")), http.StatusUnauthorized)
+
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding"), asJSON), http.StatusOK)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/stop", tagForm("reading"), asJSON), http.StatusNotFound)
+
+	rec := h.do(http.MethodGet, "/metrics", nil, withToken(token))
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec,
+		`ptimer_timer_actions_total{action="start"} 1`,
+		`ptimer_running_timers 1`,
+		`ptimer_http_request_duration_seconds_count{method="POST",route="/api/v1/timer/start",status="200"} 1`,
+		`ptimer_http_request_duration_seconds_count{method="POST",route="/api/v1/timer/stop",status="404"} 1`,
+		`ptimer_db_operation_duration_seconds_count{operation="CreateTimerSession"} 1`,
+	)
+	if strings.Contains(rec.Body.String(), `ptimer_timer_actions_total{action="stop"}`) {
+		t.Error("failed stop was counted")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newTestHarness(t, func(s *Server) {
+		store := ratelimit.NewMemoryStore()
+		s.apiLimiter = ratelimit.NewLimiter(store, "api", ratelimit.Limit{Rate: 1, Burst: 2})
+		s.authLimiter = ratelimit.NewLimiter(store, "auth", ratelimit.Limit{Rate: 1.0 / 60, Burst: 1})
+	})
+	h.login()
+
+	for range 2 {
+		assertStatus(t, h.do(http.MethodGet, "/api/v1/tags", nil, asJSON), http.StatusOK)
+	}
+	rec := h.do(http.MethodGet, "/api/v1/tags", nil, asJSON)
+	assertStatus(t, rec, http.StatusTooManyRequests)
+	assertContains(t, rec, `"error":"rate_limited"`)
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	h.clock.Advance(time.Second)
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/tags", nil, asJSON), http.StatusOK)
+
+	// Before login, clients are told apart by IP address
+	fromIP := func(ip string) requestOption {
+		return func(r *http.Request) { r.RemoteAddr = ip + ":40000" }
+	}
+	startLogin := func(ip string) *httptest.ResponseRecorder {
+		return h.do(http.MethodPost, "/api/v1/device/code", url.Values{}, asJSON, withoutCSRF, fromIP(ip))
+	}
+	assertStatus(t, startLogin("198.51.100.1"), http.StatusOK)
+	rec = startLogin("198.51.100.1")
+	assertStatus(t, rec, http.StatusTooManyRequests)
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	assertStatus(t, startLogin("198.51.100.2"), http.StatusOK)
+
+	// Without trusted proxies, X-Forwarded-For cannot pick a fresh address
+	spoofed := h.do(http.MethodPost, "/api/v1/device/code", url.Values{}, asJSON, withoutCSRF, fromIP("198.51.100.1"),
+		func(r *http.Request) { r.Header.Set("X-Forwarded-For", "203.0.113.9") })
+	assertStatus(t, spoofed, http.StatusTooManyRequests)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/search"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+// maxSearchQueryLength bounds the text search handed to the database
+const maxSearchQueryLength = 200
+
+// searchHandler godoc
+// @Summary Search sessions
+// @Description Returns completed sessions whose tag or note contains every word or "quoted phrase" of the query, with the matches marked and their total time.
+// @Description Without start and end every session is searched.
+// @Tags stats
+// @Produce html,json
+// @Param q query string true "Words or quoted phrases to search for"
+// @Param start query string false "Start datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param end query string false "End datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Success 200 {object} SearchResponse "HTML component with the results, or JSON"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/search [get]
+func (s *Server) searchHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	query := strings.TrimSpace(c.Query("q"))
+	terms := search.Terms(query)
+	if len(terms) == 0 {
+		s.respondError(c, fmt.Errorf("%w: q is required", models.ErrValidation))
+		return
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		s.respondError(c, fmt.Errorf("%w: q must be at most %d characters", models.ErrValidation, maxSearchQueryLength))
+		return
+	}
+
+	now := s.clock.Now()
+	startDate, endDate := time.Time{}, now
+	if c.Query("start") != "" || c.Query("end") != "" {
+		var err error
+		if startDate, endDate, err = parseStatsQueryParams(c, now); err != nil {
+			s.respondError(c, err)
+			return
+		}
+	}
+
+	sessions, err := s.db.SearchTimerSessions(ctx, user.ID, terms, startDate, endDate)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	response := SearchResponse{Query: query, Count: len(sessions), Results: make([]SearchResult, 0, len(sessions))}
+	for _, session := range sessions {
+		response.TotalDuration += session.Duration
+		response.Results = append(response.Results, SearchResult{
+			Session:        *session,
+			TagHighlights:  search.Highlight(session.Tag, terms),
+			NoteHighlights: search.Highlight(session.Note, terms),
+		})
+	}
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	component := templates.SearchResults(query, terms, sessions, response.TotalDuration)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering search results", "err", err)
+	}
+}
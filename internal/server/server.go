@@ -1,30 +1,141 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/joho/godotenv/autoload"
-
 	"github.com/neilsmahajan/productivity-timer/internal/auth"
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/config"
 	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/metrics"
+	"github.com/neilsmahajan/productivity-timer/internal/push"
+	"github.com/neilsmahajan/productivity-timer/internal/ratelimit"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+	"github.com/neilsmahajan/productivity-timer/internal/webhook"
 )
 
 type Server struct {
-	port int
-	db   database.Service
-	auth auth.Service
+	port           int
+	baseURL        string // public URL of the app, without a trailing slash
+	db             database.Service
+	auth           auth.Service
+	timers         timer.Service
+	clock          clock.Clock
+	allowedOrigins []string
+	metrics        *metrics.Registry
+	// metricsToken guards /metrics, which is not served without one
+	metricsToken   string
+	authProviders  map[string]bool
+	trustedProxies []string
+	// apiLimiter limits each user of the API and authLimiter each client of
+	// the login routes; nil when turned off
+	apiLimiter  *ratelimit.Limiter
+	authLimiter *ratelimit.Limiter
+	// push is nil when push notifications are not set up
+	push push.Service
+	// longTimerAlert is how long a timer runs without a break before its
+	// user is notified; zero turns the alert off
+	longTimerAlert time.Duration
+	// notifying tracks the notifications sweepTimers is sending
+	notifying sync.WaitGroup
+	webhooks  webhook.Service
+	// schema is set once the database answered and its schema is up to date;
+	// until then the server is not ready
+	schema atomic.Pointer[schemaState]
+}
+
+// schemaState is what prepare found out about the database's indexes
+type schemaState struct {
+	missingIndexes []string
+	// indexErr is why missing indexes could not be listed
+	indexErr error
 }
 
-func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
+const (
+	// Delays between attempts to reach the database at startup
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+	pingTimeout       = 5 * time.Second
+
+	// How often countdowns that reached zero and long-running timers are
+	// looked for
+	timerSweepInterval = 5 * time.Second
+	// How long a push service gets to accept a notification, and how many
+	// notifications from one sweep are sent at once
+	pushTimeout         = 10 * time.Second
+	maxConcurrentPushes = 8
+	// How long shutdown waits for notifications already being sent
+	notifyDrainTimeout = 5 * time.Second
+	// How often webhook deliveries that are due are sent, and how long a
+	// receiver gets to answer one
+	webhookDeliveryInterval = 2 * time.Second
+	webhookTimeout          = 10 * time.Second
+)
+
+// NewServer returns a server that can listen right away, before the database
+// is reachable. In the background it waits for the database, brings its
+// schema up to date and then starts the sweeps; /health/ready answers 503
+// until then. The returned channel receives an error if a migration or a
+// required index fails, or the server is shut down while waiting. It is
+// closed once shutdown has stopped the background work, so callers wait for
+// it before exiting. NewServer itself only fails on unusable configuration.
+func NewServer(cfg *config.Config) (*http.Server, <-chan error, error) {
+	clk := clock.New()
+	registry := metrics.NewRegistry()
+	mongoDB, err := database.New(cfg.Database, clk)
+	if err != nil {
+		return nil, nil, err
+	}
+	db := metrics.InstrumentDatabase(mongoDB, registry)
+
+	var sessionStore auth.SessionStore = db
+	if cfg.Session.Store == config.SessionStoreMemory {
+		sessionStore = auth.NewMemorySessionStore(clk)
+	}
+
+	webhooks := webhook.New(db, webhook.NewClient(webhookTimeout, cfg.Webhooks.AllowPrivateNetworks), clk)
 	s := &Server{
-		port: port,
-		db:   database.New(),
-		auth: auth.NewAuth(),
+		port:           cfg.Port,
+		baseURL:        cfg.BaseURL,
+		db:             db,
+		auth:           auth.NewAuth(cfg, sessionStore, clk),
+		timers:         metrics.InstrumentTimers(timer.New(db, webhooks), registry),
+		clock:          clk,
+		allowedOrigins: cfg.AllowedOrigins,
+		metrics:        registry,
+		metricsToken:   cfg.Metrics.Token,
+		authProviders: map[string]bool{
+			"google": cfg.Google.ClientID != "" && cfg.Google.ClientSecret != "",
+		},
+		trustedProxies: cfg.TrustedProxies,
+		longTimerAlert: cfg.Push.LongTimerAlert,
+		webhooks:       webhooks,
+	}
+
+	if cfg.Push.Enabled() {
+		keys, err := push.ParseKeys(cfg.Push.PublicKey, cfg.Push.PrivateKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("VAPID keys: %w", err)
+		}
+		// Endpoints come from browsers, so they get the same guard against
+		// reaching the server's own network as webhook URLs
+		pushClient := webhook.NewClient(pushTimeout, cfg.Webhooks.AllowPrivateNetworks)
+		s.push = push.New(db, keys, cfg.Push.Subject, pushClient, clk)
+	}
+
+	limits := ratelimit.NewMemoryStore()
+	if cfg.RateLimit.API.Enabled() {
+		s.apiLimiter = ratelimit.NewLimiter(limits, "api", cfg.RateLimit.API)
+	}
+	if cfg.RateLimit.Auth.Enabled() {
+		s.authLimiter = ratelimit.NewLimiter(limits, "auth", cfg.RateLimit.Auth)
 	}
 
 	// Declare Server config
@@ -36,5 +147,195 @@ func NewServer() *http.Server {
 		WriteTimeout: 30 * time.Second,
 	}
 
-	return server
+	runCtx, stop := context.WithCancel(context.Background())
+	background := make(chan error, 1)
+	prepared := make(chan struct{})
+	var sweeping sync.WaitGroup
+	server.RegisterOnShutdown(func() {
+		stop()
+		<-prepared
+		// Notifications the last sweep handed off get a moment to go out
+		if !waitTimeout(notifyDrainTimeout, &sweeping, &s.notifying) {
+			slog.Warn("Shutting down with notifications still being sent")
+		}
+		close(background)
+	})
+	go func() {
+		defer close(prepared)
+		if err := s.prepare(runCtx); err != nil {
+			background <- err
+			return
+		}
+		// Countdowns stop on time even when no page is open to stop them, and
+		// webhook deliveries go out apart from the requests that queued them.
+		// Each runs on its own so a slow receiver cannot hold up countdowns.
+		sweeping.Go(func() { repeat(runCtx, timerSweepInterval, s.sweepTimers) })
+		sweeping.Go(func() { repeat(runCtx, webhookDeliveryInterval, s.deliverWebhooks) })
+	}()
+
+	return server, background, nil
+}
+
+// prepare waits for the database and brings its schema up to date, then
+// marks the server ready. It returns an error if a migration or a required
+// index fails, or ctx is cancelled while waiting.
+func (s *Server) prepare(ctx context.Context) error {
+	if err := waitForDatabase(ctx, s.db); err != nil {
+		return err
+	}
+
+	// Migrations reshape existing documents, so they run before indexes that
+	// may depend on the new shape are built. The code expects the new shape,
+	// so a failed migration stops startup; it resumes on the next start.
+	migrated, err := s.db.Migrate(ctx)
+	for _, migration := range migrated {
+		slog.Info("Applied migration", "version", migration.Version, "description", migration.Description)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Index creation is a no-op for indexes that already exist. Without a
+	// unique index the code relies on, duplicates would be written, so that
+	// stops startup; other indexes only cost speed.
+	indexCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	created, err := s.db.EnsureIndexes(indexCtx)
+	if len(created) > 0 {
+		slog.Info("Created indexes", "indexes", created)
+	}
+	if errors.Is(err, database.ErrRequiredIndexMissing) {
+		return err
+	} else if err != nil {
+		slog.Error("Error creating indexes", "err", err)
+	}
+
+	// Indexes only change on the next start, so readiness reports what is
+	// missing now instead of asking the database on every check
+	missing, err := s.db.MissingIndexes(indexCtx)
+	if err != nil {
+		slog.Error("Error listing missing indexes", "err", err)
+	}
+	s.schema.Store(&schemaState{missingIndexes: missing, indexErr: err})
+	return nil
+}
+
+// repeat calls fn every interval until ctx is cancelled
+func repeat(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+// sweepTimers stops countdowns that reached zero, each at its due time
+// however late the sweep finds it, and notifies their users. With push
+// notifications set up, users of timers that ran too long without a break
+// are asked whether they forgot them. Notifications are sent in the
+// background, so a slow push service cannot hold up the next sweep.
+func (s *Server) sweepTimers(ctx context.Context) {
+	var notifications []userNotification
+	now := s.clock.Now()
+	states, err := s.timers.ExpireCountdowns(ctx, now)
+	for _, state := range states {
+		slog.InfoContext(ctx, "Countdown finished", "user_id", state.Session.UserID, "tag", state.Session.Tag)
+		notifications = append(notifications, userNotification{state.Session.UserID, countdownNotification(state)})
+	}
+	if err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Error stopping finished countdowns", "err", err)
+	}
+
+	if s.push != nil && s.longTimerAlert > 0 {
+		states, err = s.timers.FlagLongRunning(ctx, s.longTimerAlert, now)
+		for _, state := range states {
+			notifications = append(notifications, userNotification{state.Session.UserID, longRunningNotification(state)})
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Error finding long-running timers", "err", err)
+		}
+	}
+
+	s.notifyAll(ctx, notifications)
+}
+
+// userNotification is a notification for one user
+type userNotification struct {
+	userID       string
+	notification push.Notification
+}
+
+// notifyAll sends notifications in the background, at most
+// maxConcurrentPushes at a time. Shutdown waits for them instead of
+// cancelling them.
+func (s *Server) notifyAll(ctx context.Context, notifications []userNotification) {
+	if s.push == nil || len(notifications) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	s.notifying.Go(func() {
+		slots := make(chan struct{}, maxConcurrentPushes)
+		var sending sync.WaitGroup
+		for _, n := range notifications {
+			slots <- struct{}{}
+			sending.Go(func() {
+				defer func() { <-slots }()
+				s.notify(ctx, n.userID, n.notification)
+			})
+		}
+		sending.Wait()
+	})
+}
+
+// waitTimeout waits for each of wgs in turn for up to timeout in all and
+// reports whether they finished
+func waitTimeout(timeout time.Duration, wgs ...*sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		for _, wg := range wgs {
+			wg.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// deliverWebhooks sends the webhook deliveries that are due, including
+// retries of earlier failures
+func (s *Server) deliverWebhooks(ctx context.Context) {
+	if _, err := s.webhooks.Deliver(ctx); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Error delivering webhooks", "err", err)
+	}
+}
+
+// waitForDatabase pings db until it answers, backing off between attempts
+func waitForDatabase(ctx context.Context, db database.Service) error {
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		health, err := db.Health(pingCtx)
+		cancel()
+		if err == nil {
+			slog.Info("Database ready", "attempt", attempt, "latency", health.Latency)
+			return nil
+		}
+		slog.Warn("Database not ready, retrying", "attempt", attempt, "retry_in", delay, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for database: %w", context.Cause(ctx))
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
 }
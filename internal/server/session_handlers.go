@@ -0,0 +1,78 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+func (s *Server) sessionsPageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	sessions, currentID, err := s.auth.ListUserSessions(c.Request)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	csrfToken := s.auth.CSRFToken(currentSession(c))
+
+	component := templates.SessionsPage(sessions, currentID, csrfToken)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering sessions page", "err", err)
+	}
+}
+
+// revokeSessionHandler godoc
+// @Summary Log out a device
+// @Description Revokes one of the authenticated user's sessions; "current" revokes the one making the request, logging an API token out
+// @Tags sessions
+// @Param id path string true "Session ID, or current"
+// @Success 200 {string} string "Empty response on successful revocation"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/sessions/{id} [delete]
+func (s *Server) revokeSessionHandler(c *gin.Context) {
+	if err := s.auth.RevokeSession(c.Request, c.Param("id")); err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	// Return empty response - HTMX will remove the revoked row
+	c.Status(http.StatusOK)
+}
+
+// revokeOtherSessionsHandler godoc
+// @Summary Log out other devices
+// @Description Revokes every session of the authenticated user except the current one
+// @Tags sessions
+// @Produce html
+// @Success 200 {string} string "HTML component with the remaining sessions"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/sessions/revoke-others [post]
+func (s *Server) revokeOtherSessionsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if err := s.auth.RevokeOtherSessions(c.Request); err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	sessions, currentID, err := s.auth.ListUserSessions(c.Request)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	component := templates.SessionList(sessions, currentID)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering sessions", "err", err)
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+func (s *Server) tagsPageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	csrfToken := s.auth.CSRFToken(currentSession(c))
+
+	tags, err := s.db.FindAllUserTagStats(ctx, currentUser(c).ID)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	// Archived tags go last
+	sort.SliceStable(tags, func(i, j int) bool {
+		if tags[i].Archived != tags[j].Archived {
+			return !tags[i].Archived
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	component := templates.TagsPage(tags, csrfToken)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering tags page", "err", err)
+	}
+}
+
+// tagHandler godoc
+// @Summary Get a tag
+// @Description Returns one of the authenticated user's tags with its totals and settings. Escape the separator in nested tags as %2F.
+// @Tags tags
+// @Produce json
+// @Param tag path string true "Tag name"
+// @Success 200 {object} models.UserTagStats
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tags/{tag} [get]
+func (s *Server) tagHandler(c *gin.Context) {
+	tag, err := s.db.FindUserTagStats(c.Request.Context(), currentUser(c).ID, c.Param("tag"))
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// updateTagHandler godoc
+// @Summary Update a tag's settings
+// @Description Replaces the color, icon, description, archived state and billing settings of one of the authenticated user's tags. Archived tags are left out of GET /api/v1/tags.
+// @Tags tags
+// @Accept x-www-form-urlencoded
+// @Produce html,json
+// @Param tag path string true "Tag name"
+// @Param color formData string false "Hex color such as #4caf50; empty for the default"
+// @Param icon formData string false "Icon, usually a single emoji"
+// @Param description formData string false "Description"
+// @Param archived formData bool false "Hide the tag from the tag picker"
+// @Param billable formData bool false "Time on this tag is billable"
+// @Param hourlyRate formData number false "Hourly rate for billable time, e.g. 85.50"
+// @Success 200 {object} models.UserTagStats "HTML tag form, or JSON"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tags/{tag} [put]
+func (s *Server) updateTagHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	rate, err := parseRate(c.PostForm("hourlyRate"))
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	settings := models.TagSettings{
+		Color:           c.PostForm("color"),
+		Icon:            c.PostForm("icon"),
+		Description:     c.PostForm("description"),
+		Archived:        formBool(c.PostForm("archived")),
+		Billable:        formBool(c.PostForm("billable")),
+		HourlyRateCents: rate,
+	}
+
+	tag, err := s.timers.UpdateTag(ctx, currentUser(c).ID, c.Param("tag"), settings)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, tag)
+		return
+	}
+
+	component := templates.TagForm(tag, true)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering tag form", "err", err)
+	}
+}
+
+// maxHourlyRate keeps rates well within int64 cents
+const maxHourlyRate = 1e9
+
+// parseRate converts a decimal amount such as "85.50" to cents; empty is zero
+func parseRate(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(amount) || math.Abs(amount) > maxHourlyRate {
+		return 0, fmt.Errorf("%w: hourly rate must be an amount such as 85.50", models.ErrValidation)
+	}
+	return int64(math.Round(amount * 100)), nil
+}
+
+// formBool reads a checkbox, which browsers send as "on" when checked
+func formBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "on", "true", "1":
+		return true
+	}
+	return false
+}
+
+// tagSettings returns the settings of every one of the user's tags
+func (s *Server) tagSettings(c *gin.Context) (map[string]models.TagSettings, error) {
+	tags, err := s.db.FindAllUserTagStats(c.Request.Context(), currentUser(c).ID)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]models.TagSettings, len(tags))
+	for _, tag := range tags {
+		settings[tag.Tag] = tag.TagSettings
+	}
+	return settings, nil
+}
@@ -2,147 +2,150 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
 	"github.com/neilsmahajan/productivity-timer/web/templates"
 )
 
+// timersChangedEvent is triggered by responses that start, stop or reset a
+// timer, so a page showing several timers can reload them
+const timersChangedEvent = "timersChanged"
+
 // startTimerHandler godoc
 // @Summary Start a timer session
-// @Description Starts a new timer session or resumes an existing stopped session for the specified tag
+// @Description Starts a new timer session or resumes an existing stopped session for the specified tag. Timers running for other tags are stopped unless the user has turned on concurrent timers.
 // @Tags timer
 // @Accept x-www-form-urlencoded
-// @Produce html
+// @Produce html,json
 // @Param tag formData string true "Tag name for the timer session"
-// @Success 200 {string} string "HTML component for running timer"
+// @Param note formData string false "What the session is for; replaces the session's note"
+// @Param countdown formData string false "Stop the timer by itself once the session has run this long: whole minutes (25) or a duration (1h30m), up to 24 hours"
+// @Success 200 {object} TimerResponse "HTML component for running timer, or JSON"
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/timer/start [post]
 func (s *Server) startTimerHandler(c *gin.Context) {
-	gothUser, tag, err := s.getGothUserAndTag(c)
-	if err != nil || gothUser == nil || tag == "" {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{})
+	countdown, err := parseCountdown(c.PostForm("countdown"))
+	if err != nil {
+		s.respondError(c, err)
 		return
 	}
 
-	// Abandon any orphaned running timers for this user+tag (e.g., from closed tabs)
-	if err = s.db.AbandonRunningTimers(c.Request.Context(), gothUser.UserID, tag); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+	state, err := s.timers.Start(c.Request.Context(), currentUser(c).ID, c.PostForm("tag"), c.PostForm("note"), countdown, s.clock.Now())
+	if err != nil {
+		s.respondError(c, err)
 		return
 	}
 
-	currentTime := time.Now()
-	timerSession, err := s.db.FindTimerSession(c.Request.Context(), gothUser.UserID, tag, models.StatusStopped)
-	if errors.Is(err, mongo.ErrNoDocuments) {
-		timerSession = models.NewTimerSession(gothUser.UserID, tag)
-
-		if err = s.db.CreateTimerSession(c.Request.Context(), timerSession); err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-			return
-		}
-
-		userTagStats, err2 := s.db.FindUserTagStats(c.Request.Context(), gothUser.UserID, tag)
-		if errors.Is(err2, mongo.ErrNoDocuments) {
-			userTagStats = models.NewUserTagStats(gothUser.UserID, tag)
-			if err2 = s.db.CreateUserTagStats(c.Request.Context(), userTagStats); err2 != nil {
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-				return
-			}
-		} else if err2 != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-			return
-		} else {
-			userTagStats.SessionCount++
-			userTagStats.LastUpdated = currentTime
-			if err2 = s.db.UpdateUserTagStats(c.Request.Context(), userTagStats); err2 != nil {
-				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-				return
-			}
-		}
-	} else if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, newTimerResponse(state))
 		return
-	} else {
-		timerSession.Status = models.StatusRunning
-		timerSession.LastUpdated = currentTime
-
-		if err = s.db.UpdateTimerSession(c.Request.Context(), timerSession); err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-			return
-		}
 	}
 
-	component := templates.TimerRunning(timerSession, timerSession.Duration)
+	component := templates.TimerRunning(state.Session, state.Elapsed)
 	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("HX-Trigger", timersChangedEvent)
 	if err = component.Render(c.Request.Context(), c.Writer); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-		return
+		slog.ErrorContext(c.Request.Context(), "Error rendering running timer", "err", err)
 	}
 }
 
+// parseCountdown reads whole minutes ("25") or a duration ("1h30m"); empty
+// means no countdown. The timer service checks the range.
+func parseCountdown(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if minutes, err := strconv.Atoi(value); err == nil {
+		if minutes < 0 || time.Duration(minutes) > models.MaxCountdown/time.Minute {
+			return 0, timer.ErrInvalidCountdown
+		}
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	countdown, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: countdown must be minutes such as 25 or a duration such as 1h30m", models.ErrValidation)
+	}
+	return countdown, nil
+}
+
 // stopTimerHandler godoc
 // @Summary Stop a running timer
 // @Description Stops the currently running timer session and updates the elapsed time
 // @Tags timer
 // @Accept x-www-form-urlencoded
-// @Produce html
+// @Produce html,json
 // @Param tag formData string true "Tag name for the timer session"
-// @Success 200 {string} string "HTML component for stopped timer"
+// @Param note formData string false "What the session was for; replaces the session's note"
+// @Success 200 {object} TimerResponse "HTML component for stopped timer, or JSON"
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/timer/stop [post]
 func (s *Server) stopTimerHandler(c *gin.Context) {
-	gothUser, tag, err := s.getGothUserAndTag(c)
+	state, err := s.timers.Stop(c.Request.Context(), currentUser(c).ID, c.PostForm("tag"), c.PostForm("note"), s.clock.Now())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{})
+		s.respondError(c, err)
 		return
 	}
 
-	timerSession, err := s.db.FindTimerSession(c.Request.Context(), gothUser.UserID, tag, models.StatusRunning)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-		return
-	}
-	if timerSession == nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{})
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, newTimerResponse(state))
 		return
 	}
 
-	currentTime := time.Now()
-	elapsedTime := int64(currentTime.Sub(timerSession.LastUpdated).Seconds())
-
-	timerSession.Duration += elapsedTime
-	timerSession.Status = models.StatusStopped
-	timerSession.LastUpdated = currentTime
-	if err = s.db.UpdateTimerSession(c.Request.Context(), timerSession); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
-		return
+	component := templates.TimerStopped(state.Session, state.Elapsed)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("HX-Trigger", timersChangedEvent)
+	if err = component.Render(c.Request.Context(), c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering stopped timer", "err", err)
 	}
+}
 
-	userTagStats, err := s.db.FindUserTagStats(c.Request.Context(), gothUser.UserID, tag)
+// timerNoteHandler godoc
+// @Summary Set the note of a timer session
+// @Description Replaces the note of the running or stopped session for the tag without affecting its time. An empty note removes it.
+// @Tags timer
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param tag formData string true "Tag name for the timer session"
+// @Param note formData string false "What the session is for"
+// @Success 200 {object} TimerResponse "JSON; HTML requests get an empty 204"
+// @Success 204 "Note saved (HTML)"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/timer/note [post]
+func (s *Server) timerNoteHandler(c *gin.Context) {
+	state, err := s.timers.Annotate(c.Request.Context(), currentUser(c).ID, c.PostForm("tag"), c.PostForm("note"), s.clock.Now())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+		s.respondError(c, err)
 		return
 	}
 
-	userTagStats.LastUpdated = currentTime
-	userTagStats.TotalDuration += elapsedTime
-
-	if err = s.db.UpdateUserTagStats(c.Request.Context(), userTagStats); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, newTimerResponse(state))
 		return
 	}
 
-	component := templates.TimerStopped(timerSession, timerSession.Duration)
-	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err = component.Render(c.Request.Context(), c.Writer); err != nil {
-		return
-	}
+	// The note field stays as the user typed it, so there is nothing to swap
+	c.Status(http.StatusNoContent)
 }
 
 // resetTimerHandler godoc
@@ -150,47 +153,179 @@ func (s *Server) stopTimerHandler(c *gin.Context) {
 // @Description Marks the current timer session as completed and returns to idle state
 // @Tags timer
 // @Accept x-www-form-urlencoded
-// @Produce html
+// @Produce html,json
 // @Param tag formData string true "Tag name for the timer session"
-// @Success 200 {string} string "HTML component for idle timer"
+// @Success 200 {object} TimerResponse "HTML component for idle timer, or JSON with the completed session"
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/timer/reset [post]
 func (s *Server) resetTimerHandler(c *gin.Context) {
-	gothUser, tag, err := s.getGothUserAndTag(c)
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	state, err := s.timers.Reset(ctx, user.ID, c.PostForm("tag"), s.clock.Now())
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{})
+		s.respondError(c, err)
 		return
 	}
 
-	timerSession, err := s.db.FindTimerSession(c.Request.Context(), gothUser.UserID, tag, models.StatusStopped)
-	if err != nil || timerSession == nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, newTimerResponse(state))
 		return
 	}
 
-	currentTime := time.Now()
-	timerSession.Status = models.StatusCompleted
-	timerSession.LastUpdated = currentTime
-	timerSession.EndTime = &currentTime
-	if err = s.db.UpdateTimerSession(c.Request.Context(), timerSession); err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+	c.Header("HX-Trigger", timersChangedEvent)
+	s.renderIdleTimer(c, user.ID)
+}
+
+// currentTimerHandler godoc
+// @Summary Get the active timer
+// @Description Returns the user's running or stopped timer session, if any
+// @Tags timer
+// @Produce html,json
+// @Success 200 {object} TimerResponse "HTML component for the timer, or JSON"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "No active timer (JSON only)"
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/timer [get]
+func (s *Server) currentTimerHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	state, err := s.timers.Current(ctx, user.ID, s.clock.Now())
+	if err != nil {
+		// The web UI shows the idle timer rather than an error
+		if errors.Is(err, timer.ErrNoActiveTimer) && !wantsJSON(c) {
+			s.renderIdleTimer(c, user.ID)
+			return
+		}
+		s.respondError(c, err)
 		return
 	}
 
-	allUserTagStats, err := s.db.FindAllUserTagStats(c.Request.Context(), gothUser.UserID)
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, newTimerResponse(state))
+		return
+	}
+
+	component := templates.TimerStopped(state.Session, state.Elapsed)
+	if state.Running() {
+		component = templates.TimerRunning(state.Session, state.Elapsed)
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering timer", "err", err)
+	}
+}
+
+// activeTimersHandler godoc
+// @Summary List the active timers
+// @Description Returns every running or stopped timer session of the user, in the order they were started
+// @Tags timer
+// @Produce html,json
+// @Success 200 {object} ActiveTimersResponse "HTML timer board, or JSON"
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/timer/active [get]
+func (s *Server) activeTimersHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	states, err := s.timers.Active(ctx, user.ID, s.clock.Now())
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		response := ActiveTimersResponse{Timers: make([]TimerResponse, 0, len(states))}
+		for _, state := range states {
+			response.Timers = append(response.Timers, newTimerResponse(state))
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	tags, err := s.timers.Tags(ctx, user.ID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{})
+		s.respondError(c, err)
+		return
+	}
+	component := templates.TimerBoard(states, tags)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(ctx, "Error rendering timer board", "err", err)
+	}
+}
+
+// concurrentTimersHandler godoc
+// @Summary Turn concurrent timers on or off
+// @Description With concurrent timers, starting a timer leaves the timers of other tags running, e.g. to track on-call time alongside focused work. Otherwise starting a timer stops the one already running.
+// @Tags timer
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param enabled formData bool false "true to run several timers at once"
+// @Success 200 {object} ConcurrentTimersResponse "JSON; HTML requests get an empty 204 and reload the page"
+// @Success 204 "Setting saved (HTML)"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/timer/concurrent [put]
+func (s *Server) concurrentTimersHandler(c *gin.Context) {
+	enabled := formBool(c.PostForm("enabled"))
+	if err := s.db.SetConcurrentTimers(c.Request.Context(), currentUser(c).ID, enabled); err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, ConcurrentTimersResponse{ConcurrentTimers: enabled})
 		return
 	}
-	tags := make([]string, 0, len(allUserTagStats))
-	for _, tagStats := range allUserTagStats {
-		tags = append(tags, tagStats.Tag)
+
+	// The index page shows a single timer or the timer board
+	c.Header("HX-Refresh", "true")
+	c.Status(http.StatusNoContent)
+}
+
+// renderIdleTimer renders the start form with the user's tags
+func (s *Server) renderIdleTimer(c *gin.Context, userID string) {
+	ctx := c.Request.Context()
+
+	tags, err := s.timers.Tags(ctx, userID)
+	if err != nil {
+		s.respondError(c, err)
+		return
 	}
 
 	component := templates.TimerIdle(tags)
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err = component.Render(c.Request.Context(), c.Writer); err != nil {
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering idle timer", "err", err)
+	}
+}
+
+// tagsHandler godoc
+// @Summary List the user's tags
+// @Description Returns every tag the authenticated user has tracked time against, except archived ones
+// @Tags timer
+// @Produce json
+// @Success 200 {object} TagListResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/tags [get]
+func (s *Server) tagsHandler(c *gin.Context) {
+	tags, err := s.timers.Tags(c.Request.Context(), currentUser(c).ID)
+	if err != nil {
+		s.respondError(c, err)
 		return
 	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+	c.JSON(http.StatusOK, TagListResponse{Tags: tags})
 }
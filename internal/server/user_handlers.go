@@ -1,7 +1,7 @@
 package server
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -26,24 +26,23 @@ func (s *Server) callbackHandler(c *gin.Context) {
 	// Complete the OAuth authentication flow
 	gothUser, err := gothic.CompleteUserAuth(c.Writer, c.Request)
 	if err != nil {
-		log.Printf("Error completing auth: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error completing auth", "err", err)
 		c.Redirect(http.StatusTemporaryRedirect, "/")
 		return
 	}
 
 	// Convert goth.User to our User model and save to database
-	user := models.FromGothUser(gothUser)
-	_, err = s.db.FindOrCreateUser(c.Request.Context(), user)
+	user, err := s.db.FindOrCreateUser(c.Request.Context(), models.FromGothUser(gothUser))
 	if err != nil {
-		log.Printf("Error saving user to database: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error saving user to database", "err", err)
 		c.Redirect(http.StatusTemporaryRedirect, "/")
 		return
 	}
 
 	// Store user in our custom session
-	err = s.auth.StoreUserInSession(c.Writer, c.Request, &gothUser)
+	err = s.auth.StoreUserInSession(c.Writer, c.Request, user)
 	if err != nil {
-		log.Printf("Error storing user in session: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error storing user in session", "err", err)
 		c.Redirect(http.StatusTemporaryRedirect, "/")
 		return
 	}
@@ -62,12 +61,12 @@ func (s *Server) callbackHandler(c *gin.Context) {
 func (s *Server) logoutHandler(c *gin.Context) {
 	// Clear Gothic session (OAuth state)
 	if err := gothic.Logout(c.Writer, c.Request); err != nil {
-		log.Printf("Error clearing gothic session: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error clearing gothic session", "err", err)
 	}
 
 	// Clear our custom user session
 	if err := s.auth.ClearUserSession(c.Writer, c.Request); err != nil {
-		log.Printf("Error clearing user session: %v", err)
+		slog.ErrorContext(c.Request.Context(), "Error clearing user session", "err", err)
 	}
 
 	c.Redirect(http.StatusTemporaryRedirect, "/")
@@ -87,19 +86,23 @@ func (s *Server) authHandler(c *gin.Context) {
 	c.Request.URL.RawQuery = q.Encode()
 
 	// Check if user is already authenticated
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err == nil && gothUser != nil {
+	session, err := s.loadSession(c)
+	if err == nil {
 		// Check if user exists in database
-		user, dbErr := s.db.GetUserByID(c.Request.Context(), gothUser.UserID)
+		user, dbErr := s.db.GetUserByID(c.Request.Context(), session.UserID)
 		if dbErr == nil && user != nil {
 			// User exists in both session and database, redirect to index
 			c.Redirect(http.StatusTemporaryRedirect, "/")
 			return
 		}
 		// User in session but not in database, clear session and re-authenticate
-		log.Printf("User in session but not in database, clearing session")
+		if dbErr != nil {
+			slog.ErrorContext(c.Request.Context(), "Error getting user from database", "err", dbErr)
+		} else {
+			slog.WarnContext(c.Request.Context(), "User in session but not in database, clearing session")
+		}
 		if clearErr := s.auth.ClearUserSession(c.Writer, c.Request); clearErr != nil {
-			log.Printf("Error clearing session: %v", clearErr)
+			slog.ErrorContext(c.Request.Context(), "Error clearing session", "err", clearErr)
 		}
 	}
 
@@ -1,77 +1,109 @@
 package server
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/webhook"
 	"github.com/neilsmahajan/productivity-timer/web/templates"
 )
 
+// Stats summary views
+const (
+	statsViewTracked   = "tracked"
+	statsViewWallClock = "wallclock"
+)
+
 func (s *Server) statsPageHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	// Try to get user from session
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err != nil || gothUser == nil {
-		c.Redirect(http.StatusTemporaryRedirect, "/")
-		return
-	}
+	csrfToken := s.auth.CSRFToken(currentSession(c))
 
-	component := templates.StatsPage()
-	if err = component.Render(ctx, c.Writer); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+	component := templates.StatsPage(csrfToken)
+	if err := component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering stats page", "err", err)
 	}
 }
 
 // statsSummaryHandler godoc
 // @Summary Get stats summary
-// @Description Returns aggregated statistics for the authenticated user within a date range
+// @Description Returns aggregated statistics for the authenticated user within a date range, with per-tag totals rolled up along tag levels ("client-a/backend" counts towards "client-a")
 // @Tags stats
-// @Produce html
-// @Param start query string false "Start datetime (format: 2006-01-02T15:04)"
-// @Param end query string false "End datetime (format: 2006-01-02T15:04)"
-// @Success 200 {string} string "HTML component with stats summary"
+// @Produce html,json
+// @Param start query string false "Start datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param end query string false "End datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param tag query string false "Only this tag and the tags nested under it"
+// @Param view query string false "wallclock to share the time when several timers ran at once between their tags instead of counting it for each"
+// @Success 200 {object} models.StatsSummary "HTML component with stats summary, or JSON"
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/stats/summary [get]
 func (s *Server) statsSummaryHandler(c *gin.Context) {
 	ctx := c.Request.Context()
+	user := currentUser(c)
 
-	// Try to get user from session
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err != nil || gothUser == nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
+	startDate, endDate, err := parseStatsQueryParams(c, s.clock.Now())
+	if err != nil {
+		s.respondError(c, err)
 		return
 	}
 
-	startDate, endDate, err := parseStatsQueryParams(c)
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+	tag := c.Query("tag")
+	if tag != "" {
+		var ok bool
+		if tag, ok = models.CleanTag(tag); !ok {
+			s.respondError(c, fmt.Errorf("%w: tag must not have empty levels", models.ErrValidation))
+			return
+		}
+	}
+
+	var wallClock bool
+	switch c.Query("view") {
+	case "", statsViewTracked:
+	case statsViewWallClock:
+		wallClock = true
+	default:
+		s.respondError(c, fmt.Errorf("%w: view must be tracked or wallclock", models.ErrValidation))
 		return
 	}
 
-	statsSummary, err := s.db.GetStatsSummary(ctx, gothUser.UserID, startDate, endDate)
+	statsSummary, err := s.db.GetStatsSummary(ctx, user.ID, tag, startDate, endDate, wallClock)
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		s.respondError(c, err)
+		return
+	}
+	settings, err := s.tagSettings(c)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	statsSummary.ApplyTagSettings(settings)
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusOK, statsSummary)
 		return
 	}
 
 	component := templates.StatsSummary(statsSummary)
 	if err = component.Render(ctx, c.Writer); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		slog.ErrorContext(c.Request.Context(), "Error rendering stats", "err", err)
 	}
 }
 
-// parseStatsQueryParams extracts and validates start/end dates from query params
-func parseStatsQueryParams(c *gin.Context) (time.Time, time.Time, error) {
+// parseStatsQueryParams extracts and validates start/end dates from query
+// params, interpreting them in now's location
+func parseStatsQueryParams(c *gin.Context, now time.Time) (time.Time, time.Time, error) {
 	startStr := c.Query("start")
 	endStr := c.Query("end")
 
 	// Default to today if no params provided
-	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, now.Location())
 
@@ -79,104 +111,123 @@ func parseStatsQueryParams(c *gin.Context) (time.Time, time.Time, error) {
 		return startOfDay, endOfDay, nil
 	}
 
-	// Parse datetime-local format: "2006-01-02T15:04"
-	const layout = "2006-01-02T15:04"
-
-	startDate, err := time.ParseInLocation(layout, startStr, now.Location())
+	startDate, err := parseStatsTime(startStr, now.Location())
 	if err != nil {
-		return time.Time{}, time.Time{}, err
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: start must be formatted as %s or RFC 3339", models.ErrValidation, datetimeLocalLayout)
 	}
 
-	endDate, err := time.ParseInLocation(layout, endStr, now.Location())
+	endDate, err := parseStatsTime(endStr, now.Location())
 	if err != nil {
-		return time.Time{}, time.Time{}, err
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: end must be formatted as %s or RFC 3339", models.ErrValidation, datetimeLocalLayout)
 	}
 
 	return startDate, endDate, nil
 }
 
+// datetimeLocalLayout is the format of the stats page's datetime-local inputs
+const datetimeLocalLayout = "2006-01-02T15:04"
+
+// parseStatsTime parses a datetime-local value in loc, or an RFC 3339
+// timestamp carrying its own offset as sent by the command-line client
+func parseStatsTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(datetimeLocalLayout, value, loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // tagSessionsHandler godoc
 // @Summary Get sessions for a specific tag
-// @Description Returns all timer sessions for a specific tag within a date range
+// @Description Returns all timer sessions for a specific tag, and the tags nested under it, within a date range. Escape the separator in nested tags as %2F.
 // @Tags stats
-// @Produce html
+// @Produce html,json
 // @Param tag path string true "Tag name"
-// @Param start query string false "Start datetime (format: 2006-01-02T15:04)"
-// @Param end query string false "End datetime (format: 2006-01-02T15:04)"
-// @Success 200 {string} string "HTML component with tag sessions"
+// @Param start query string false "Start datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param end query string false "End datetime (format: 2006-01-02T15:04 or RFC 3339)"
+// @Param q query string false "Only sessions whose note contains this text, ignoring case"
+// @Success 200 {object} TagSessionsResponse "HTML component with tag sessions, or JSON"
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/stats/tag/{tag}/sessions [get]
 func (s *Server) tagSessionsHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-
-	// Try to get user from session
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err != nil || gothUser == nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-
+	user := currentUser(c)
 	tag := c.Param("tag")
-	if tag == "" {
-		c.AbortWithStatus(http.StatusBadRequest)
+
+	startDate, endDate, err := parseStatsQueryParams(c, s.clock.Now())
+	if err != nil {
+		s.respondError(c, err)
 		return
 	}
 
-	startDate, endDate, err := parseStatsQueryParams(c)
+	sessions, err := s.db.GetTagSessions(ctx, user.ID, tag, startDate, endDate)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		s.respondError(c, err)
 		return
 	}
+	query := strings.TrimSpace(c.Query("q"))
+	sessions = filterByNote(sessions, query)
 
-	sessions, err := s.db.GetTagSessions(ctx, gothUser.UserID, tag, startDate, endDate)
-	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if wantsJSON(c) {
+		response := TagSessionsResponse{Tag: tag, Sessions: make([]models.TimerSession, 0, len(sessions))}
+		for _, session := range sessions {
+			response.Sessions = append(response.Sessions, *session)
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
-	component := templates.TagSessions(tag, sessions)
+	component := templates.TagSessions(tag, sessions, query)
 	if err = component.Render(ctx, c.Writer); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+		slog.ErrorContext(c.Request.Context(), "Error rendering tag sessions", "err", err)
 	}
 }
 
+// filterByNote keeps the sessions whose note contains query, ignoring case.
+// An empty query keeps every session.
+func filterByNote(sessions []*models.TimerSession, query string) []*models.TimerSession {
+	if query == "" {
+		return sessions
+	}
+	query = strings.ToLower(query)
+	matching := sessions[:0]
+	for _, session := range sessions {
+		if strings.Contains(strings.ToLower(session.Note), query) {
+			matching = append(matching, session)
+		}
+	}
+	return matching
+}
+
 // deleteTagHandler godoc
 // @Summary Delete a tag and all its sessions
-// @Description Deletes all timer sessions and statistics for a specific tag
+// @Description Deletes all timer sessions and statistics for a specific tag. Tags nested under it are kept.
 // @Tags stats
 // @Param tag path string true "Tag name to delete"
 // @Success 200 {string} string "Empty response on successful deletion"
-// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/stats/tag/{tag} [delete]
 func (s *Server) deleteTagHandler(c *gin.Context) {
 	ctx := c.Request.Context()
-
-	gothUser, err := s.auth.GetUserFromSession(c.Request)
-	if err != nil || gothUser == nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-
+	user := currentUser(c)
 	tag := c.Param("tag")
-	if tag == "" {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
 
-	if err = s.db.DeleteUserTagStats(ctx, gothUser.UserID, tag); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := s.db.DeleteUserTagStats(ctx, user.ID, tag); err != nil {
+		s.respondError(c, err)
 		return
 	}
 
-	if err = s.db.DeleteTimerSession(ctx, gothUser.UserID, tag); err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := s.db.DeleteTimerSession(ctx, user.ID, tag); err != nil {
+		s.respondError(c, err)
 		return
 	}
+	s.publish(ctx, user.ID, models.EventTagDeleted, webhook.TagData{Tag: tag})
 
-	// Return empty response - HTMX will remove the deleted row
+	// Deleting a nested tag changes its ancestors' totals, so have HTMX
+	// reload the whole summary rather than just remove the row
+	c.Header("HX-Trigger", "statsChanged")
 	c.Status(http.StatusOK)
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+func TestParseStatsQueryParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("loading location: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   error
+	}{
+		{
+			name:      "defaults to today just before midnight",
+			now:       time.Date(2026, 3, 4, 23, 59, 59, 0, newYork),
+			wantStart: time.Date(2026, 3, 4, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 3, 4, 23, 59, 59, 999999999, newYork),
+		},
+		{
+			name:      "defaults to the new day exactly at midnight",
+			now:       time.Date(2026, 3, 5, 0, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 3, 5, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 3, 5, 23, 59, 59, 999999999, newYork),
+		},
+		{
+			name:      "spring forward day is 23 hours long",
+			now:       time.Date(2026, 3, 8, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 3, 8, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 3, 8, 0, 0, 0, 0, newYork).Add(23*time.Hour - time.Nanosecond),
+		},
+		{
+			name:      "fall back day is 25 hours long",
+			now:       time.Date(2026, 11, 1, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 11, 1, 0, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 11, 1, 0, 0, 0, 0, newYork).Add(25*time.Hour - time.Nanosecond),
+		},
+		{
+			name:      "explicit range is read in the clock's location",
+			query:     "start=2026-03-01T08:00&end=2026-03-01T17:30",
+			now:       time.Date(2026, 3, 4, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 3, 1, 8, 0, 0, 0, newYork),
+			wantEnd:   time.Date(2026, 3, 1, 17, 30, 0, 0, newYork),
+		},
+		{
+			name:      "RFC 3339 range keeps its own offset",
+			query:     "start=2026-03-02T00:00:00%2B01:00&end=2026-03-04T12:00:00Z",
+			now:       time.Date(2026, 3, 4, 12, 0, 0, 0, newYork),
+			wantStart: time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "malformed start",
+			query:   "start=yesterday&end=2026-03-01T17:30",
+			now:     time.Date(2026, 3, 4, 12, 0, 0, 0, newYork),
+			wantErr: models.ErrValidation,
+		},
+		{
+			name:    "missing end",
+			query:   "start=2026-03-01T08:00",
+			now:     time.Date(2026, 3, 4, 12, 0, 0, 0, newYork),
+			wantErr: models.ErrValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/stats/summary?"+tt.query, nil)
+
+			start, end, err := parseStatsQueryParams(c, tt.now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("start = %v, want %v", start, tt.wantStart)
+			}
+			if !end.Equal(tt.wantEnd) {
+				t.Errorf("end = %v, want %v", end, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/web/templates"
+)
+
+// webhookLogLength is how many deliveries the delivery log shows
+const webhookLogLength = 50
+
+var errNoWebhook = fmt.Errorf("%w: no webhook with this ID", models.ErrNotFound)
+
+func (s *Server) webhooksPageHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	csrfToken := s.auth.CSRFToken(currentSession(c))
+
+	webhooks, err := s.db.FindWebhooks(ctx, currentUser(c).ID)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	component := templates.WebhooksPage(webhooks, csrfToken)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering webhooks page", "err", err)
+	}
+}
+
+// listWebhooksHandler godoc
+// @Summary List webhooks
+// @Description Returns the authenticated user's webhooks, oldest first. Secrets are not included.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [get]
+func (s *Server) listWebhooksHandler(c *gin.Context) {
+	webhooks, err := s.db.FindWebhooks(c.Request.Context(), currentUser(c).ID)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []*models.Webhook{}
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// createWebhookHandler godoc
+// @Summary Register a webhook
+// @Description Registers a URL to be sent the authenticated user's timer.started, timer.stopped, timer.completed and tag.deleted events as signed JSON. The signing secret is only returned here.
+// @Tags webhooks
+// @Accept x-www-form-urlencoded
+// @Produce html,json
+// @Param url formData string true "http or https URL to POST events to"
+// @Param events formData []string true "Events to send; repeat for several" collectionFormat(multi)
+// @Success 201 {object} WebhookCreatedResponse "JSON, or the HTML webhook list showing the secret"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks [post]
+func (s *Server) createWebhookHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	webhook, err := s.webhooks.Register(ctx, user.ID, strings.TrimSpace(c.PostForm("url")), c.PostFormArray("events"))
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		c.JSON(http.StatusCreated, WebhookCreatedResponse{Webhook: webhook, Secret: webhook.Secret})
+		return
+	}
+
+	webhooks, err := s.db.FindWebhooks(ctx, user.ID)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+	c.Status(http.StatusCreated)
+	component := templates.WebhookList(webhooks, webhook)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering webhooks", "err", err)
+	}
+}
+
+// deleteWebhookHandler godoc
+// @Summary Delete a webhook
+// @Description Deletes one of the authenticated user's webhooks together with its delivery log and any deliveries not yet sent
+// @Tags webhooks
+// @Param id path string true "Webhook ID"
+// @Success 204 "Webhook deleted (JSON)"
+// @Success 200 {string} string "Empty response for HTMX to remove the webhook"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [delete]
+func (s *Server) deleteWebhookHandler(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		s.respondError(c, errNoWebhook)
+		return
+	}
+	if err = s.db.DeleteWebhook(c.Request.Context(), currentUser(c).ID, id); err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	// HTMX does not swap 204 responses
+	c.Status(http.StatusOK)
+}
+
+// webhookDeliveriesHandler godoc
+// @Summary Webhook delivery log
+// @Description Returns the latest deliveries to one of the authenticated user's webhooks, newest first, with their status, attempts and the receiver's last answer. Deliveries are kept for 30 days.
+// @Tags webhooks
+// @Produce html,json
+// @Param id path string true "Webhook ID"
+// @Success 200 {array} models.WebhookDelivery "JSON, or an HTML table"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (s *Server) webhookDeliveriesHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := currentUser(c)
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		s.respondError(c, errNoWebhook)
+		return
+	}
+	if _, err = s.db.FindWebhook(ctx, user.ID, id); err != nil {
+		s.respondError(c, err)
+		return
+	}
+	deliveries, err := s.db.FindWebhookDeliveries(ctx, user.ID, id, webhookLogLength)
+	if err != nil {
+		s.respondError(c, err)
+		return
+	}
+
+	if wantsJSON(c) {
+		if deliveries == nil {
+			deliveries = []*models.WebhookDelivery{}
+		}
+		c.JSON(http.StatusOK, deliveries)
+		return
+	}
+
+	component := templates.DeliveryLog(deliveries)
+	if err = component.Render(ctx, c.Writer); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Error rendering webhook deliveries", "err", err)
+	}
+}
+
+// publish queues a webhook event, logging failures; the change that caused
+// it is not undone because it could not be queued
+func (s *Server) publish(ctx context.Context, userID, event string, data any) {
+	if err := s.webhooks.Publish(ctx, userID, event, data); err != nil {
+		slog.ErrorContext(ctx, "Error queueing webhook deliveries", "user_id", userID, "event", event, "err", err)
+	}
+}
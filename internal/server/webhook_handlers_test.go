@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/webhook"
+)
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	var s *Server
+	h := newTestHarness(t, func(server *Server) { s = server })
+	h.login()
+
+	var mu sync.Mutex
+	var secret string
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), body, h.clock.Now(), 5*time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var payload struct {
+			Event string `json:"event"`
+			Data  struct {
+				Tag    string `json:"tag"`
+				Reason string `json:"reason"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, strings.TrimSpace(payload.Event+" "+payload.Data.Tag+" "+payload.Data.Reason))
+	}))
+	t.Cleanup(receiver.Close)
+
+	form := url.Values{"url": {receiver.URL}, "events": models.WebhookEvents}
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/webhooks", form, withoutCSRF, asJSON), http.StatusForbidden)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/webhooks", url.Values{"url": {"ftp://example.com"}, "events": models.WebhookEvents}, asJSON), http.StatusBadRequest)
+	rec := h.do(http.MethodPost, "/api/v1/webhooks", form, asJSON)
+	assertStatus(t, rec, http.StatusCreated)
+	var created WebhookCreatedResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.Webhook == nil || created.Secret == "" {
+		t.Fatalf("created = %s, %v; want the webhook with its secret", rec.Body.String(), err)
+	}
+	mu.Lock()
+	secret = created.Secret
+	mu.Unlock()
+
+	rec = h.do(http.MethodGet, "/api/v1/webhooks", nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, created.ID.Hex())
+	if strings.Contains(rec.Body.String(), created.Secret) {
+		t.Errorf("webhook list leaks the secret: %s", rec.Body.String())
+	}
+	rec = h.do(http.MethodGet, "/webhooks", nil)
+	assertStatus(t, rec, http.StatusOK)
+	assertContains(t, rec, "Add webhook", receiver.URL)
+
+	// Switching timers, a countdown the sweep stops, resetting and deleting
+	// a tag are all sent
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", tagForm("coding")), http.StatusOK)
+	countdown := tagForm("reading")
+	countdown.Set("countdown", "5")
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/start", countdown), http.StatusOK)
+	h.clock.Advance(10 * time.Minute)
+	s.sweepTimers(ctx)
+	assertStatus(t, h.do(http.MethodPost, "/api/v1/timer/reset", tagForm("reading")), http.StatusOK)
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/stats/tag/coding", nil), http.StatusOK)
+	s.deliverWebhooks(ctx)
+
+	want := []string{
+		"timer.started coding",
+		"timer.stopped coding switch",
+		"timer.started reading",
+		"timer.stopped reading countdown",
+		"timer.completed reading",
+		"tag.deleted coding",
+	}
+	mu.Lock()
+	got := strings.Join(received, "|")
+	mu.Unlock()
+	if got != strings.Join(want, "|") {
+		t.Fatalf("received %q, want %q", got, want)
+	}
+
+	deliveriesPath := "/api/v1/webhooks/" + created.ID.Hex() + "/deliveries"
+	rec = h.do(http.MethodGet, deliveriesPath, nil, asJSON)
+	assertStatus(t, rec, http.StatusOK)
+	var deliveries []models.WebhookDelivery
+	if err := json.Unmarshal(rec.Body.Bytes(), &deliveries); err != nil || len(deliveries) != len(want) {
+		t.Fatalf("deliveries = %s, %v; want %d", rec.Body.String(), err, len(want))
+	}
+	if d := deliveries[0]; d.Event != models.EventTagDeleted || d.Status != models.DeliveryDelivered || d.Attempts != 1 || d.ResponseStatus != http.StatusOK {
+		t.Errorf("newest delivery = %+v, want tag.deleted delivered on the first attempt", d)
+	}
+	assertContains(t, h.do(http.MethodGet, deliveriesPath, nil), "timer.completed", "delivered")
+
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/webhooks/"+created.ID.Hex(), nil, asJSON), http.StatusNoContent)
+	assertStatus(t, h.do(http.MethodDelete, "/api/v1/webhooks/"+created.ID.Hex(), nil, asJSON), http.StatusNotFound)
+	assertStatus(t, h.do(http.MethodGet, deliveriesPath, nil, asJSON), http.StatusNotFound)
+	assertStatus(t, h.do(http.MethodGet, "/api/v1/webhooks/not-an-id/deliveries", nil, asJSON), http.StatusNotFound)
+}
@@ -0,0 +1,510 @@
+package timer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+)
+
+var (
+	ErrTagRequired    = fmt.Errorf("%w: tag is required", models.ErrValidation)
+	ErrNoRunningTimer = fmt.Errorf("%w: no running timer for this tag", models.ErrNotFound)
+	ErrNoStoppedTimer = fmt.Errorf("%w: no stopped timer for this tag", models.ErrNotFound)
+	ErrNoActiveTimer  = fmt.Errorf("%w: no active timer", models.ErrNotFound)
+	ErrNoTimerForTag  = fmt.Errorf("%w: no running or stopped timer for this tag", models.ErrNotFound)
+	ErrNoteTooLong    = fmt.Errorf("%w: note must be at most %d characters", models.ErrValidation, models.MaxNoteLength)
+	ErrInvalidTag     = fmt.Errorf("%w: tag levels separated by %q must not be empty", models.ErrValidation, models.TagSeparator)
+
+	ErrInvalidCountdown = fmt.Errorf("%w: countdown must be between 1 second and %d hours", models.ErrValidation, int(models.MaxCountdown/time.Hour))
+	ErrCountdownPassed  = fmt.Errorf("%w: countdown must be longer than the time already tracked", models.ErrValidation)
+
+	ErrInvalidColor       = fmt.Errorf("%w: color must be a hex color such as #4caf50", models.ErrValidation)
+	ErrIconTooLong        = fmt.Errorf("%w: icon must be at most %d characters", models.ErrValidation, models.MaxTagIconLength)
+	ErrDescriptionTooLong = fmt.Errorf("%w: description must be at most %d characters", models.ErrValidation, models.MaxTagDescriptionLength)
+	ErrNegativeRate       = fmt.Errorf("%w: hourly rate must not be negative", models.ErrValidation)
+)
+
+// State is a timer session together with its elapsed time at a given instant
+type State struct {
+	Session *models.TimerSession
+	Elapsed int64 // Seconds, including the interval currently running
+}
+
+// Running reports whether the timer is counting
+func (st *State) Running() bool {
+	return st.Session.Status == models.StatusRunning
+}
+
+// Service holds the timer business rules. Every operation takes the user it
+// acts for and the current time, so callers decide where "now" comes from.
+// Start and Stop replace the session's note with note unless it is empty.
+type Service interface {
+	Start(ctx context.Context, userID, tag, note string, countdown time.Duration, now time.Time) (*State, error)
+	Stop(ctx context.Context, userID, tag, note string, now time.Time) (*State, error)
+	Annotate(ctx context.Context, userID, tag, note string, now time.Time) (*State, error)
+	Reset(ctx context.Context, userID, tag string, now time.Time) (*State, error)
+	Current(ctx context.Context, userID string, now time.Time) (*State, error)
+	Active(ctx context.Context, userID string, now time.Time) ([]*State, error)
+	ExpireCountdowns(ctx context.Context, now time.Time) ([]*State, error)
+	FlagLongRunning(ctx context.Context, limit time.Duration, now time.Time) ([]*State, error)
+	Tags(ctx context.Context, userID string) ([]string, error)
+	UpdateTag(ctx context.Context, userID, tag string, settings models.TagSettings) (*models.UserTagStats, error)
+}
+
+// Why a timer stopped
+const (
+	StoppedByUser      = "user"      // Stop was called
+	StoppedBySwitch    = "switch"    // Another timer was started
+	StoppedByCountdown = "countdown" // Its countdown reached zero
+)
+
+// Event is a timer starting (models.EventTimerStarted), stopping
+// (models.EventTimerStopped) or completing (models.EventTimerCompleted)
+type Event struct {
+	Name  string
+	State *State
+	// Reason is why a timer stopped; empty for other events
+	Reason string
+}
+
+// Listener is told about every timer event once it is stored, however it
+// came about. Listeners cannot fail the change, so they report no error.
+type Listener interface {
+	TimerChanged(ctx context.Context, event Event)
+}
+
+type service struct {
+	db       database.Service
+	listener Listener
+}
+
+// New returns a Service that stores timers in db and tells listener, which
+// may be nil, about their events
+func New(db database.Service, listener Listener) Service {
+	return &service{db: db, listener: listener}
+}
+
+func (s *service) emit(ctx context.Context, name string, state *State, reason string) {
+	if s.listener != nil {
+		s.listener.TimerChanged(ctx, Event{Name: name, State: state, Reason: reason})
+	}
+}
+
+// Start resumes the user's stopped session for tag, or begins a new one.
+// Running sessions left behind for the tag (e.g., by a closed tab) are
+// abandoned first. Unless the user has opted in to concurrent timers, the
+// timers running for other tags are stopped.
+//
+// A positive countdown makes the timer stop by itself once the session has
+// run that long. Without one, a resumed session keeps its countdown, unless
+// that has finished and the session now counts up.
+func (s *service) Start(ctx context.Context, userID, tag, note string, countdown time.Duration, now time.Time) (*State, error) {
+	tag, err := cleanTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	note, err = cleanNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.AbandonRunningTimers(ctx, userID, tag); err != nil {
+		return nil, err
+	}
+
+	timerSession, err := s.db.FindTimerSession(ctx, userID, tag, models.StatusStopped)
+	resuming := err == nil
+	if errors.Is(err, models.ErrNotFound) {
+		timerSession = models.NewTimerSession(userID, tag, now)
+		timerSession.Note = note
+	} else if err != nil {
+		return nil, err
+	}
+	if err = setCountdown(timerSession, countdown, now); err != nil {
+		return nil, err
+	}
+
+	if err = s.stopOtherTimers(ctx, userID, tag, now); err != nil {
+		return nil, err
+	}
+
+	if !resuming {
+		if err = s.db.CreateTimerSession(ctx, timerSession); err != nil {
+			return nil, err
+		}
+		if err = s.countSession(ctx, userID, tag, now); err != nil {
+			return nil, err
+		}
+	} else {
+		timerSession.Status = models.StatusRunning
+		timerSession.LastUpdated = now
+		if note != "" {
+			timerSession.Note = note
+		}
+		if err = s.db.UpdateTimerSession(ctx, timerSession); err != nil {
+			return nil, err
+		}
+	}
+
+	state := &State{Session: timerSession, Elapsed: timerSession.Duration}
+	s.emit(ctx, models.EventTimerStarted, state, "")
+	return state, nil
+}
+
+// setCountdown sets the countdown of a session about to run and when it is
+// due to reach zero
+func setCountdown(timerSession *models.TimerSession, countdown time.Duration, now time.Time) error {
+	seconds := int64(countdown / time.Second)
+	switch {
+	case countdown < 0 || countdown > models.MaxCountdown || (countdown > 0 && seconds == 0):
+		return ErrInvalidCountdown
+	case seconds > 0 && seconds <= timerSession.Duration:
+		return ErrCountdownPassed
+	case seconds > 0:
+		timerSession.Countdown = seconds
+	case timerSession.CountdownFinished():
+		timerSession.Countdown = 0
+	}
+
+	timerSession.DueAt = nil
+	if timerSession.Countdown > 0 {
+		dueAt := now.Add(time.Duration(timerSession.Countdown-timerSession.Duration) * time.Second)
+		timerSession.DueAt = &dueAt
+	}
+	return nil
+}
+
+// countSession records a new session in the user's stats for tag
+func (s *service) countSession(ctx context.Context, userID, tag string, now time.Time) error {
+	return s.db.AddToUserTagStats(ctx, userID, tag, 0, 1, now)
+}
+
+// Stop pauses the running session for tag and adds the time since it was
+// last started to both the session and the tag's stats
+func (s *service) Stop(ctx context.Context, userID, tag, note string, now time.Time) (*State, error) {
+	tag, err := cleanTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	note, err = cleanNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	timerSession, err := s.db.FindTimerSession(ctx, userID, tag, models.StatusRunning)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrNoRunningTimer
+	} else if err != nil {
+		return nil, err
+	}
+
+	if note != "" {
+		timerSession.Note = note
+	}
+	err = s.pause(ctx, timerSession, now, StoppedByUser)
+	if errors.Is(err, models.ErrConflict) {
+		return nil, ErrNoRunningTimer
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &State{Session: timerSession, Elapsed: timerSession.Duration}, nil
+}
+
+// pause stops a running session, recording the interval since it was last
+// started and adding it to the tag's stats. A countdown stops when it reached
+// zero, however much later this runs. reason is passed on to the listener.
+//
+// When another request stopped or restarted the session since it was read,
+// pause returns ErrConflict and leaves the stats and listener alone.
+func (s *service) pause(ctx context.Context, timerSession *models.TimerSession, now time.Time, reason string) error {
+	runningSince := timerSession.LastUpdated
+	if timerSession.DueAt != nil && timerSession.DueAt.Before(now) {
+		now = *timerSession.DueAt
+	}
+	timerSession.DueAt = nil
+	timerSession.AlertedAt = nil
+
+	elapsedTime := elapsedSince(timerSession.LastUpdated, now)
+	if elapsedTime > 0 {
+		// RanDuring keeps the time of sessions started before intervals were
+		// recorded
+		timerSession.Intervals = append(timerSession.RanDuring(), models.Interval{Start: timerSession.LastUpdated, End: now})
+	}
+	timerSession.Duration += elapsedTime
+	timerSession.Status = models.StatusStopped
+	timerSession.LastUpdated = now
+	if err := s.db.PauseTimerSession(ctx, timerSession, runningSince); err != nil {
+		return err
+	}
+
+	if err := s.db.AddToUserTagStats(ctx, timerSession.UserID, timerSession.Tag, elapsedTime, 0, now); err != nil {
+		return err
+	}
+
+	s.emit(ctx, models.EventTimerStopped, &State{Session: timerSession, Elapsed: timerSession.Duration}, reason)
+	return nil
+}
+
+// stopOtherTimers stops the user's timers running for tags other than tag,
+// unless the user has opted in to concurrent timers
+func (s *service) stopOtherTimers(ctx context.Context, userID, tag string, now time.Time) error {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user != nil && user.ConcurrentTimers {
+		return nil
+	}
+
+	timerSessions, err := s.db.FindActiveTimerSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, timerSession := range timerSessions {
+		if timerSession.Tag == tag || timerSession.Status != models.StatusRunning {
+			continue
+		}
+		// A timer stopped meanwhile needs no stopping
+		if err = s.pause(ctx, timerSession, now, StoppedBySwitch); err != nil && !errors.Is(err, models.ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Annotate replaces the note of the running or stopped session for tag; an
+// empty note removes it. The session's timing is left alone.
+func (s *service) Annotate(ctx context.Context, userID, tag, note string, now time.Time) (*State, error) {
+	tag, err := cleanTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	note, err = cleanNote(note)
+	if err != nil {
+		return nil, err
+	}
+
+	timerSession, err := s.db.FindTimerSession(ctx, userID, tag, models.StatusRunning)
+	if errors.Is(err, models.ErrNotFound) {
+		timerSession, err = s.db.FindTimerSession(ctx, userID, tag, models.StatusStopped)
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrNoTimerForTag
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Set only the note: a full write would undo a pause or stop that
+	// landed since the session was read
+	err = s.db.UpdateTimerSessionNote(ctx, timerSession.ID, note)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrNoTimerForTag
+	} else if err != nil {
+		return nil, err
+	}
+	timerSession.Note = note
+	return newState(timerSession, now), nil
+}
+
+// cleanTag normalises the spacing of a nested tag so "client-a / backend"
+// and "client-a/backend" name the same timer
+func cleanTag(tag string) (string, error) {
+	if tag == "" {
+		return "", ErrTagRequired
+	}
+	tag, ok := models.CleanTag(tag)
+	if !ok {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// cleanNote trims surrounding whitespace and enforces the length limit
+func cleanNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > models.MaxNoteLength {
+		return "", ErrNoteTooLong
+	}
+	return note, nil
+}
+
+// Reset completes the stopped session for tag so it counts towards stats
+func (s *service) Reset(ctx context.Context, userID, tag string, now time.Time) (*State, error) {
+	tag, err := cleanTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	timerSession, err := s.db.FindTimerSession(ctx, userID, tag, models.StatusStopped)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrNoStoppedTimer
+	} else if err != nil {
+		return nil, err
+	}
+
+	timerSession.Status = models.StatusCompleted
+	timerSession.LastUpdated = now
+	timerSession.EndTime = &now
+	if err = s.db.UpdateTimerSession(ctx, timerSession); err != nil {
+		return nil, err
+	}
+
+	state := &State{Session: timerSession, Elapsed: timerSession.Duration}
+	s.emit(ctx, models.EventTimerCompleted, state, "")
+	return state, nil
+}
+
+// Current returns the user's running or stopped timer, if any
+func (s *service) Current(ctx context.Context, userID string, now time.Time) (*State, error) {
+	timerSession, err := s.db.FindActiveTimerSession(ctx, userID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, ErrNoActiveTimer
+	} else if err != nil {
+		return nil, err
+	}
+	if err = s.expire(ctx, timerSession, now); err != nil {
+		return nil, err
+	}
+
+	return newState(timerSession, now), nil
+}
+
+// Active returns the user's running and stopped timers in the order they
+// were started
+func (s *service) Active(ctx context.Context, userID string, now time.Time) ([]*State, error) {
+	timerSessions, err := s.db.FindActiveTimerSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*State, 0, len(timerSessions))
+	for _, timerSession := range timerSessions {
+		if err = s.expire(ctx, timerSession, now); err != nil {
+			return nil, err
+		}
+		states = append(states, newState(timerSession, now))
+	}
+	return states, nil
+}
+
+// ExpireCountdowns stops every user's running countdowns that reached zero
+// by now, e.g. after their page was closed, and returns them. Countdowns
+// another server or request stopped first are left out.
+func (s *service) ExpireCountdowns(ctx context.Context, now time.Time) ([]*State, error) {
+	timerSessions, err := s.db.FindDueTimerSessions(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]*State, 0, len(timerSessions))
+	for _, timerSession := range timerSessions {
+		err = s.pause(ctx, timerSession, now, StoppedByCountdown)
+		if errors.Is(err, models.ErrConflict) {
+			continue
+		} else if err != nil {
+			return states, err
+		}
+		states = append(states, newState(timerSession, now))
+	}
+	return states, nil
+}
+
+// FlagLongRunning returns every user's timers that have run for at least
+// limit without a break, e.g. because they were forgotten. Each run is
+// returned once; a timer that is stopped and started again can be flagged
+// again.
+func (s *service) FlagLongRunning(ctx context.Context, limit time.Duration, now time.Time) ([]*State, error) {
+	var states []*State
+	for {
+		timerSession, err := s.db.ClaimLongRunningTimerSession(ctx, now.Add(-limit), now)
+		if errors.Is(err, models.ErrNotFound) {
+			return states, nil
+		} else if err != nil {
+			return states, err
+		}
+		states = append(states, newState(timerSession, now))
+	}
+}
+
+// expire stops a running countdown that reached zero before now, so callers
+// never see it counting past zero. If another request stopped it first,
+// timerSession is still returned stopped.
+func (s *service) expire(ctx context.Context, timerSession *models.TimerSession, now time.Time) error {
+	if timerSession.Status != models.StatusRunning || timerSession.DueAt == nil || timerSession.DueAt.After(now) {
+		return nil
+	}
+	if err := s.pause(ctx, timerSession, now, StoppedByCountdown); err != nil && !errors.Is(err, models.ErrConflict) {
+		return err
+	}
+	return nil
+}
+
+// newState counts the interval in progress when the session is running
+func newState(timerSession *models.TimerSession, now time.Time) *State {
+	elapsed := timerSession.Duration
+	if timerSession.Status == models.StatusRunning {
+		elapsed += elapsedSince(timerSession.LastUpdated, now)
+	}
+	return &State{Session: timerSession, Elapsed: elapsed}
+}
+
+// Tags returns every tag the user has tracked time against, except archived
+// ones
+func (s *service) Tags(ctx context.Context, userID string) ([]string, error) {
+	allUserTagStats, err := s.db.FindAllUserTagStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(allUserTagStats))
+	for _, tagStats := range allUserTagStats {
+		if !tagStats.Archived {
+			tags = append(tags, tagStats.Tag)
+		}
+	}
+	return tags, nil
+}
+
+// UpdateTag replaces the settings of one of the user's tags
+func (s *service) UpdateTag(ctx context.Context, userID, tag string, settings models.TagSettings) (*models.UserTagStats, error) {
+	settings, err := cleanTagSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.UpdateTagSettings(ctx, userID, tag, settings)
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+func cleanTagSettings(settings models.TagSettings) (models.TagSettings, error) {
+	settings.Color = strings.ToLower(strings.TrimSpace(settings.Color))
+	settings.Icon = strings.TrimSpace(settings.Icon)
+	settings.Description = strings.TrimSpace(settings.Description)
+	switch {
+	case settings.Color != "" && !hexColor.MatchString(settings.Color):
+		return settings, ErrInvalidColor
+	case utf8.RuneCountInString(settings.Icon) > models.MaxTagIconLength:
+		return settings, ErrIconTooLong
+	case utf8.RuneCountInString(settings.Description) > models.MaxTagDescriptionLength:
+		return settings, ErrDescriptionTooLong
+	case settings.HourlyRateCents < 0:
+		return settings, ErrNegativeRate
+	}
+	return settings, nil
+}
+
+// elapsedSince returns the whole seconds between from and now, never negative
+func elapsedSince(from, now time.Time) int64 {
+	if now.Before(from) {
+		return 0
+	}
+	return int64(now.Sub(from).Seconds())
+}
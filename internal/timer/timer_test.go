@@ -0,0 +1,661 @@
+package timer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+)
+
+const (
+	userID = "user-1"
+	tag    = "coding"
+)
+
+type op int
+
+const (
+	start op = iota
+	stop
+	reset
+)
+
+func (o op) String() string {
+	return [...]string{"start", "stop", "reset"}[o]
+}
+
+// step advances the clock, then applies op and checks the resulting state
+type step struct {
+	advance     time.Duration
+	op          op
+	wantElapsed int64
+	wantStatus  models.TimerStatus
+}
+
+func newService(now time.Time) (timer.Service, database.Service, *clock.Fake) {
+	clk := clock.NewFake(now)
+	db := database.NewMemory(clk)
+	return timer.New(db, nil), db, clk
+}
+
+func apply(ctx context.Context, svc timer.Service, o op, now time.Time) (*timer.State, error) {
+	switch o {
+	case start:
+		return svc.Start(ctx, userID, tag, "", 0, now)
+	case stop:
+		return svc.Stop(ctx, userID, tag, "", now)
+	default:
+		return svc.Reset(ctx, userID, tag, now)
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("loading location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestDurations(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name          string
+		startAt       time.Time
+		steps         []step
+		wantTagTotal  int64
+		wantTagCount  int
+		wantCompleted int
+	}{
+		{
+			name:    "start then stop",
+			startAt: time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{25 * time.Minute, stop, 1500, models.StatusStopped},
+			},
+			wantTagTotal: 1500,
+			wantTagCount: 1,
+		},
+		{
+			name:    "continue accumulates without counting the pause",
+			startAt: time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{10 * time.Minute, stop, 600, models.StatusStopped},
+				{time.Hour, start, 600, models.StatusRunning},
+				{20 * time.Minute, stop, 1800, models.StatusStopped},
+			},
+			wantTagTotal: 1800,
+			wantTagCount: 1,
+		},
+		{
+			name:    "reset completes the session and the next start is a new one",
+			startAt: time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{time.Hour, stop, 3600, models.StatusStopped},
+				{time.Minute, reset, 3600, models.StatusCompleted},
+				{time.Minute, start, 0, models.StatusRunning},
+				{30 * time.Minute, stop, 1800, models.StatusStopped},
+			},
+			wantTagTotal:  5400,
+			wantTagCount:  2,
+			wantCompleted: 1,
+		},
+		{
+			name:    "partial seconds are truncated",
+			startAt: time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{1500 * time.Millisecond, stop, 1, models.StatusStopped},
+			},
+			wantTagTotal: 1,
+			wantTagCount: 1,
+		},
+		{
+			name:    "session spanning midnight",
+			startAt: time.Date(2026, 3, 4, 23, 50, 0, 0, newYork),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{20 * time.Minute, stop, 1200, models.StatusStopped},
+				{0, reset, 1200, models.StatusCompleted},
+			},
+			wantTagTotal:  1200,
+			wantTagCount:  1,
+			wantCompleted: 1,
+		},
+		{
+			name:    "spring forward: 01:30 EST to 03:30 EDT is one hour",
+			startAt: time.Date(2026, 3, 8, 1, 30, 0, 0, newYork),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{time.Hour, stop, 3600, models.StatusStopped},
+			},
+			wantTagTotal: 3600,
+			wantTagCount: 1,
+		},
+		{
+			name:    "fall back: 01:30 EDT to 01:30 EST is one hour",
+			startAt: time.Date(2026, 11, 1, 1, 30, 0, 0, newYork),
+			steps: []step{
+				{0, start, 0, models.StatusRunning},
+				{time.Hour, stop, 3600, models.StatusStopped},
+			},
+			wantTagTotal: 3600,
+			wantTagCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc, db, clk := newService(tt.startAt)
+
+			for i, st := range tt.steps {
+				clk.Advance(st.advance)
+				state, err := apply(ctx, svc, st.op, clk.Now())
+				if err != nil {
+					t.Fatalf("step %d (%s): unexpected error: %v", i, st.op, err)
+				}
+				if state.Elapsed != st.wantElapsed {
+					t.Errorf("step %d (%s): elapsed = %d, want %d", i, st.op, state.Elapsed, st.wantElapsed)
+				}
+				if state.Session.Status != st.wantStatus {
+					t.Errorf("step %d (%s): status = %s, want %s", i, st.op, state.Session.Status, st.wantStatus)
+				}
+			}
+
+			tagStats, err := db.FindUserTagStats(ctx, userID, tag)
+			if err != nil {
+				t.Fatalf("FindUserTagStats: %v", err)
+			}
+			if tagStats.TotalDuration != tt.wantTagTotal {
+				t.Errorf("tag total = %d, want %d", tagStats.TotalDuration, tt.wantTagTotal)
+			}
+			if tagStats.SessionCount != tt.wantTagCount {
+				t.Errorf("tag session count = %d, want %d", tagStats.SessionCount, tt.wantTagCount)
+			}
+
+			summary, err := db.GetStatsSummary(ctx, userID, "", tt.startAt.Add(-24*time.Hour), clk.Now(), false)
+			if err != nil {
+				t.Fatalf("GetStatsSummary: %v", err)
+			}
+			if summary.TotalSessions != tt.wantCompleted {
+				t.Errorf("completed sessions = %d, want %d", summary.TotalSessions, tt.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestCurrent(t *testing.T) {
+	ctx := context.Background()
+	svc, _, clk := newService(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+
+	if _, err := svc.Current(ctx, userID, clk.Now()); !errors.Is(err, timer.ErrNoActiveTimer) {
+		t.Fatalf("Current with no timer: err = %v, want ErrNoActiveTimer", err)
+	}
+
+	if _, err := svc.Start(ctx, userID, tag, "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(90 * time.Second)
+
+	state, err := svc.Current(ctx, userID, clk.Now())
+	if err != nil {
+		t.Fatalf("Current while running: %v", err)
+	}
+	if !state.Running() || state.Elapsed != 90 {
+		t.Errorf("Current while running = (running %v, elapsed %d), want (true, 90)", state.Running(), state.Elapsed)
+	}
+
+	if _, err = svc.Stop(ctx, userID, tag, "", clk.Now()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	clk.Advance(time.Hour)
+
+	state, err = svc.Current(ctx, userID, clk.Now())
+	if err != nil {
+		t.Fatalf("Current while stopped: %v", err)
+	}
+	if state.Running() || state.Elapsed != 90 {
+		t.Errorf("Current while stopped = (running %v, elapsed %d), want (false, 90)", state.Running(), state.Elapsed)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	ctx := context.Background()
+	svc, db, clk := newService(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+
+	if _, err := svc.Annotate(ctx, userID, tag, "nothing to annotate", clk.Now()); !errors.Is(err, timer.ErrNoTimerForTag) {
+		t.Fatalf("Annotate without timer: err = %v, want ErrNoTimerForTag", err)
+	}
+
+	state, err := svc.Start(ctx, userID, tag, "  billing migration  ", 0, clk.Now())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if state.Session.Note != "billing migration" {
+		t.Errorf("note after Start = %q, want it trimmed", state.Session.Note)
+	}
+
+	// Annotating a running timer must not restart its interval
+	clk.Advance(time.Minute)
+	state, err = svc.Annotate(ctx, userID, tag, "billing migration, batch 2", clk.Now())
+	if err != nil {
+		t.Fatalf("Annotate: %v", err)
+	}
+	if state.Elapsed != 60 {
+		t.Errorf("elapsed after Annotate = %d, want 60", state.Elapsed)
+	}
+
+	clk.Advance(time.Minute)
+	state, err = svc.Stop(ctx, userID, tag, "", clk.Now())
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if state.Elapsed != 120 || state.Session.Note != "billing migration, batch 2" {
+		t.Errorf("after Stop = (%d, %q), want the note kept", state.Elapsed, state.Session.Note)
+	}
+
+	if _, err = svc.Reset(ctx, userID, tag, clk.Now()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	sessions, err := db.GetTagSessions(ctx, userID, tag, clk.Now().Add(-time.Hour), clk.Now())
+	if err != nil || len(sessions) != 1 || sessions[0].Note != "billing migration, batch 2" {
+		t.Errorf("saved sessions = %v, %v; want the note persisted", sessions, err)
+	}
+
+	long := strings.Repeat("x", models.MaxNoteLength+1)
+	if _, err = svc.Start(ctx, userID, tag, long, 0, clk.Now()); !errors.Is(err, timer.ErrNoteTooLong) || !errors.Is(err, models.ErrValidation) {
+		t.Errorf("Start with long note: err = %v, want ErrNoteTooLong", err)
+	}
+}
+
+func TestConcurrentTimers(t *testing.T) {
+	ctx := context.Background()
+	svc, db, clk := newService(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	begin := clk.Now()
+
+	// Without the opt-in, starting a timer stops the one running
+	if _, err := svc.Start(ctx, userID, "coding", "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start coding: %v", err)
+	}
+	clk.Advance(10 * time.Minute)
+	if _, err := svc.Start(ctx, userID, "reading", "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start reading: %v", err)
+	}
+	states, err := svc.Active(ctx, userID, clk.Now())
+	if err != nil || len(states) != 2 || states[0].Running() || states[0].Elapsed != 600 || !states[1].Running() {
+		t.Fatalf("Active = %v, %v; want coding stopped at 600s and reading running", states, err)
+	}
+
+	user, err := db.FindOrCreateUser(ctx, &models.User{Provider: "google", ProviderID: "on-call"})
+	if err != nil {
+		t.Fatalf("FindOrCreateUser: %v", err)
+	}
+	if err = db.SetConcurrentTimers(ctx, user.ID, true); err != nil {
+		t.Fatalf("SetConcurrentTimers: %v", err)
+	}
+
+	// on-call runs 9:00-10:00 while coding runs 9:30-10:30
+	clk.Set(begin)
+	if _, err = svc.Start(ctx, user.ID, "on-call", "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start on-call: %v", err)
+	}
+	clk.Advance(30 * time.Minute)
+	if _, err = svc.Start(ctx, user.ID, "coding", "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start coding: %v", err)
+	}
+	if states, err = svc.Active(ctx, user.ID, clk.Now()); err != nil || len(states) != 2 || !states[0].Running() || !states[1].Running() {
+		t.Fatalf("Active = %v, %v; want both timers running", states, err)
+	}
+	for _, step := range []struct {
+		advance time.Duration
+		tag     string
+	}{{30 * time.Minute, "on-call"}, {30 * time.Minute, "coding"}} {
+		clk.Advance(step.advance)
+		if _, err = svc.Stop(ctx, user.ID, step.tag, "", clk.Now()); err != nil {
+			t.Fatalf("Stop %s: %v", step.tag, err)
+		}
+		if _, err = svc.Reset(ctx, user.ID, step.tag, clk.Now()); err != nil {
+			t.Fatalf("Reset %s: %v", step.tag, err)
+		}
+	}
+
+	tracked, err := db.GetStatsSummary(ctx, user.ID, "", begin, clk.Now(), false)
+	if err != nil || tracked.TotalDuration != 7200 {
+		t.Fatalf("tracked summary = %+v, %v; want 7200s", tracked, err)
+	}
+	wallClock, err := db.GetStatsSummary(ctx, user.ID, "", begin, clk.Now(), true)
+	if err != nil {
+		t.Fatalf("wall-clock summary: %v", err)
+	}
+	if !wallClock.WallClock || wallClock.TotalDuration != 5400 || wallClock.TotalSessions != 2 {
+		t.Errorf("wall-clock summary = %+v, want 5400s over 2 sessions", wallClock)
+	}
+	for _, tagStats := range wallClock.TagBreakdown {
+		if tagStats.TotalDuration != 2700 {
+			t.Errorf("wall-clock %s = %ds, want the overlap shared (2700s)", tagStats.Tag, tagStats.TotalDuration)
+		}
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	ctx := context.Background()
+	svc, db, clk := newService(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	due := clk.Now().Add(25 * time.Minute)
+
+	state, err := svc.Start(ctx, userID, tag, "", 25*time.Minute, clk.Now())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if state.Session.Countdown != 1500 || state.Session.DueAt == nil || !state.Session.DueAt.Equal(due) {
+		t.Fatalf("countdown = %d due %v, want 1500 due %v", state.Session.Countdown, state.Session.DueAt, due)
+	}
+
+	clk.Advance(10 * time.Minute)
+	if states, err := svc.ExpireCountdowns(ctx, clk.Now()); err != nil || len(states) != 0 {
+		t.Fatalf("ExpireCountdowns before due = %v, %v; want none", states, err)
+	}
+
+	// The page was closed; the sweep finds the countdown late but stops it
+	// at the exact due time
+	clk.Advance(time.Hour)
+	states, err := svc.ExpireCountdowns(ctx, clk.Now())
+	if err != nil || len(states) != 1 {
+		t.Fatalf("ExpireCountdowns = %v, %v; want one", states, err)
+	}
+	session := states[0].Session
+	if session.Status != models.StatusStopped || session.Duration != 1500 || !session.LastUpdated.Equal(due) || !session.CountdownFinished() {
+		t.Errorf("expired session = %+v, want stopped at 1500s at %v", session, due)
+	}
+	tagStats, err := db.FindUserTagStats(ctx, userID, tag)
+	if err != nil || tagStats.TotalDuration != 1500 {
+		t.Errorf("tag stats = %+v, %v; want 1500s", tagStats, err)
+	}
+
+	// Continuing a finished countdown counts up
+	state, err = svc.Start(ctx, userID, tag, "", 0, clk.Now())
+	if err != nil || state.Session.Countdown != 0 || state.Session.DueAt != nil {
+		t.Fatalf("continue = %+v, %v; want counting up", state, err)
+	}
+	clk.Advance(time.Minute)
+	if _, err = svc.Stop(ctx, userID, tag, "", clk.Now()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err = svc.Start(ctx, userID, tag, "", 20*time.Minute, clk.Now()); !errors.Is(err, timer.ErrCountdownPassed) {
+		t.Errorf("Start with a countdown already passed: err = %v, want ErrCountdownPassed", err)
+	}
+
+	// Reading the timer stops a countdown that reached zero before the sweep
+	state, err = svc.Start(ctx, userID, tag, "", 27*time.Minute, clk.Now())
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(5 * time.Minute)
+	if state, err = svc.Current(ctx, userID, clk.Now()); err != nil || state.Running() || state.Elapsed != 1620 {
+		t.Errorf("Current after due = %+v, %v; want stopped at 1620s", state, err)
+	}
+
+	for _, countdown := range []time.Duration{-time.Minute, 500 * time.Millisecond, 25 * time.Hour} {
+		if _, err = svc.Start(ctx, userID, "reading", "", countdown, clk.Now()); !errors.Is(err, timer.ErrInvalidCountdown) {
+			t.Errorf("Start with countdown %v: err = %v, want ErrInvalidCountdown", countdown, err)
+		}
+	}
+}
+
+// counter counts the events it is told about; servers sweeping at once call
+// it concurrently
+type counter struct {
+	mu     sync.Mutex
+	events map[string]int
+}
+
+func (c *counter) TimerChanged(_ context.Context, event timer.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events[event.Name]++
+}
+
+// sweepBarrier holds every FindDueTimerSessions call until all the expected
+// sweeps have read the due sessions, so they all try to stop the same ones
+type sweepBarrier struct {
+	database.Service
+	reads sync.WaitGroup
+}
+
+func (b *sweepBarrier) FindDueTimerSessions(ctx context.Context, now time.Time) ([]*models.TimerSession, error) {
+	timerSessions, err := b.Service.FindDueTimerSessions(ctx, now)
+	b.reads.Done()
+	b.reads.Wait()
+	return timerSessions, err
+}
+
+func TestExpireCountdownsOnce(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	db := &sweepBarrier{Service: database.NewMemory(clk)}
+	events := &counter{events: map[string]int{}}
+	svc := timer.New(db, events)
+
+	if _, err := svc.Start(ctx, userID, tag, "", 25*time.Minute, clk.Now()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(time.Hour)
+
+	// Several servers sweep at once; only one of them may stop the countdown
+	const sweeps = 4
+	db.reads.Add(sweeps)
+	expired := make(chan int, sweeps)
+	var wg sync.WaitGroup
+	for range sweeps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			states, err := svc.ExpireCountdowns(ctx, clk.Now())
+			if err != nil {
+				t.Errorf("ExpireCountdowns: %v", err)
+			}
+			expired <- len(states)
+		}()
+	}
+	wg.Wait()
+	close(expired)
+
+	total := 0
+	for n := range expired {
+		total += n
+	}
+	if total != 1 {
+		t.Errorf("sweeps returned the countdown %d times, want once", total)
+	}
+	if got := events.events[models.EventTimerStopped]; got != 1 {
+		t.Errorf("%d stop events, want 1", got)
+	}
+	tagStats, err := db.FindUserTagStats(ctx, userID, tag)
+	if err != nil || tagStats.TotalDuration != 1500 {
+		t.Errorf("tag stats = %+v, %v; want 1500s counted once", tagStats, err)
+	}
+}
+
+func TestFlagLongRunning(t *testing.T) {
+	ctx := context.Background()
+	svc, _, clk := newService(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+
+	if _, err := svc.Start(ctx, userID, tag, "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(3 * time.Hour)
+	if states, err := svc.FlagLongRunning(ctx, 4*time.Hour, clk.Now()); err != nil || len(states) != 0 {
+		t.Fatalf("FlagLongRunning after 3h = %v, %v; want none", states, err)
+	}
+
+	clk.Advance(time.Hour)
+	states, err := svc.FlagLongRunning(ctx, 4*time.Hour, clk.Now())
+	if err != nil || len(states) != 1 || states[0].Session.Tag != tag || states[0].Elapsed != 4*3600 {
+		t.Fatalf("FlagLongRunning after 4h = %v, %v; want the %q timer at 4h", states, err, tag)
+	}
+	clk.Advance(time.Hour)
+	if states, err = svc.FlagLongRunning(ctx, 4*time.Hour, clk.Now()); err != nil || len(states) != 0 {
+		t.Fatalf("FlagLongRunning again = %v, %v; want each run flagged once", states, err)
+	}
+
+	// A new run after a break can be flagged again
+	if _, err = svc.Stop(ctx, userID, tag, "", clk.Now()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err = svc.Start(ctx, userID, tag, "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(4 * time.Hour)
+	if states, err = svc.FlagLongRunning(ctx, 4*time.Hour, clk.Now()); err != nil || len(states) != 1 {
+		t.Fatalf("FlagLongRunning after restarting = %v, %v; want one", states, err)
+	}
+}
+
+// recorder keeps the events it is told about as "name tag reason"
+type recorder []string
+
+func (r *recorder) TimerChanged(_ context.Context, event timer.Event) {
+	*r = append(*r, strings.TrimSpace(event.Name+" "+event.State.Session.Tag+" "+event.Reason))
+}
+
+func TestListener(t *testing.T) {
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	var events recorder
+	svc := timer.New(database.NewMemory(clk), &events)
+
+	steps := []func() (*timer.State, error){
+		func() (*timer.State, error) { return svc.Start(ctx, userID, "coding", "", 0, clk.Now()) },
+		func() (*timer.State, error) { return svc.Start(ctx, userID, "reading", "", 10*time.Minute, clk.Now()) },
+		func() (*timer.State, error) {
+			_, err := svc.Active(ctx, userID, clk.Now().Add(time.Hour))
+			return nil, err
+		},
+		func() (*timer.State, error) { return svc.Reset(ctx, userID, "reading", clk.Now()) },
+		func() (*timer.State, error) { return svc.Start(ctx, userID, "coding", "", 0, clk.Now()) },
+		func() (*timer.State, error) { return svc.Stop(ctx, userID, "coding", "", clk.Now()) },
+		func() (*timer.State, error) { return svc.Stop(ctx, userID, "coding", "", clk.Now()) },
+	}
+	for i, step := range steps {
+		if _, err := step(); err != nil && !errors.Is(err, timer.ErrNoRunningTimer) {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	// A failed Stop tells the listener nothing
+	want := []string{
+		"timer.started coding",
+		"timer.stopped coding switch",
+		"timer.started reading",
+		"timer.stopped reading countdown",
+		"timer.completed reading",
+		"timer.started coding",
+		"timer.stopped coding user",
+	}
+	if strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestMidnightBoundary(t *testing.T) {
+	ctx := context.Background()
+	newYork := mustLoadLocation(t, "America/New_York")
+	svc, db, clk := newService(time.Date(2026, 3, 4, 23, 50, 0, 0, newYork))
+
+	if _, err := svc.Start(ctx, userID, tag, "", 0, clk.Now()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clk.Advance(20 * time.Minute)
+	if _, err := svc.Stop(ctx, userID, tag, "", clk.Now()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := svc.Reset(ctx, userID, tag, clk.Now()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+
+	// Sessions belong to the day they started on
+	days := []struct {
+		name         string
+		day          time.Time
+		wantSessions int
+		wantDuration int64
+	}{
+		{"start day", time.Date(2026, 3, 4, 0, 0, 0, 0, newYork), 1, 1200},
+		{"next day", time.Date(2026, 3, 5, 0, 0, 0, 0, newYork), 0, 0},
+	}
+	for _, d := range days {
+		t.Run(d.name, func(t *testing.T) {
+			end := d.day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			summary, err := db.GetStatsSummary(ctx, userID, "", d.day, end, false)
+			if err != nil {
+				t.Fatalf("GetStatsSummary: %v", err)
+			}
+			if summary.TotalSessions != d.wantSessions || summary.TotalDuration != d.wantDuration {
+				t.Errorf("summary = (%d sessions, %ds), want (%d sessions, %ds)",
+					summary.TotalSessions, summary.TotalDuration, d.wantSessions, d.wantDuration)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	now := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		call    func(svc timer.Service) error
+		wantErr error
+		wantIs  error
+	}{
+		{
+			name: "start without tag",
+			call: func(svc timer.Service) error {
+				_, err := svc.Start(context.Background(), userID, "", "", 0, now)
+				return err
+			},
+			wantErr: timer.ErrTagRequired,
+			wantIs:  models.ErrValidation,
+		},
+		{
+			name: "stop without running timer",
+			call: func(svc timer.Service) error {
+				_, err := svc.Stop(context.Background(), userID, tag, "", now)
+				return err
+			},
+			wantErr: timer.ErrNoRunningTimer,
+			wantIs:  models.ErrNotFound,
+		},
+		{
+			name:    "reset without stopped timer",
+			call:    func(svc timer.Service) error { _, err := svc.Reset(context.Background(), userID, tag, now); return err },
+			wantErr: timer.ErrNoStoppedTimer,
+			wantIs:  models.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, _ := newService(now)
+			err := tt.call(svc)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !errors.Is(err, tt.wantIs) {
+				t.Errorf("err = %v, want it to wrap %v", err, tt.wantIs)
+			}
+		})
+	}
+}
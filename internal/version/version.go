@@ -0,0 +1,53 @@
+// Package version reports which build of the application is running.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit are set at build time with
+//
+//	-ldflags "-X github.com/neilsmahajan/productivity-timer/internal/version.Version=v1.2.3"
+//
+// Commit falls back to the VCS revision Go embeds in the binary
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version" example:"v1.2.3"`
+	Commit    string `json:"commit,omitempty" example:"4f2c1e9"`
+	GoVersion string `json:"goVersion" example:"go1.25.0"`
+}
+
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+	if info.Commit == "" {
+		info.Commit = vcsRevision()
+	}
+	return info
+}
+
+func vcsRevision() string {
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
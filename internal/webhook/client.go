@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("URL resolves to a private or local address")
+
+// NewClient returns the HTTP client deliveries are sent with; the server
+// sends push notifications with one too. Redirects are not followed, so a
+// receiver cannot point deliveries elsewhere. Unless allowPrivate is set,
+// connections to loopback, private and link-local addresses are refused, so
+// user-supplied URLs cannot reach services on the server's own network.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refusePrivate is a net.Dialer Control function. It runs after the host
+// name is resolved, so it sees the address actually dialled.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	addr := addrPort.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addr)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+// Package webhook sends users' timer activity to URLs they registered.
+// Events are queued as deliveries in the database and sent by a background
+// worker, which retries failures with exponential backoff. Every request is
+// signed with the webhook's secret; see Sign.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/neilsmahajan/productivity-timer/internal/clock"
+	"github.com/neilsmahajan/productivity-timer/internal/database"
+	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
+	"github.com/neilsmahajan/productivity-timer/internal/version"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed. With the backoff below the last try is about an hour after
+	// the first.
+	MaxAttempts = 8
+	// firstRetryDelay doubles after each failed attempt up to maxRetryDelay
+	firstRetryDelay = 30 * time.Second
+	maxRetryDelay   = time.Hour
+	// claimLease is how long a claimed delivery is held back from other
+	// workers; it outlasts a send
+	claimLease = 2 * time.Minute
+	// deliveryBatch bounds the deliveries one call to Deliver sends, and
+	// maxConcurrentSends how many of them are sent at once
+	deliveryBatch      = 100
+	maxConcurrentSends = 8
+	// maxURLLength bounds a webhook URL
+	maxURLLength = 2048
+	// maxErrorLength bounds the response body kept in the delivery log
+	maxErrorLength = 256
+)
+
+var (
+	ErrInvalidURL      = fmt.Errorf("%w: url must be an http or https URL of at most %d characters", models.ErrValidation, maxURLLength)
+	ErrInvalidEvents   = fmt.Errorf("%w: events must name at least one of %s", models.ErrValidation, strings.Join(models.WebhookEvents, ", "))
+	ErrTooManyWebhooks = fmt.Errorf("%w: at most %d webhooks can be registered", models.ErrValidation, models.MaxWebhooks)
+)
+
+// Payload is the JSON body of every delivery
+type Payload struct {
+	// ID is the delivery's; it stays the same across retries so receivers
+	// can ignore repeats
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"` // TimerData or TagData
+}
+
+// TimerData describes the timer of a timer.* event
+type TimerData struct {
+	Tag       string             `json:"tag"`
+	Note      string             `json:"note,omitempty"`
+	Status    models.TimerStatus `json:"status"`
+	Elapsed   int64              `json:"elapsed"`             // Seconds
+	Countdown int64              `json:"countdown,omitempty"` // Seconds; zero counts up
+	DueAt     *time.Time         `json:"dueAt,omitempty"`
+	// Reason is why a timer stopped: "user", "switch" or "countdown"
+	Reason string `json:"reason,omitempty"`
+}
+
+// TagData names the tag of a tag.deleted event
+type TagData struct {
+	Tag string `json:"tag"`
+}
+
+// Service registers webhooks and delivers events to them. As a
+// timer.Listener it queues the timer events of the users it is told about.
+type Service interface {
+	timer.Listener
+	// Register validates and stores a webhook for the user with a new
+	// secret
+	Register(ctx context.Context, userID, rawURL string, events []string) (*models.Webhook, error)
+	// Publish queues event for each of the user's webhooks that subscribed
+	// to it
+	Publish(ctx context.Context, userID, event string, data any) error
+	// Deliver sends the deliveries that are due and returns how many it
+	// attempted. Deliveries to different webhooks are sent at once, but
+	// each webhook gets one at a time, so a slow receiver holds up only its
+	// own. Failed deliveries are retried by a later call.
+	Deliver(ctx context.Context) (int, error)
+}
+
+type service struct {
+	db     database.Service
+	client *http.Client
+	clock  clock.Clock
+}
+
+// New returns a Service that sends deliveries with client; see NewClient
+func New(db database.Service, client *http.Client, clk clock.Clock) Service {
+	return &service{db: db, client: client, clock: clk}
+}
+
+func (s *service) Register(ctx context.Context, userID, rawURL string, events []string) (*models.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil || len(rawURL) > maxURLLength {
+		return nil, ErrInvalidURL
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return nil, ErrInvalidEvents
+		}
+	}
+	// Stored in the order they are offered, without repeats
+	var subscribed []string
+	for _, event := range models.WebhookEvents {
+		if slices.Contains(events, event) {
+			subscribed = append(subscribed, event)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil, ErrInvalidEvents
+	}
+
+	webhooks, err := s.db.FindWebhooks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) >= models.MaxWebhooks {
+		return nil, ErrTooManyWebhooks
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		URL:       u.String(),
+		Events:    subscribed,
+		Secret:    secret,
+		CreatedAt: s.clock.Now(),
+	}
+	if err = s.db.CreateWebhook(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *service) TimerChanged(ctx context.Context, event timer.Event) {
+	session := event.State.Session
+	data := TimerData{
+		Tag:       session.Tag,
+		Note:      session.Note,
+		Status:    session.Status,
+		Elapsed:   event.State.Elapsed,
+		Countdown: session.Countdown,
+		DueAt:     session.DueAt,
+		Reason:    event.Reason,
+	}
+	if err := s.Publish(ctx, session.UserID, event.Name, data); err != nil {
+		slog.ErrorContext(ctx, "Error queueing webhook deliveries", "user_id", session.UserID, "event", event.Name, "err", err)
+	}
+}
+
+func (s *service) Publish(ctx context.Context, userID, event string, data any) error {
+	webhooks, err := s.db.FindWebhooks(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	var deliveries []*models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event) {
+			continue
+		}
+		id := primitive.NewObjectID()
+		payload, err := json.Marshal(Payload{ID: id.Hex(), Event: event, CreatedAt: now, Data: data})
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			ID:            id,
+			WebhookID:     webhook.ID,
+			UserID:        userID,
+			Event:         event,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+	}
+	return s.db.CreateWebhookDeliveries(ctx, deliveries)
+}
+
+// sent is the outcome of attempting a delivery to a webhook
+type sent struct {
+	webhookID primitive.ObjectID
+	err       error
+}
+
+func (s *service) Deliver(ctx context.Context) (int, error) {
+	// sending holds the webhooks with an attempt in flight; none is claimed
+	// another delivery until its attempt is done
+	sending := make(map[primitive.ObjectID]bool)
+	done := make(chan sent)
+	var attempted int
+	var errs []error
+	claiming := true
+	for {
+		if claiming && attempted < deliveryBatch && len(sending) < maxConcurrentSends {
+			now := s.clock.Now()
+			delivery, err := s.db.ClaimWebhookDelivery(ctx, now, now.Add(claimLease), slices.Collect(maps.Keys(sending)))
+			if err == nil {
+				attempted++
+				sending[delivery.WebhookID] = true
+				go func() {
+					done <- sent{webhookID: delivery.WebhookID, err: s.attempt(ctx, delivery)}
+				}()
+				continue
+			}
+			if !errors.Is(err, models.ErrNotFound) {
+				errs = append(errs, err)
+			}
+			// Nothing else is due, or only for webhooks being sent to;
+			// look again once one of them is free
+			claiming = false
+		}
+
+		if len(sending) == 0 {
+			return attempted, errors.Join(errs...)
+		}
+		result := <-done
+		delete(sending, result.webhookID)
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+		// After an error only the attempts in flight are seen through
+		claiming = len(errs) == 0
+	}
+}
+
+// attempt sends a claimed delivery once and records the outcome, scheduling
+// a retry after a failure unless it was the last attempt
+func (s *service) attempt(ctx context.Context, delivery *models.WebhookDelivery) error {
+	webhook, err := s.db.FindWebhook(ctx, delivery.UserID, delivery.WebhookID)
+	if errors.Is(err, models.ErrNotFound) {
+		// Deleted since the event; its deliveries are being deleted too
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	status, err := s.send(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the lease runs out and another attempt is made
+		return ctx.Err()
+	}
+
+	now := s.clock.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.Error = ""
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = nil
+		slog.WarnContext(ctx, "Webhook delivery failed", "user_id", delivery.UserID, "webhook_id", webhook.ID.Hex(), "delivery_id", delivery.ID.Hex(), "err", err)
+	default:
+		next := now.Add(retryDelay(delivery.Attempts))
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+	return s.db.UpdateWebhookDelivery(ctx, delivery)
+}
+
+// send posts the delivery's payload and returns the response status, zero
+// when there was no response. Any status but 2xx is an error.
+func (s *service) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "productivity-timer-webhooks/"+version.Version)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID.Hex())
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, s.clock.Now(), delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return resp.StatusCode, fmt.Errorf("receiver answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorLength))
+	return resp.StatusCode, nil
+}
+
+// retryDelay is the wait after the given number of failed attempts
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
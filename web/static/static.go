@@ -0,0 +1,9 @@
+// Package static holds the browser files that are not templates.
+package static
+
+import _ "embed"
+
+// ServiceWorker shows the push notifications the server sends
+//
+//go:embed sw.js
+var ServiceWorker []byte
@@ -0,0 +1,22 @@
+// Service worker that shows the push notifications the server sends, e.g.
+// when a countdown finishes while the timer's page is closed.
+
+self.addEventListener('push', (event) => {
+	const message = event.data ? event.data.json() : {};
+	event.waitUntil(self.registration.showNotification(message.title || 'Productivity Timer', {
+		body: message.body,
+		tag: message.tag,
+		renotify: Boolean(message.tag),
+		data: { url: message.url || '/' },
+	}));
+});
+
+// Clicking a notification focuses the app's tab, or opens one
+self.addEventListener('notificationclick', (event) => {
+	event.notification.close();
+	const url = new URL(event.notification.data.url, self.location.origin).href;
+	event.waitUntil(self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((windows) => {
+		const open = windows.find((client) => client.url === url);
+		return open ? open.focus() : self.clients.openWindow(url);
+	}));
+});
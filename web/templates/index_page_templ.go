@@ -1,6 +1,6 @@
 // Code generated by templ - DO NOT EDIT.
 
-// templ: version: v0.3.960
+// templ: version: v0.3.977
 package templates
 
 //lint:file-ignore SA4006 This context is only used if a nested component is present.
@@ -10,11 +10,21 @@ import (
 
 	"github.com/a-h/templ"
 	templruntime "github.com/a-h/templ/runtime"
-	"github.com/markbates/goth"
 	"github.com/neilsmahajan/productivity-timer/internal/models"
+	"github.com/neilsmahajan/productivity-timer/internal/timer"
 )
 
-func IndexPage(user *goth.User, activeSession *models.TimerSession, tags []string) templ.Component {
+// csrfHeaders returns the hx-headers value that makes every HTMX request on a
+// page carry the session's CSRF token
+func csrfHeaders(token string) string {
+	headers, _ := templ.JSONString(map[string]string{"X-CSRF-Token": token})
+	return headers
+}
+
+// IndexPage shows the user's timer, or with concurrent timers all of them.
+// pushKey is the key browsers subscribe to push notifications with; empty
+// when the server cannot send them.
+func IndexPage(user *models.User, activeSession *models.TimerSession, elapsed int64, timers []*timer.State, tags []string, csrfToken string, pushKey string) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -35,61 +45,147 @@ func IndexPage(user *goth.User, activeSession *models.TimerSession, tags []strin
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Productivity Timer</title><script src=\"https://cdn.jsdelivr.net/npm/htmx.org@2.0.8/dist/htmx.min.js\" integrity=\"sha384-/TgkGk7p307TH7EXJDuUlgG3Ce1UVolAOFopFekQkkXihi5u/6OCvVKyz1W+idaz\" crossorigin=\"anonymous\"></script><script src=\"//unpkg.com/alpinejs\" defer></script><style>\n\t\t\t\t* { box-sizing: border-box; margin: 0; padding: 0; }\n\t\t\t\tbody { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f5f5f5; min-height: 100vh; }\n\t\t\t\t.container { max-width: 900px; margin: 0 auto; padding: 20px; }\n\t\t\t\th1 { color: #333; margin-bottom: 20px; }\n\t\t\t\t.card { background: white; border-radius: 8px; padding: 20px; margin-bottom: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }\n\t\t\t\t.header { display: flex; justify-content: space-between; align-items: center; margin-bottom: 20px; }\n\t\t\t\t.user-info { display: flex; align-items: center; gap: 12px; }\n\t\t\t\t.avatar { width: 40px; height: 40px; border-radius: 50%; }\n\t\t\t\t.user-email { color: #333; font-weight: 500; }\n\t\t\t\t.nav-links { display: flex; gap: 15px; align-items: center; }\n\t\t\t\t.nav-link { color: #4CAF50; text-decoration: none; padding: 8px 16px; border-radius: 4px; transition: all 0.2s; }\n\t\t\t\t.nav-link:hover { background: #e8f5e9; }\n\t\t\t\t.logout-link { color: #666; }\n\t\t\t\t.logout-link:hover { color: #dc2626; background: #fee2e2; }\n\t\t\t\t.timer-card { text-align: center; padding: 40px 20px; }\n\t\t\t\t.timer-display { font-size: 72px; font-weight: bold; color: #333; margin-bottom: 10px; font-variant-numeric: tabular-nums; }\n\t\t\t\t.timer-display.running { color: #4CAF50; }\n\t\t\t\t.timer-tag { font-size: 18px; color: #666; margin-bottom: 30px; }\n\t\t\t\t.timer-tag strong { color: #4CAF50; }\n\t\t\t\t.timer-status { font-size: 14px; color: #999; margin-bottom: 20px; }\n\t\t\t\t.btn { padding: 12px 24px; font-size: 16px; cursor: pointer; border: none; border-radius: 6px; font-weight: 500; transition: all 0.2s; }\n\t\t\t\t.btn-primary { background: linear-gradient(135deg, #4CAF50 0%, #8BC34A 100%); color: white; }\n\t\t\t\t.btn-primary:hover { transform: translateY(-1px); box-shadow: 0 4px 12px rgba(76, 175, 80, 0.4); }\n\t\t\t\t.btn-danger { background: linear-gradient(135deg, #f093fb 0%, #f5576c 100%); color: white; }\n\t\t\t\t.btn-danger:hover { transform: translateY(-1px); box-shadow: 0 4px 12px rgba(245, 87, 108, 0.4); }\n\t\t\t\t.btn-secondary { background: #64748b; color: white; }\n\t\t\t\t.btn-secondary:hover { background: #475569; }\n\t\t\t\t.btn-group { display: flex; gap: 12px; justify-content: center; flex-wrap: wrap; }\n\t\t\t\t.timer-form { display: flex; gap: 12px; justify-content: center; align-items: flex-start; flex-wrap: wrap; }\n\t\t\t\t.tag-select { padding: 12px 16px; font-size: 16px; border: 1px solid #ddd; border-radius: 6px; min-width: 200px; }\n\t\t\t\t.tag-select:focus { outline: none; border-color: #4CAF50; box-shadow: 0 0 0 3px rgba(76, 175, 80, 0.1); }\n\t\t\t\t.idle-message { color: #666; margin-bottom: 30px; }\n\t\t\t\t[x-cloak] { display: none !important; }\n\t\t\t</style></head><body><div class=\"container\"><div class=\"card header\"><div class=\"user-info\"><img src=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<!doctype html><html lang=\"en\"><head><meta charset=\"UTF-8\"><meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\"><title>Productivity Timer</title><script src=\"https://cdn.jsdelivr.net/npm/htmx.org@2.0.8/dist/htmx.min.js\" integrity=\"sha384-/TgkGk7p307TH7EXJDuUlgG3Ce1UVolAOFopFekQkkXihi5u/6OCvVKyz1W+idaz\" crossorigin=\"anonymous\"></script>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = HTMXErrorConfig().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<script src=\"//unpkg.com/alpinejs\" defer></script><script>\n\t\t\t\t// countdownFinished beeps and notifies the user that tag's countdown\n\t\t\t\t// reached zero, then reloads the timers, which the server stops on time\n\t\t\t\tfunction countdownFinished(tag) {\n\t\t\t\t\ttry {\n\t\t\t\t\t\tconst audio = new AudioContext();\n\t\t\t\t\t\tconst beep = audio.createOscillator();\n\t\t\t\t\t\tbeep.frequency.value = 880;\n\t\t\t\t\t\tbeep.connect(audio.destination);\n\t\t\t\t\t\tbeep.start();\n\t\t\t\t\t\tbeep.stop(audio.currentTime + 0.6);\n\t\t\t\t\t} catch (e) {}\n\t\t\t\t\tif (window.Notification && Notification.permission === 'granted') {\n\t\t\t\t\t\tnew Notification('Countdown finished', { body: tag });\n\t\t\t\t\t}\n\t\t\t\t\tsetTimeout(() => htmx.trigger(document.body, 'countdownFinished'), 1000);\n\t\t\t\t}\n\n\t\t\t\t// requestCountdownAlerts asks to show notifications when a countdown is started\n\t\t\t\tfunction requestCountdownAlerts(form) {\n\t\t\t\t\tif (form.countdown.value.trim() !== '' && window.Notification && Notification.permission === 'default') {\n\t\t\t\t\t\tNotification.requestPermission();\n\t\t\t\t\t}\n\t\t\t\t}\n\n\t\t\t\t// pushToggle subscribes this browser to the server's push notifications,\n\t\t\t\t// so alerts arrive while the page is closed, or unsubscribes it\n\t\t\t\tfunction pushToggle(key) {\n\t\t\t\t\treturn {\n\t\t\t\t\t\tsupported: 'serviceWorker' in navigator && 'PushManager' in window,\n\t\t\t\t\t\tenabled: false,\n\t\t\t\t\t\terror: '',\n\t\t\t\t\t\tasync init() {\n\t\t\t\t\t\t\tif (!this.supported) return;\n\t\t\t\t\t\t\tconst registration = await navigator.serviceWorker.register('/sw.js');\n\t\t\t\t\t\t\tthis.enabled = (await registration.pushManager.getSubscription()) !== null;\n\t\t\t\t\t\t},\n\t\t\t\t\t\tasync toggle(on) {\n\t\t\t\t\t\t\tthis.error = '';\n\t\t\t\t\t\t\ttry {\n\t\t\t\t\t\t\t\tconst registration = await navigator.serviceWorker.ready;\n\t\t\t\t\t\t\t\tlet subscription = await registration.pushManager.getSubscription();\n\t\t\t\t\t\t\t\tif (on) {\n\t\t\t\t\t\t\t\t\tsubscription ??= await registration.pushManager.subscribe({\n\t\t\t\t\t\t\t\t\t\tuserVisibleOnly: true,\n\t\t\t\t\t\t\t\t\t\tapplicationServerKey: Uint8Array.from(atob(key.replace(/-/g, '+').replace(/_/g, '/')), (c) => c.charCodeAt(0)),\n\t\t\t\t\t\t\t\t\t});\n\t\t\t\t\t\t\t\t\tconst { endpoint, keys } = subscription.toJSON();\n\t\t\t\t\t\t\t\t\tawait pushRequest('POST', '/api/v1/push/subscriptions', new URLSearchParams({ endpoint, p256dh: keys.p256dh, auth: keys.auth }));\n\t\t\t\t\t\t\t\t} else if (subscription) {\n\t\t\t\t\t\t\t\t\tawait pushRequest('DELETE', '/api/v1/push/subscriptions?' + new URLSearchParams({ endpoint: subscription.endpoint }));\n\t\t\t\t\t\t\t\t\tawait subscription.unsubscribe();\n\t\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\t\tthis.enabled = on;\n\t\t\t\t\t\t\t} catch (e) {\n\t\t\t\t\t\t\t\tthis.enabled = !on;\n\t\t\t\t\t\t\t\tthis.error = on ? 'Notifications could not be turned on: ' + e.message : 'Notifications could not be turned off: ' + e.message;\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t},\n\t\t\t\t\t};\n\t\t\t\t}\n\n\t\t\t\t// pushRequest calls the push API with the page's CSRF token\n\t\t\t\tasync function pushRequest(method, url, body) {\n\t\t\t\t\tconst headers = JSON.parse(document.body.getAttribute('hx-headers'));\n\t\t\t\t\tconst response = await fetch(url, { method, headers, body });\n\t\t\t\t\tif (!response.ok && !(method === 'DELETE' && response.status === 404)) {\n\t\t\t\t\t\tthrow new Error((await response.json()).message || response.statusText);\n\t\t\t\t\t}\n\t\t\t\t}\n\t\t\t</script><style>\n\t\t\t\t* { box-sizing: border-box; margin: 0; padding: 0; }\n\t\t\t\tbody { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f5f5f5; min-height: 100vh; }\n\t\t\t\t.container { max-width: 900px; margin: 0 auto; padding: 20px; }\n\t\t\t\th1 { color: #333; margin-bottom: 20px; }\n\t\t\t\t.card { background: white; border-radius: 8px; padding: 20px; margin-bottom: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }\n\t\t\t\t.header { display: flex; justify-content: space-between; align-items: center; margin-bottom: 20px; }\n\t\t\t\t.user-info { display: flex; align-items: center; gap: 12px; }\n\t\t\t\t.avatar { width: 40px; height: 40px; border-radius: 50%; }\n\t\t\t\t.user-email { color: #333; font-weight: 500; }\n\t\t\t\t.nav-links { display: flex; gap: 15px; align-items: center; }\n\t\t\t\t.nav-link { color: #4CAF50; text-decoration: none; padding: 8px 16px; border-radius: 4px; transition: all 0.2s; }\n\t\t\t\t.nav-link:hover { background: #e8f5e9; }\n\t\t\t\t.logout-link { color: #666; }\n\t\t\t\t.logout-link:hover { color: #dc2626; background: #fee2e2; }\n\t\t\t\t.timer-card { text-align: center; padding: 40px 20px; }\n\t\t\t\t.timer-display { font-size: 72px; font-weight: bold; color: #333; margin-bottom: 10px; font-variant-numeric: tabular-nums; }\n\t\t\t\t.timer-display.running { color: #4CAF50; }\n\t\t\t\t.timer-tag { font-size: 18px; color: #666; margin-bottom: 30px; }\n\t\t\t\t.timer-tag strong { color: #4CAF50; }\n\t\t\t\t.timer-status { font-size: 14px; color: #999; margin-bottom: 20px; }\n\t\t\t\t.btn { padding: 12px 24px; font-size: 16px; cursor: pointer; border: none; border-radius: 6px; font-weight: 500; transition: all 0.2s; }\n\t\t\t\t.btn-primary { background: linear-gradient(135deg, #4CAF50 0%, #8BC34A 100%); color: white; }\n\t\t\t\t.btn-primary:hover { transform: translateY(-1px); box-shadow: 0 4px 12px rgba(76, 175, 80, 0.4); }\n\t\t\t\t.btn-danger { background: linear-gradient(135deg, #f093fb 0%, #f5576c 100%); color: white; }\n\t\t\t\t.btn-danger:hover { transform: translateY(-1px); box-shadow: 0 4px 12px rgba(245, 87, 108, 0.4); }\n\t\t\t\t.btn-secondary { background: #64748b; color: white; }\n\t\t\t\t.btn-secondary:hover { background: #475569; }\n\t\t\t\t.btn-group { display: flex; gap: 12px; justify-content: center; flex-wrap: wrap; }\n\t\t\t\t.timer-form { display: flex; gap: 12px; justify-content: center; align-items: flex-start; flex-wrap: wrap; }\n\t\t\t\t.tag-select { padding: 12px 16px; font-size: 16px; border: 1px solid #ddd; border-radius: 6px; min-width: 200px; }\n\t\t\t\t.tag-select:focus { outline: none; border-color: #4CAF50; box-shadow: 0 0 0 3px rgba(76, 175, 80, 0.1); }\n\t\t\t\t.countdown-input { padding: 12px 16px; font-size: 16px; border: 1px solid #ddd; border-radius: 6px; width: 220px; }\n\t\t\t\t.countdown-input:focus { outline: none; border-color: #4CAF50; box-shadow: 0 0 0 3px rgba(76, 175, 80, 0.1); }\n\t\t\t\t.note-input { padding: 12px 16px; font-size: 16px; border: 1px solid #ddd; border-radius: 6px; min-width: 260px; }\n\t\t\t\t.note-input:focus { outline: none; border-color: #4CAF50; box-shadow: 0 0 0 3px rgba(76, 175, 80, 0.1); }\n\t\t\t\t.note-form { margin-bottom: 20px; }\n\t\t\t\t.timer-note { font-size: 15px; color: #555; font-style: italic; margin-bottom: 20px; }\n\t\t\t\t.timer-board .timer-card { padding: 25px 20px; }\n\t\t\t\t.timer-board .timer-display { font-size: 48px; }\n\t\t\t\t.timer-board .timer-tag { margin-bottom: 15px; }\n\t\t\t\t.concurrent-toggle, .push-toggle { display: flex; gap: 8px; align-items: center; justify-content: flex-end; margin: -10px 0 15px; font-size: 14px; color: #666; cursor: pointer; }\n\t\t\t\t.push-error { color: #dc2626; }\n\t\t\t\t.push-test { background: none; border: none; color: #4CAF50; cursor: pointer; font-size: 14px; text-decoration: underline; }\n\t\t\t\t.idle-message { color: #666; margin-bottom: 30px; }\n\t\t\t\t[x-cloak] { display: none !important; }\n\t\t\t</style></head><body hx-headers=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var2 string
-		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(user.AvatarURL)
+		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(csrfHeaders(csrfToken))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 58, Col: 31}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 148, Col: 43}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" alt=\"avatar\" class=\"avatar\"> <span class=\"user-email\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = ErrorBanner().Render(ctx, templ_7745c5c3_Buffer)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<div class=\"container\"><div class=\"card header\"><div class=\"user-info\"><img src=\"")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var3 string
-		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(user.Email)
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(user.AvatarURL)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 59, Col: 43}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 153, Col: 31}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "</span></div><div class=\"nav-links\"><a href=\"/stats\" class=\"nav-link\">📊 Stats</a> <a href=\"")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\" alt=\"avatar\" class=\"avatar\"> <span class=\"user-email\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var4 templ.SafeURL
-		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinURLErrs(templ.URL(fmt.Sprintf("/logout/%s", user.Provider)))
+		var templ_7745c5c3_Var4 string
+		templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(user.Email)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 63, Col: 67}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 154, Col: 43}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" class=\"nav-link logout-link\">Logout</a></div></div><h1>⏱️ Productivity Timer</h1><div class=\"card timer-card\" id=\"timer-container\">")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</span></div><div class=\"nav-links\"><a href=\"/stats\" class=\"nav-link\">📊 Stats</a> <a href=\"/tags\" class=\"nav-link\">🏷️ Tags</a> <a href=\"/reports\" class=\"nav-link\">🧾 Billing</a> <a href=\"/sessions\" class=\"nav-link\">🔐 Devices</a> <a href=\"/webhooks\" class=\"nav-link\">🔗 Webhooks</a> <a href=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var5 templ.SafeURL
+		templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinURLErrs(templ.URL(fmt.Sprintf("/logout/%s", user.Provider)))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 162, Col: 67}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\" class=\"nav-link logout-link\">Logout</a></div></div><h1>⏱️ Productivity Timer</h1><label class=\"concurrent-toggle\" title=\"Keep other timers running when you start one, e.g. on-call time alongside focused work\"><input type=\"checkbox\" name=\"enabled\" value=\"true\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if user.ConcurrentTimers {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, " checked")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, " hx-put=\"/api/v1/timer/concurrent\" hx-trigger=\"change\" hx-swap=\"none\"> Run several timers at once</label> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		if activeSession == nil {
-			templ_7745c5c3_Err = TimerIdle(tags).Render(ctx, templ_7745c5c3_Buffer)
+		if pushKey != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<label class=\"push-toggle\" title=\"Get a notification on this device when a countdown finishes or a timer runs for hours, even with this page closed\" x-data=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var6 string
+			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("pushToggle(%q)", pushKey))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `web/templates/index_page.templ`, Line: 171, Col: 201}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "\" x-show=\"supported\" x-cloak><input type=\"checkbox\" :checked=\"enabled\" @change=\"toggle($event.target.checked)\"> Notify me on this device <span class=\"push-error\" x-show=\"error\" x-text=\"error\"></span> <button type=\"button\" class=\"push-test\" x-show=\"enabled\" hx-post=\"/api/v1/push/test\" hx-swap=\"none\">Send a test</button></label> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		if user.ConcurrentTimers {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<div id=\"timers\" hx-get=\"/api/v1/timer/active\" hx-trigger=\"timersChanged from:body, countdownFinished from:body\" hx-swap=\"innerHTML\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = TimerBoard(timers, tags).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "</div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		} else {
-			templ_7745c5c3_Err = TimerRunning(activeSession, 0).Render(ctx, templ_7745c5c3_Buffer)
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, "<div class=\"card timer-card\" id=\"timer-container\" hx-get=\"/api/v1/timer\" hx-trigger=\"countdownFinished from:body\" hx-target=\"this\" hx-swap=\"innerHTML\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if activeSession == nil {
+				templ_7745c5c3_Err = TimerIdle(tags).Render(ctx, templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else if activeSession.Status == models.StatusRunning {
+				templ_7745c5c3_Err = TimerRunning(activeSession, elapsed).Render(ctx, templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = TimerStopped(activeSession, elapsed).Render(ctx, templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "</div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div></div></body></html>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</div></body></html>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}